@@ -2,10 +2,24 @@ package main
 
 import (
 	"GoNews/pkg/api"
+	"GoNews/pkg/config"
+	"GoNews/pkg/media"
+	"GoNews/pkg/rbac"
 	"GoNews/pkg/storage"
+	"GoNews/pkg/storage/memdb"
+	"GoNews/pkg/storage/mongo"
 	"GoNews/pkg/storage/postgres"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -17,42 +31,242 @@ type server struct {
 }
 
 func main() {
-
-	// Используем БД в памяти (для тестов)
-	// srv.db = memdb.New()
-
 	// Загружаем переменные окружения из .env
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Не удалось загрузить .env файл, используем переменные окружения")
 	}
 
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.Usage = func() { usage(fs) }
+	cfg, args, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		log.Fatal("Ошибка конфигурации:\n", err)
+	}
+	if cfg.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	var srv server
 
-	// Подключение к PostgreSQL
-	db, err := postgres.New() // Теперь New() не принимает строку, а берёт данные из os.Getenv()
+	srv.db, err = openStorage(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Управление миграциями: server migrate up|down [N]|status
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(srv.db, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// Создание администратора: server create-admin <логин> <имя>
+	if len(args) > 0 && args[0] == "create-admin" {
+		if err := runCreateAdmin(srv.db, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	store, err := openMedia(cfg)
 	if err != nil {
-		log.Fatal("Ошибка подключения к PostgreSQL:", err)
+		log.Fatal(err)
 	}
-	srv.db = db
 
-	// Подключение к MongoDB (альтернативный вариант)
-	/*
-		db3, err := mongo.New("mongodb://localhost:27017/")
+	// Перенос аватарок в хранилище файлов: server migrate-avatars
+	if len(args) == 1 && args[0] == "migrate-avatars" {
+		n, err := api.MigrateAvatars(context.Background(), srv.db, cfg.Static, store)
+		log.Printf("Перенесено аватарок: %d", n)
 		if err != nil {
 			log.Fatal(err)
 		}
-		srv.db = db3
-	*/
-	////////////////////////
-	// mongoURI := os.Getenv("MONGO_URI") // Получаем строку подключения из .env
-	// db3, err := mongo.New(mongoURI) // Используем переменную окружения для подключения
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
-	// srv.db = db3
-
-	srv.api = api.New(srv.db)
-	log.Println("Сервер запущен на :8080")
-	http.ListenAndServe(":8080", srv.api.Router())
+		return
+	}
+	if len(args) > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if cfg.Migrate {
+		if err := runMigrate(srv.db, []string{"up"}); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	srv.api = api.New(srv.db, api.Options{
+		Templates: cfg.Templates,
+		Static:    cfg.Static,
+		Timeout:   cfg.Timeout,
+
+		SessionSecret: cfg.Session.Secret,
+		SessionTTL:    cfg.Session.TTL,
+		SecureCookies: cfg.Session.Secure,
+
+		DefaultRole: rbac.Role(cfg.DefaultRole),
+
+		CORS: api.CORS{
+			Origins:     cfg.CORS.Origins,
+			Methods:     cfg.CORS.Methods,
+			Headers:     cfg.CORS.Headers,
+			Credentials: cfg.CORS.Credentials,
+			MaxAge:      cfg.CORS.MaxAge,
+		},
+
+		Media: store,
+	})
+	log.Printf("Сервер запущен на %s (хранилище %s)", cfg.Addr, cfg.Storage)
+	log.Fatal(http.ListenAndServe(cfg.Addr, srv.api.Router()))
+}
+
+// openStorage подключается к хранилищу, выбранному в конфигурации.
+func openStorage(cfg config.Config) (storage.Interface, error) {
+	switch cfg.Storage {
+	case config.StoragePostgres:
+		db, err := postgres.New(cfg.Postgres.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка подключения к PostgreSQL: %w", err)
+		}
+		return db, nil
+	case config.StorageMongo:
+		db, err := mongo.New(cfg.Mongo.URI, cfg.Mongo.Database)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка подключения к MongoDB: %w", err)
+		}
+		return db, nil
+	case config.StorageMemDB:
+		// БД в памяти: данные теряются при перезапуске
+		return memdb.New(), nil
+	}
+	return nil, fmt.Errorf("неизвестный драйвер хранилища %q", cfg.Storage)
+}
+
+// openMedia создаёт хранилище загруженных файлов, выбранное в конфигурации.
+func openMedia(cfg config.Config) (media.Store, error) {
+	m := cfg.Media
+	switch m.Driver {
+	case config.MediaFS:
+		// По умолчанию файлы лежат среди статических и раздаются вместе с ними
+		dir, baseURL := m.Dir, m.URL
+		if dir == "" {
+			dir = cfg.Static
+		}
+		if baseURL == "" {
+			baseURL = "/static/"
+			if dir != cfg.Static {
+				baseURL = "/media/"
+			}
+		}
+		return media.NewFS(dir, baseURL), nil
+	case config.MediaS3:
+		s, err := media.NewS3(media.S3Options{
+			Endpoint:  m.S3.Endpoint,
+			Bucket:    m.S3.Bucket,
+			Region:    m.S3.Region,
+			AccessKey: m.S3.AccessKey,
+			SecretKey: m.S3.SecretKey,
+			PublicURL: m.S3.PublicURL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ошибка настройки S3: %w", err)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("неизвестное хранилище файлов %q", m.Driver)
+}
+
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "Использование:\n")
+	fmt.Fprintf(out, "  %s [флаги]                              запуск сервера\n", fs.Name())
+	fmt.Fprintf(out, "  %s [флаги] migrate up|down [N]|status   управление миграциями БД\n", fs.Name())
+	fmt.Fprintf(out, "  %s [флаги] migrate-avatars              перенос аватарок в хранилище файлов\n", fs.Name())
+	fmt.Fprintf(out, "  %s [флаги] create-admin ЛОГИН ИМЯ       создание администратора; пароль из %s или stdin\n\n", fs.Name(), adminPasswordEnv)
+	fs.PrintDefaults()
+}
+
+// runMigrate выполняет команду управления миграциями.
+func runMigrate(s storage.Interface, args []string) error {
+	db, ok := s.(*postgres.Store)
+	if !ok {
+		return fmt.Errorf("миграции поддерживаются только для хранилища postgres")
+	}
+	ctx := context.Background()
+	if len(args) == 0 {
+		return fmt.Errorf("не указана команда migrate: up, down или status")
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := db.Migrate(ctx)
+		for _, m := range done {
+			log.Printf("Применена миграция %04d_%s", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			log.Println("Схема БД актуальна")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("неверное количество миграций для отката: %q", args[1])
+			}
+			steps = n
+		}
+		done, err := db.Rollback(ctx, steps)
+		for _, m := range done {
+			log.Printf("Откачена миграция %04d_%s", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		status, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range status {
+			applied := "не применена"
+			if st.Applied() {
+				applied = st.AppliedAt.Local().Format("02.01.2006 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", st.Version, st.Name, applied)
+		}
+		return nil
+	}
+	return fmt.Errorf("неизвестная команда migrate: %q", args[0])
+}
+
+// Переменная окружения с паролем для create-admin.
+const adminPasswordEnv = "GONEWS_ADMIN_PASSWORD"
+
+// runCreateAdmin заводит администратора. Пароль берётся из переменной окружения
+// adminPasswordEnv, а без неё - первой строкой стандартного ввода:
+// в аргументах он попал бы в историю команд и список процессов.
+func runCreateAdmin(db storage.Interface, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("использование: create-admin ЛОГИН ИМЯ")
+	}
+	if _, ok := db.(*memdb.Store); ok {
+		return fmt.Errorf("хранилище memdb не сохраняет данные: администратор пропадёт вместе с процессом")
+	}
+	password, ok := os.LookupEnv(adminPasswordEnv)
+	if !ok {
+		fmt.Fprint(os.Stderr, "Пароль: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("ошибка чтения пароля: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	id, err := api.CreateAdmin(context.Background(), db, args[1], args[0], password)
+	if err != nil {
+		return fmt.Errorf("ошибка создания администратора: %w", err)
+	}
+	log.Printf("Создан администратор %s (автор %d)", args[0], id)
+	return nil
 }
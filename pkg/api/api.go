@@ -1,67 +1,303 @@
 package api
 
 import (
+	"GoNews/pkg/media"
+	"GoNews/pkg/rbac"
 	"GoNews/pkg/storage"
+	"GoNews/pkg/validate"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"image"
-	"image/jpeg"
-	"image/png"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/nfnt/resize"
 )
 
+// Ограничение времени обработки одного запроса к хранилищу по умолчанию.
+const requestTimeout = 5 * time.Second
+
+// Options - настройки API. Нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	Templates string        // каталог HTML-шаблонов (по умолчанию templates)
+	Static    string        // каталог статических файлов (по умолчанию static)
+	Timeout   time.Duration // ограничение времени запроса к хранилищу
+
+	SessionSecret string        // ключ подписи cookie сессий (по умолчанию случайный)
+	SessionTTL    time.Duration // время жизни сессии
+	SecureCookies bool          // выдавать cookie только для HTTPS
+
+	DefaultRole rbac.Role // роль новых пользователей (по умолчанию автор)
+
+	CORS CORS // запросы со страниц других источников
+
+	Media media.Store // хранилище загруженных файлов (по умолчанию каталог статических файлов)
+}
+
 // Программный интерфейс сервера GoNews
 type API struct {
-	db     storage.Interface
-	router *mux.Router
+	db        storage.Interface
+	router    *mux.Router
+	timeout   time.Duration
+	templates string
+	static    string
+	sessions  sessions
+	role      rbac.Role // роль новых пользователей
+	corsOpts  CORS
+	media     media.Store
+	html      *htmlCache // HTML текста публикаций
 }
 
 // Конструктор объекта API
-func New(db storage.Interface) *API {
+func New(db storage.Interface, opts Options) *API {
 	api := API{
-		db: db,
+		db:        db,
+		timeout:   opts.Timeout,
+		templates: opts.Templates,
+		static:    opts.Static,
+		sessions:  newSessions(opts.SessionSecret, opts.SessionTTL, opts.SecureCookies),
+		role:      opts.DefaultRole,
+		corsOpts:  opts.CORS,
+		media:     opts.Media,
+		html:      newHTMLCache(htmlCacheSize),
+	}
+	if !api.role.Valid() {
+		api.role = rbac.Author
+	}
+	if api.timeout <= 0 {
+		api.timeout = requestTimeout
+	}
+	if api.templates == "" {
+		api.templates = "templates"
+	}
+	if api.static == "" {
+		api.static = "static"
+	}
+	if api.media == nil {
+		api.media = media.NewFS(api.static, "/static/")
+	}
+	if len(api.corsOpts.Methods) == 0 {
+		api.corsOpts.Methods = corsMethods
+	}
+	if len(api.corsOpts.Headers) == 0 {
+		api.corsOpts.Headers = corsHeaders
+	}
+	if api.corsOpts.Credentials && slices.Contains(api.corsOpts.Origins, "*") {
+		log.Println(`CORS: источник "*" не действует вместе с credentials, разрешены только перечисленные явно`)
 	}
 	api.router = mux.NewRouter()
+	api.router.Use(withRequestID, limitBody, api.cors, api.csrf, api.authenticate)
+	api.router.NotFoundHandler = notFoundHandler(http.StatusNotFound)
+	api.router.MethodNotAllowedHandler = notFoundHandler(http.StatusMethodNotAllowed)
 	api.endpoints()
 	return &api
 }
 
 // Регистрация обработчиков API.
 func (api *API) endpoints() {
-	api.router.HandleFunc("/posts/all", api.postsHandler).Methods(http.MethodGet, http.MethodOptions)
-	// api.router.HandleFunc("/add-post", api.addPostHandler).Methods(http.MethodPost, http.MethodOptions)
-	api.router.HandleFunc("/posts/{id}", api.updatePostHandler).Methods(http.MethodPut, http.MethodOptions)
-	api.router.HandleFunc("/posts/{id}", api.deletePostHandler).Methods(http.MethodDelete, http.MethodOptions)
+	// Все запросы OPTIONS: предварительные запросы CORS перехватывает промежуточный обработчик
+	api.router.Methods(http.MethodOptions).HandlerFunc(optionsHandler)
+
+	// JSON API: версионированные адреса и прежние адреса без версии
+	api.jsonEndpoints(api.router.PathPrefix(apiPrefix).Subrouter())
+	api.jsonEndpoints(api.router)
 
 	api.router.HandleFunc("/", api.homeHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/post/{id}", api.postPageHandler).Methods(http.MethodGet)     // страница публикации
+	api.router.HandleFunc("/author/{id}", api.authorPageHandler).Methods(http.MethodGet) // страница автора
 
 	// Обработка статических файлов
 	api.router.PathPrefix("/static/").HandlerFunc(api.staticFileHandler())
+	// Загруженные файлы из хранилища, если оно не раздаёт их само
+	api.router.PathPrefix(mediaURL).HandlerFunc(api.mediaHandler).Methods(http.MethodGet, http.MethodHead)
+
+	//вход и выход
+	api.router.HandleFunc("/login", api.loginPageHandler).Methods("GET")
+	api.router.HandleFunc("/login", api.loginHandler).Methods("POST")
+	api.router.HandleFunc("/logout", api.logoutHandler).Methods("POST")
 
-	//добавление пользователя
+	//назначение ролей
+	api.router.HandleFunc("/admin/roles", requireLogin(api.rolesPageHandler)).Methods("GET")
+	api.router.HandleFunc("/admin/roles/{id}", requireLogin(api.setRoleHandler)).Methods("POST")
+
+	//регистрация пользователя
 	api.router.HandleFunc("/add-user", api.addUserPageHandler).Methods("GET") // Для отображения формы
 	api.router.HandleFunc("/add-user", api.addUserHandler).Methods("POST")    // Для обработки формы
 
+	//редактирование и удаление пользователя
+	api.router.HandleFunc("/authors/{id}/edit", requireLogin(api.editAuthorPageHandler)).Methods("GET")      // Для отображения формы
+	api.router.HandleFunc("/authors/{id}/edit", requireLogin(api.editAuthorHandler)).Methods("POST")         // Для обработки формы
+	api.router.HandleFunc("/authors/{id}/delete", requireLogin(api.deleteAuthorFormHandler)).Methods("POST") // Для формы удаления
+
 	//добавление поста
-	api.router.HandleFunc("/add-post", api.addPostPageHandler).Methods("GET") // Для отображения формы
-	api.router.HandleFunc("/add-post", api.addPostHandler).Methods("POST")    // Для обработки формы
+	api.router.HandleFunc("/add-post", requireLogin(api.addPostPageHandler)).Methods("GET") // Для отображения формы
+	api.router.HandleFunc("/add-post", requireLogin(api.addPostHandler)).Methods("POST")    // Для обработки формы
+
+	//редактирование поста
+	api.router.HandleFunc("/posts/{id}/edit", requireLogin(api.editPostPageHandler)).Methods("GET") // Для отображения формы
+	api.router.HandleFunc("/posts/{id}/edit", requireLogin(api.editPostHandler)).Methods("POST")    // Для обработки формы
+
+	//история изменений поста
+	api.router.HandleFunc("/post/{id}/revisions", requireLogin(api.revisionsPageHandler)).Methods("GET")
+	api.router.HandleFunc("/post/{id}/diff", requireLogin(api.diffPageHandler)).Methods("GET")
+	api.router.HandleFunc("/post/{id}/revisions/{revision}/restore", requireLogin(api.restoreRevisionFormHandler)).Methods("POST")
+}
+
+// Регистрация обработчиков JSON API в маршрутизаторе r.
+// Вход по токену (Authorization: Bearer) или по cookie сессии.
+func (api *API) jsonEndpoints(r *mux.Router) {
+	handle := func(path string, h http.HandlerFunc, method string) {
+		r.Handle(path, tokenHandler{api.bearerAuth(h)}).Methods(method)
+	}
+
+	handle("/posts", api.postsPageHandler, http.MethodGet)
+	handle("/posts/all", api.postsHandler, http.MethodGet)
+	handle("/posts", requireScope(scopePostsWrite, api.createPostHandler), http.MethodPost)
+	handle("/posts/{id}", api.postHandler, http.MethodGet)
+	handle("/posts/{id}", requireScope(scopePostsWrite, api.updatePostHandler), http.MethodPut)
+	handle("/posts/{id}", requireScope(scopePostsWrite, api.deletePostHandler), http.MethodDelete)
+	handle("/preview", requireScope(scopePostsWrite, api.previewHandler), http.MethodPost)
+
+	// Вложения публикации
+	handle("/posts/{id}/attachments", api.attachmentsHandler, http.MethodGet)
+	handle("/posts/{id}/attachments", requireScope(scopePostsWrite, api.uploadAttachmentsHandler), http.MethodPost)
+	handle("/posts/{id}/attachments/{attachment}", requireScope(scopePostsWrite, api.deleteAttachmentHandler), http.MethodDelete)
+
+	// История изменений публикации
+	handle("/posts/{id}/revisions", requireLogin(api.revisionsHandler), http.MethodGet)
+	handle("/posts/{id}/revisions/{revision}", requireLogin(api.revisionHandler), http.MethodGet)
+	handle("/posts/{id}/revisions/{revision}/restore", requireScope(scopePostsWrite, api.restoreRevisionHandler), http.MethodPost)
+	handle("/posts/{id}/diff", requireLogin(api.diffHandler), http.MethodGet)
+
+	handle("/authors", api.authorsHandler, http.MethodGet)
+	handle("/authors/{id}", api.authorHandler, http.MethodGet)
+	handle("/authors/{id}/posts", api.authorPostsHandler, http.MethodGet)
+	handle("/authors/{id}", requireScope(scopeAuthorsAdmin, api.updateAuthorHandler), http.MethodPut)
+	handle("/authors/{id}", requireScope(scopeAuthorsAdmin, api.deleteAuthorHandler), http.MethodDelete)
+
+	// API-токены автора
+	handle("/authors/{id}/tokens", requireSession(api.tokensHandler), http.MethodGet)
+	handle("/authors/{id}/tokens", requireSession(api.createTokenHandler), http.MethodPost)
+	handle("/authors/{id}/tokens/{token}", requireSession(api.deleteTokenHandler), http.MethodDelete)
+}
+
+// Ограничение размера тела запроса: больше не бывает даже формы с файлом.
+const maxRequestBody = 10 << 20
+
+// limitBody - промежуточный обработчик: ограничивает размер тела запроса.
+func limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseForm разбирает отправленную форму, в том числе с файлами.
+func parseForm(r *http.Request) error {
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "multipart/form-data" {
+		return r.ParseMultipartForm(maxRequestBody)
+	}
+	return r.ParseForm()
+}
+
+// formParseError отвечает на ошибку разбора формы: 413 для слишком большого запроса.
+func formParseError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Запрос больше %d МБ", maxRequestBody>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Ошибка разбора формы", http.StatusBadRequest)
+}
+
+// Контекст для обращения к хранилищу: отменяется при разрыве соединения
+// клиентом и ограничен по времени.
+func (api *API) context(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), api.timeout)
+}
+
+// Вывод HTML-шаблона из каталога шаблонов.
+// Шаблонам доступны функции viewer (учётная запись вошедшего пользователя или nil),
+// can (есть ли у него право), canEditPost и canEditAuthor (может ли он менять
+// публикации автора или самого автора с указанным ID), roles (все роли),
+// csrfToken (токен для форм и запросов fetch) и postHTML (HTML текста
+// публикации в Markdown с её вложениями).
+func (api *API) render(w http.ResponseWriter, r *http.Request, name string, data any) {
+	acc, loggedIn := currentAccount(r)
+	funcs := template.FuncMap{
+		"viewer": func() *storage.Account {
+			if !loggedIn {
+				return nil
+			}
+			return &acc
+		},
+		"can":           func(p rbac.Permission) bool { return can(r, p) },
+		"canEditPost":   func(authorID int) bool { return canEditPost(r, authorID) },
+		"canEditAuthor": func(authorID int) bool { return canEditAuthor(r, authorID) },
+		"roles":         func() []rbac.Role { return rbac.Roles },
+		"csrfToken":     func() string { return csrfToken(r) },
+		"postHTML":      api.postHTML,
+	}
+	tmpl, err := template.New(name).Funcs(funcs).ParseFiles(filepath.Join(api.templates, name))
+	if err != nil {
+		log.Printf("Ошибка загрузки шаблона %s: %v", name, err)
+		http.Error(w, "Ошибка загрузки страницы", http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Ошибка вывода шаблона %s: %v", name, err)
+	}
+}
+
+// Числовой параметр id из пути запроса.
+func idParam(r *http.Request) (int, error) {
+	return intParam(r, "id")
+}
+
+// Положительный числовой параметр name из пути запроса.
+func intParam(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("неверный ID: %q", mux.Vars(r)[name])
+	}
+	return id, nil
+}
+
+// Код ответа HTTP для ошибки хранилища:
+// 404 - запись не найдена, 409 - конфликт, 422 - некорректные данные.
+func storageStatus(err error) int {
+	switch {
+	case errors.As(err, new(validate.Errors)):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
 }
 
 // Обработчик статических файлов
 func (api *API) staticFileHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Определяем путь к файлу
-		filePath := filepath.Join("static", r.URL.Path[len("/static/"):])
+		filePath := filepath.Join(api.static, r.URL.Path[len("/static/"):])
 
 		// Проверка существования файла
 		if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -72,34 +308,129 @@ func (api *API) staticFileHandler() http.HandlerFunc {
 		// Логирование запроса
 		log.Printf("Serving static file: %s", filePath)
 
+		// Файлы, загруженные пользователями, если хранилище файлов - каталог статики
+		if key := r.URL.Path[len("/static/"):]; strings.HasPrefix(key, avatarsKey) || strings.HasPrefix(key, attachmentsKey) {
+			uploadHeaders(w.Header(), mime.TypeByExtension(filepath.Ext(filePath)))
+		}
 		http.ServeFile(w, r, filePath)
 	}
 }
 
-// обработчик ГЕТ юзер
-func (api *API) addUserPageHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles("templates/add_user.html")
+// Типы загруженных файлов, которые браузер может показывать прямо на странице.
+var inlineUploadTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// uploadHeaders добавляет заголовки ответа с загруженным пользователем файлом
+// типа contentType: браузер не угадывает тип по содержимому, а всё, кроме
+// изображений, скачивает, а не открывает как страницу сайта.
+func uploadHeaders(h http.Header, contentType string) {
+	h.Set("X-Content-Type-Options", "nosniff")
+	if ct, _, _ := mime.ParseMediaType(contentType); !slices.Contains(inlineUploadTypes, ct) {
+		h.Set("Content-Disposition", "attachment")
+	}
+}
+
+// URL, по которому сервер раздаёт файлы из хранилища.
+const mediaURL = "/media/"
+
+// Раздача файла из хранилища файлов.
+func (api *API) mediaHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, mediaURL)
+	rc, info, err := api.media.Get(r.Context(), key)
+	if errors.Is(err, media.ErrNotFound) || errors.Is(err, media.ErrInvalidKey) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Ошибка загрузки страницы", http.StatusInternalServerError)
+		log.Printf("[%s] Ошибка чтения файла %s: %v", requestID(r), key, err)
+		http.Error(w, "Ошибка чтения файла", http.StatusBadGateway)
 		return
 	}
-	tmpl.Execute(w, nil)
+	defer rc.Close()
+
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	if info.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	// Имена файлов случайные и не переиспользуются
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	uploadHeaders(w.Header(), info.ContentType)
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, rc)
 }
 
 func (api *API) homeHandler(w http.ResponseWriter, r *http.Request) {
-	posts, err := api.db.Posts()
+	q, err := parsePostsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := pageNumber(r, &q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	res, err := api.db.Posts(ctx, q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storageError(w, r, err)
 		return
 	}
 
-	tmpl, err := template.ParseFiles("templates/index.html")
+	atts, err := api.attachmentsByPost(ctx, res.Posts...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storageError(w, r, err)
 		return
 	}
 
-	tmpl.Execute(w, posts)
+	data := storage.PageData{Posts: res.Posts, Attachments: atts}
+	data.PrevURL, data.NextURL = pageLinks(r, page, res)
+	api.render(w, r, "index.html", data)
+}
+
+// HTML-страницы листаются по номерам страниц (?page=N), а не курсором.
+// pageNumber разбирает номер страницы и выставляет соответствующее смещение в q.
+func pageNumber(r *http.Request, q *storage.PostsQuery) (int, error) {
+	page := 1
+	if v := r.URL.Query().Get("page"); v != "" {
+		var err error
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, fmt.Errorf("неверный номер страницы: %q", v)
+		}
+	}
+	q.Cursor = ""
+	q.Offset = (page - 1) * q.PageSize()
+	return page, nil
+}
+
+// Ссылки на соседние HTML-страницы; пустые, если такой страницы нет.
+func pageLinks(r *http.Request, page int, res storage.PostsPage) (prev, next string) {
+	if page > 1 {
+		prev = pageURL(r, page-1)
+	}
+	if res.NextCursor != "" {
+		next = pageURL(r, page+1)
+	}
+	return prev, next
+}
+
+// Ссылка на страницу с сохранением остальных параметров запроса.
+func pageURL(r *http.Request, page int) string {
+	v := r.URL.Query()
+	v.Del("page")
+	if page > 1 {
+		v.Set("page", strconv.Itoa(page))
+	}
+	u := *r.URL
+	u.RawQuery = v.Encode()
+	return u.RequestURI()
 }
 
 // Получение маршрутизатора запросов.
@@ -108,214 +439,430 @@ func (api *API) Router() *mux.Router {
 	return api.router
 }
 
-// Получение всех публикаций.
-func (api *API) postsHandler(w http.ResponseWriter, r *http.Request) {
-	posts, err := api.db.Posts()
+// Разбор параметров выборки публикаций из строки запроса:
+// limit, offset, cursor, author, from, to (Unix-время или дата ГГГГ-ММ-ДД)
+// и sort (new - сначала новые, old - сначала старые).
+func parsePostsQuery(r *http.Request) (storage.PostsQuery, error) {
+	var q storage.PostsQuery
+	v := r.URL.Query()
+
+	var err error
+	for name, dst := range map[string]*int{"limit": &q.Limit, "offset": &q.Offset, "author": &q.AuthorID} {
+		if s := v.Get(name); s != "" {
+			if *dst, err = strconv.Atoi(s); err != nil {
+				return q, fmt.Errorf("неверное значение %s: %q", name, s)
+			}
+		}
+	}
+	for name, dst := range map[string]*int64{"from": &q.From, "to": &q.To} {
+		if s := v.Get(name); s != "" {
+			if *dst, err = parseTime(s); err != nil {
+				return q, fmt.Errorf("неверное значение %s: %q", name, s)
+			}
+		}
+	}
+
+	switch v.Get("sort") {
+	case "", "new":
+		q.Sort = storage.NewestFirst
+	case "old":
+		q.Sort = storage.OldestFirst
+	default:
+		return q, fmt.Errorf("неверный порядок сортировки: %q", v.Get("sort"))
+	}
+
+	q.Cursor = v.Get("cursor")
+	if err := q.Validate(); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// Разбор момента времени: Unix-время в секундах или дата в формате ГГГГ-ММ-ДД.
+func parseTime(s string) (int64, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// Получение страницы публикаций вместе с курсором следующей страницы.
+func (api *API) postsPageHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parsePostsQuery(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	bytes, err := json.Marshal(posts)
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	page, err := api.db.Posts(ctx, q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storageError(w, r, err)
 		return
 	}
-	w.Write(bytes)
+	respond(w, r, http.StatusOK, page)
 }
 
-// Добавление публикации.
-func (api *API) addPostHandler(w http.ResponseWriter, r *http.Request) {
-	// Парсим данные формы
-	err := r.ParseForm()
+// Получение публикации по ID.
+func (api *API) postHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
 	if err != nil {
-		http.Error(w, "Ошибка парсинга формы", http.StatusInternalServerError)
+		jsonError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Извлекаем значения из формы
-	title := r.FormValue("title")
-	content := r.FormValue("content")
-	authorID := r.FormValue("author_id")
+	ctx, cancel := api.context(r)
+	defer cancel()
 
-	// Проверяем, что authorID не пустой
-	if authorID == "" {
-		http.Error(w, "Автор не выбран", http.StatusBadRequest)
+	p, err := api.db.Post(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, p)
+}
+
+// Страница публикации.
+func (api *API) postPageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	p, err := api.db.Post(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	atts, err := api.attachmentsByPost(ctx, p)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	api.render(w, r, "post.html", storage.PageData{Post: p, Attachments: atts})
+}
+
+// Получение публикаций без метаданных страницы.
+// Оставлено для совместимости: возвращает одну страницу с теми же параметрами, что и /posts.
+func (api *API) postsHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parsePostsQuery(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	fmt.Println("Заголовок:", title)
-	fmt.Println("Контент:", content)
-	fmt.Println("Автор:", authorID)
+	ctx, cancel := api.context(r)
+	defer cancel()
 
-	// Преобразуем authorID в int
-	authorIDInt, err := strconv.Atoi(authorID)
+	page, err := api.db.Posts(ctx, q)
 	if err != nil {
-		http.Error(w, "Неверный формат author_id", http.StatusBadRequest)
+		storageError(w, r, err)
 		return
 	}
+	respond(w, r, http.StatusOK, page.Posts)
+}
+
+// Добавление публикации от имени вошедшего пользователя.
+func (api *API) addPostHandler(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, can(r, rbac.CreatePost)) {
+		return
+	}
+	acc, _ := currentAccount(r)
 
 	// Создаем новый объект Post
 	p := storage.Post{
-		Title:     title,
-		Content:   content,
-		AuthorID:  authorIDInt,       // Используем преобразованный int
+		Title:     r.FormValue("title"),
+		Content:   r.FormValue("content"),
+		AuthorID:  acc.AuthorID,
 		CreatedAt: time.Now().Unix(), // Устанавливаем текущую метку времени
 	}
 
+	ctx, cancel := api.context(r)
+	defer cancel()
+
 	// Добавляем публикацию в базу данных
-	err = api.db.AddPost(p)
+	p, err := api.createPost(ctx, p)
+	if formErrors(err) != nil {
+		api.formError(w, r, "add_post.html", http.StatusUnprocessableEntity, storage.PageData{}, err)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storageError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	// Вложения сохраняем после публикации: им нужен её ID.
+	// Если файл не подошёл, публикацию удаляем, чтобы форму можно было отправить заново
+	if r.MultipartForm != nil {
+		_, err := api.addAttachments(ctx, p.ID, 0, r.MultipartForm.File[attachmentsFormField])
+		if err != nil {
+			if err := api.deletePost(ctx, p); err != nil {
+				log.Printf("Ошибка удаления публикации %d: %v", p.ID, err)
+			}
+			err = attachmentsFormError(err)
+			if formErrors(err) != nil {
+				api.formError(w, r, "add_post.html", http.StatusUnprocessableEntity, storage.PageData{}, err)
+				return
+			}
+			storageError(w, r, err)
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-// для html
-func (api *API) addPostPageHandler(w http.ResponseWriter, r *http.Request) {
-	// Загружаем список авторов
-	authors, err := api.db.GetAuthors()
+// Проверка и сохранение новой публикации.
+// Возвращает сохранённую публикацию с присвоенным ID и заполненным автором.
+func (api *API) createPost(ctx context.Context, p storage.Post) (storage.Post, error) {
+	a, err := api.checkPost(ctx, p)
+	if err != nil {
+		return storage.Post{}, err
+	}
+	if p.CreatedAt == 0 {
+		p.CreatedAt = time.Now().Unix()
+	}
+
+	p.ID, err = api.db.AddPost(ctx, p)
 	if err != nil {
-		http.Error(w, "Ошибка загрузки авторов", http.StatusInternalServerError)
+		return storage.Post{}, err
+	}
+	p.Author = a
+	p.FormattedDate = storage.FormatDate(p.CreatedAt)
+	return p, nil
+}
+
+// Запрос на создание или изменение публикации через JSON API.
+// Без author_id публикация создаётся от имени вошедшего пользователя
+// или остаётся у прежнего автора.
+type postRequest struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	AuthorID int    `json:"author_id"`
+}
+
+// Создание публикации из JSON.
+// Отвечает 201 с созданной публикацией и её адресом в заголовке Location.
+func (api *API) createPostHandler(w http.ResponseWriter, r *http.Request) {
+	var req postRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		jsonError(w, r, http.StatusBadRequest, "Неверный JSON: "+err.Error())
+		return
+	}
+
+	acc, _ := currentAccount(r)
+	if req.AuthorID == 0 {
+		req.AuthorID = acc.AuthorID
+	}
+	// От имени другого автора публикуют только те, кто может менять чужие публикации
+	if !allow(w, r, can(r, rbac.CreatePost) && canEditPost(r, req.AuthorID)) {
 		return
 	}
 
-	// Создаём объект данных для шаблона
-	data := storage.PageData{Authors: authors}
+	ctx, cancel := api.context(r)
+	defer cancel()
 
-	// Парсим и рендерим шаблон
-	tmpl, err := template.ParseFiles("templates/add_post.html")
+	p, err := api.createPost(ctx, storage.Post{
+		Title:    req.Title,
+		Content:  req.Content,
+		AuthorID: req.AuthorID,
+	})
 	if err != nil {
-		http.Error(w, "Ошибка загрузки страницы", http.StatusInternalServerError)
+		storageError(w, r, err)
 		return
 	}
 
-	tmpl.Execute(w, data)
+	w.Header().Set("Location", apiPath(r, "/posts/"+strconv.Itoa(p.ID)))
+	respond(w, r, http.StatusCreated, p)
 }
 
-func (api *API) updatePostHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r) // Получаем параметры из URL
-	idStr := vars["id"] // Получаем ID как строку
-
-	// Преобразуем строку ID в int
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Неверный ID", http.StatusBadRequest)
+// для html
+func (api *API) addPostPageHandler(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, can(r, rbac.CreatePost)) {
 		return
 	}
+	api.render(w, r, "add_post.html", storage.PageData{})
+}
 
-	var p storage.Post
-	err = json.NewDecoder(r.Body).Decode(&p)
+// Страница редактирования публикации с полями, заполненными из хранилища.
+func (api *API) editPostPageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	p.ID = id // Присваиваем ID из URL
-	err = api.db.UpdatePost(p)
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	data, err := api.editPostData(ctx, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storageError(w, r, err)
+		return
+	}
+	if !allow(w, r, canEditPost(r, data.Post.AuthorID)) {
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	api.render(w, r, "edit_post.html", data)
 }
 
-// Удаление публикации.
-func (api *API) deletePostHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r) // Получаем параметры из URL
-	idStr := vars["id"] // Получаем ID из URL
+// Данные формы редактирования публикации: сама публикация и её вложения.
+func (api *API) editPostData(ctx context.Context, id int) (storage.PageData, error) {
+	p, err := api.db.Post(ctx, id)
+	if err != nil {
+		return storage.PageData{}, err
+	}
+	atts, err := api.attachmentsByPost(ctx, p)
+	if err != nil {
+		return storage.PageData{}, err
+	}
+	return storage.PageData{Post: p, Attachments: atts}, nil
+}
 
-	// Преобразуем строку в целое число
-	id, err := strconv.Atoi(idStr)
+// Обработка формы редактирования публикации: новые заголовок и текст
+// и дополнительные вложения. Дата создания остаётся прежней.
+func (api *API) editPostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
 	if err != nil {
-		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = api.db.DeletePost(storage.Post{ID: id}) // Передаем ID для удаления
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	data, err := api.editPostData(ctx, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storageError(w, r, err)
+		return
+	}
+	if !allow(w, r, canEditPost(r, data.Post.AuthorID)) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-}
-
-// обработка фоток
-// Добавление пользователя с аватаркой
-func (api *API) addUserHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	acc, _ := currentAccount(r)
+	p := data.Post
+	p.Title, p.Content = r.FormValue("title"), r.FormValue("content")
+	p.UpdatedAt, p.UpdatedBy = time.Now().Unix(), acc.AuthorID
+	p.Author, err = api.checkPost(ctx, p)
+	if formErrors(err) != nil {
+		api.formError(w, r, "edit_post.html", http.StatusUnprocessableEntity, data, err)
+		return
+	}
+	if err != nil {
+		storageError(w, r, err)
 		return
 	}
 
-	// Получаем имя пользователя
-	name := r.FormValue("name")
-	if name == "" {
-		http.Error(w, "Имя не может быть пустым", http.StatusBadRequest)
+	// Новые вложения сохраняем до публикации: если файл не подошёл,
+	// публикация остаётся прежней, а форму можно отправить заново
+	var added []storage.Attachment
+	if r.MultipartForm != nil {
+		added, err = api.addAttachments(ctx, id, len(data.Attachments[id]), r.MultipartForm.File[attachmentsFormField])
+		err = attachmentsFormError(err)
+		if formErrors(err) != nil {
+			api.formError(w, r, "edit_post.html", http.StatusUnprocessableEntity, data, err)
+			return
+		}
+		if err != nil {
+			storageError(w, r, err)
+			return
+		}
+	}
+	if err := api.db.UpdatePost(ctx, p); err != nil {
+		api.dropAttachments(ctx, id, added)
+		storageError(w, r, err)
 		return
 	}
 
-	// Получаем файл аватарки
-	file, header, err := r.FormFile("avatar")
+	http.Redirect(w, r, "/post/"+strconv.Itoa(id), http.StatusSeeOther)
+}
+
+// Изменение публикации владельцем или администратором.
+func (api *API) updatePostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
 	if err != nil {
-		http.Error(w, "Ошибка загрузки файла", http.StatusBadRequest)
+		jsonError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	defer file.Close()
 
-	// Определяем расширение файла
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
-		http.Error(w, "Неподдерживаемый формат (только JPG, PNG)", http.StatusBadRequest)
+	var req postRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		jsonError(w, r, http.StatusBadRequest, "Неверный JSON: "+err.Error())
 		return
 	}
 
-	// Формируем путь к файлу: av_имя.jpg
-	avatarPath := "static/avatars/av_" + name + ext
+	ctx, cancel := api.context(r)
+	defer cancel()
 
-	// Декодируем изображение
-	var img image.Image
-	if ext == ".png" {
-		img, err = png.Decode(file)
-	} else {
-		img, err = jpeg.Decode(file)
-	}
+	old, err := api.db.Post(ctx, id)
 	if err != nil {
-		http.Error(w, "Ошибка декодирования изображения", http.StatusInternalServerError)
+		storageError(w, r, err)
+		return
+	}
+	if req.AuthorID == 0 {
+		req.AuthorID = old.AuthorID
+	}
+	// Передать публикацию другому автору может только тот, кто может менять чужие публикации
+	if !allow(w, r, canEditPost(r, old.AuthorID) && canEditPost(r, req.AuthorID)) {
 		return
 	}
 
-	// Сжимаем до 32x32
-	img = resize.Resize(32, 32, img, resize.Lanczos3)
+	// Дата создания остаётся прежней, что бы ни прислал клиент
+	acc, _ := currentAccount(r)
+	p := old
+	p.Title, p.Content, p.AuthorID = req.Title, req.Content, req.AuthorID
+	p.UpdatedAt, p.UpdatedBy = time.Now().Unix(), acc.AuthorID
+	if p.Author, err = api.checkPost(ctx, p); err != nil {
+		storageError(w, r, err)
+		return
+	}
+	if err := api.db.UpdatePost(ctx, p); err != nil {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, p)
+}
 
-	// Создаём файл для сохранения
-	outFile, err := os.Create(avatarPath)
+// Удаление публикации владельцем или администратором.
+func (api *API) deletePostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
 	if err != nil {
-		http.Error(w, "Ошибка сохранения файла", http.StatusInternalServerError)
+		jsonError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	defer outFile.Close()
 
-	// Кодируем и сохраняем
-	if ext == ".png" {
-		err = png.Encode(outFile, img)
-	} else {
-		err = jpeg.Encode(outFile, img, nil)
-	}
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	p, err := api.db.Post(ctx, id)
 	if err != nil {
-		http.Error(w, "Ошибка кодирования изображения", http.StatusInternalServerError)
+		storageError(w, r, err)
+		return
+	}
+	if !allow(w, r, canEditPost(r, p.AuthorID)) {
 		return
 	}
 
-	// Добавляем пользователя в БД
-	err = api.db.AddAuthor(storage.Author{Name: name, AvatarURL: "/" + avatarPath})
+	err = api.deletePost(ctx, p) // Удаляем вместе с файлами вложений
 	if err != nil {
-		http.Error(w, "Ошибка сохранения пользователя", http.StatusInternalServerError)
+		storageError(w, r, err)
 		return
 	}
 
-	// Успех
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	respond(w, r, http.StatusOK, nil)
 }
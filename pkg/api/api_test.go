@@ -0,0 +1,182 @@
+package api
+
+import (
+	"GoNews/pkg/media"
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"GoNews/pkg/storage/memdb"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testServer - API над пустой БД в памяти.
+type testServer struct {
+	t   *testing.T
+	db  *memdb.Store
+	api *API
+}
+
+// newTestServer создаёт API с настройками opts. Шаблоны берутся из репозитория,
+// статические файлы пишутся во временный каталог.
+func newTestServer(t *testing.T, opts Options) *testServer {
+	t.Helper()
+	opts.Templates = filepath.Join("..", "..", "templates")
+	opts.Static = t.TempDir()
+	if opts.SessionSecret == "" {
+		opts.SessionSecret = "test-secret"
+	}
+	db := memdb.New()
+	return &testServer{t: t, db: db, api: New(db, opts)}
+}
+
+// do выполняет запрос через маршрутизатор API.
+func (s *testServer) do(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.api.Router().ServeHTTP(w, r)
+	return w
+}
+
+// account заводит автора login с учётной записью роли role.
+func (s *testServer) account(login string, role rbac.Role) storage.Account {
+	s.t.Helper()
+	ctx := context.Background()
+	id, err := s.db.AddAuthor(ctx, storage.Author{Name: login})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	acc := storage.Account{AuthorID: id, Login: login, PasswordHash: "hash-" + login, Role: role}
+	if err := s.db.AddAccount(ctx, acc); err != nil {
+		s.t.Fatal(err)
+	}
+	return acc
+}
+
+// post добавляет публикацию автора authorID и возвращает её ID.
+func (s *testServer) post(authorID int) int {
+	s.t.Helper()
+	id, err := s.db.AddPost(context.Background(), storage.Post{Title: "Заголовок", Content: "Текст", AuthorID: authorID, CreatedAt: 1700000000})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return id
+}
+
+// login добавляет к запросу cookie сессии учётной записи acc.
+func (s *testServer) login(r *http.Request, acc storage.Account) *http.Request {
+	w := httptest.NewRecorder()
+	s.api.sessions.issue(w, acc)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+// token выпускает API-токен учётной записи acc и возвращает заголовок Authorization с ним.
+func (s *testServer) token(acc storage.Account, scopes ...string) string {
+	s.t.Helper()
+	token, err := newToken()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	_, err = s.db.AddToken(context.Background(), storage.Token{
+		AuthorID: acc.AuthorID, Name: "test", Hash: hashToken(token), Scopes: scopes, CreatedAt: 1700000000,
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+// withCSRF добавляет к запросу CSRF-токен в cookie и заголовке.
+func withCSRF(r *http.Request) *http.Request {
+	token := newCSRFToken()
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+	r.Header.Set(csrfHeader, token)
+	return r
+}
+
+// decodeEnvelope разбирает ответ версионированного JSON API.
+func decodeEnvelope(t *testing.T, w *httptest.ResponseRecorder) envelope {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, ожидался JSON", ct)
+	}
+	var env envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatalf("ответ не JSON: %v: %s", err, w.Body)
+	}
+	return env
+}
+
+// wantError проверяет код ответа и ошибку в конверте JSON API.
+func wantError(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("код ответа %d, ожидался %d: %s", w.Code, status, w.Body)
+	}
+	env := decodeEnvelope(t, w)
+	if env.Error == nil || env.Error.Code != errorCode(status) || env.Error.Message == "" {
+		t.Errorf("ошибка в ответе %+v, ожидался код %q с сообщением", env.Error, errorCode(status))
+	}
+	if env.RequestID == "" || env.RequestID != w.Header().Get(requestIDHeader) {
+		t.Errorf("request_id = %q, заголовок %s = %q", env.RequestID, requestIDHeader, w.Header().Get(requestIDHeader))
+	}
+}
+
+// Заголовки ответа с файлом: загруженные пользователями файлы браузер
+// не должен угадывать по содержимому, а неизображения - открывать на сайте.
+func TestUploadHeaders(t *testing.T) {
+	tests := []struct {
+		path       string
+		body       string
+		nosniff    bool
+		attachment bool
+	}{
+		{"avatars/av_0123456789abcdef0123456789abcdef_32.png", "\x89PNG", true, false},
+		{"posts/1/0123456789abcdef0123456789abcdef.txt", "<script>alert(1)</script>", true, true},
+		{"posts/1/0123456789abcdef0123456789abcdef.pdf", "%PDF-1.4", true, true},
+		{"styles.css", "body {}", false, false},
+	}
+
+	mediaDir := t.TempDir()
+	static := newTestServer(t, Options{})
+	store := newTestServer(t, Options{Media: media.NewFS(mediaDir, mediaURL)})
+	for _, tt := range tests {
+		for _, dir := range []string{static.api.static, mediaDir} {
+			p := filepath.Join(dir, filepath.FromSlash(tt.path))
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, []byte(tt.body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, tt := range tests {
+		urls := map[string]*testServer{"/static/" + tt.path: static}
+		if tt.nosniff {
+			// из хранилища раздаются только загруженные файлы
+			urls[mediaURL+tt.path] = store
+		}
+		for url, srv := range urls {
+			w := srv.do(httptest.NewRequest(http.MethodGet, url, nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("GET %s: код %d", url, w.Code)
+				continue
+			}
+			if got := w.Header().Get("X-Content-Type-Options") == "nosniff"; got != tt.nosniff {
+				t.Errorf("GET %s: X-Content-Type-Options = %q", url, w.Header().Get("X-Content-Type-Options"))
+			}
+			if got := w.Header().Get("Content-Disposition") == "attachment"; got != tt.attachment {
+				t.Errorf("GET %s: Content-Disposition = %q", url, w.Header().Get("Content-Disposition"))
+			}
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package api
+
+import (
+	"GoNews/pkg/imaging"
+	"GoNews/pkg/media"
+	"GoNews/pkg/storage"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Префикс ключей вложений в хранилище файлов.
+const attachmentsKey = "posts/"
+
+// Ограничения на вложения публикаций.
+const (
+	maxAttachmentSize    = 8 << 20 // размер файла в байтах
+	maxPostAttachments   = 20      // вложений у одной публикации
+	maxAttachmentNameLen = 200     // длина имени файла в символах
+	attachmentThumbSize  = 256     // сторона квадратной уменьшенной копии изображения
+)
+
+// Поля с файлами: в форме добавления публикации и в запросе загрузки JSON API.
+const (
+	attachmentsFormField  = "attachments"
+	attachmentsUploadPart = "file"
+)
+
+// Допустимые типы вложений, определённые по содержимому файла, и расширения,
+// под которыми файлы сохраняются. Тип не берётся из имени файла: иначе в хранилище
+// мог бы попасть, например, HTML-файл, который браузер откроет как страницу сайта.
+var attachmentTypes = map[string]string{
+	"image/jpeg":                ".jpg",
+	"image/png":                 ".png",
+	"image/gif":                 ".gif",
+	"application/pdf":           ".pdf",
+	"application/zip":           ".zip",
+	"text/plain; charset=utf-8": ".txt",
+}
+
+// attachmentKey возвращает ключ нового файла вложения публикации postID.
+func attachmentKey(postID int, suffix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%d/%s%s", attachmentsKey, postID, hex.EncodeToString(b), suffix), nil
+}
+
+// attachmentName приводит имя загруженного файла к виду для показа:
+// без каталогов и управляющих символов, не длиннее maxAttachmentNameLen.
+func attachmentName(filename, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filepath.Base(strings.ReplaceAll(filename, "\\", "/")))
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		name = "file" + ext
+	}
+	if utf8.RuneCountInString(name) > maxAttachmentNameLen {
+		name = string([]rune(name)[:maxAttachmentNameLen])
+	}
+	return name
+}
+
+// saveAttachment проверяет загруженный файл, сохраняет его и, для изображений,
+// уменьшенную копию в хранилище файлов и добавляет вложение к публикации postID.
+func (api *API) saveAttachment(ctx context.Context, postID int, header *multipart.FileHeader) (storage.Attachment, error) {
+	tooLarge := &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Файл %q больше %d МБ", header.Filename, maxAttachmentSize>>20)}
+	if header.Size > maxAttachmentSize {
+		return storage.Attachment{}, tooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return storage.Attachment{}, &requestError{http.StatusBadRequest, "Ошибка загрузки файла"}
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+	if err != nil {
+		return storage.Attachment{}, &requestError{http.StatusBadRequest, "Ошибка загрузки файла"}
+	}
+	if len(data) > maxAttachmentSize {
+		return storage.Attachment{}, tooLarge
+	}
+
+	ctype := http.DetectContentType(data)
+	ext, ok := attachmentTypes[ctype]
+	if !ok {
+		return storage.Attachment{}, &requestError{http.StatusBadRequest,
+			fmt.Sprintf("Неподдерживаемый тип файла %q (изображения JPG, PNG, GIF, документы PDF, TXT, архивы ZIP)", header.Filename)}
+	}
+
+	att := storage.Attachment{
+		PostID:      postID,
+		Name:        attachmentName(header.Filename, ext),
+		ContentType: ctype,
+		Size:        int64(len(data)),
+		CreatedAt:   time.Now().Unix(),
+	}
+
+	// Для изображений готовим уменьшенную копию до сохранения файлов:
+	// испорченное изображение не должно попасть в хранилище
+	var thumb bytes.Buffer
+	var thumbFormat string
+	if strings.HasPrefix(ctype, "image/") {
+		images, format, err := imaging.Squares(data, []int{attachmentThumbSize}, maxAvatarPixels)
+		switch {
+		case errors.Is(err, imaging.ErrTooLarge):
+			return storage.Attachment{}, &requestError{http.StatusBadRequest, fmt.Sprintf("Слишком большое изображение %q", header.Filename)}
+		case err != nil:
+			return storage.Attachment{}, &requestError{http.StatusBadRequest, fmt.Sprintf("Ошибка декодирования изображения %q", header.Filename)}
+		}
+		if err := imaging.Encode(&thumb, images[0], format); err != nil {
+			return storage.Attachment{}, &requestError{http.StatusInternalServerError, "Ошибка кодирования изображения"}
+		}
+		thumbFormat = imaging.OutputFormat(format)
+	}
+
+	var keys []string
+	fail := func(err error) (storage.Attachment, error) {
+		api.deleteMedia(ctx, keys)
+		return storage.Attachment{}, err
+	}
+	key, err := attachmentKey(postID, ext)
+	if err != nil {
+		return fail(err)
+	}
+	if err := api.media.Put(ctx, key, bytes.NewReader(data), ctype); err != nil {
+		log.Printf("Ошибка сохранения вложения %s: %v", key, err)
+		return fail(&requestError{http.StatusInternalServerError, "Ошибка сохранения файла"})
+	}
+	keys = append(keys, key)
+	att.URL = api.media.URL(key)
+
+	if thumbFormat != "" {
+		thumbKey := strings.TrimSuffix(key, ext) + "_thumb" + avatarExts[thumbFormat]
+		if err := api.media.Put(ctx, thumbKey, &thumb, avatarMIMEType[thumbFormat]); err != nil {
+			log.Printf("Ошибка сохранения вложения %s: %v", thumbKey, err)
+			return fail(&requestError{http.StatusInternalServerError, "Ошибка сохранения файла"})
+		}
+		keys = append(keys, thumbKey)
+		att.ThumbnailURL = api.media.URL(thumbKey)
+	}
+
+	att.ID, err = api.db.AddAttachment(ctx, att)
+	if err != nil {
+		return fail(err)
+	}
+	return att, nil
+}
+
+// addAttachments сохраняет вложения публикации postID, у которой уже есть
+// existing вложений. При ошибке сохранённые в этом вызове вложения удаляются.
+func (api *API) addAttachments(ctx context.Context, postID, existing int, headers []*multipart.FileHeader) ([]storage.Attachment, error) {
+	if existing+len(headers) > maxPostAttachments {
+		return nil, invalidField(attachmentsFormField, fmt.Sprintf("у публикации может быть не больше %d вложений", maxPostAttachments))
+	}
+	var saved []storage.Attachment
+	for _, h := range headers {
+		att, err := api.saveAttachment(ctx, postID, h)
+		if err != nil {
+			api.dropAttachments(ctx, postID, saved)
+			return nil, err
+		}
+		saved = append(saved, att)
+	}
+	return saved, nil
+}
+
+// dropAttachments удаляет вложения публикации postID вместе с файлами,
+// записывая ошибки в журнал.
+func (api *API) dropAttachments(ctx context.Context, postID int, atts []storage.Attachment) {
+	for _, att := range atts {
+		if err := api.db.DeleteAttachment(ctx, postID, att.ID); err != nil {
+			log.Printf("Ошибка удаления вложения %d: %v", att.ID, err)
+		}
+	}
+	api.removeAttachmentFiles(ctx, atts)
+}
+
+// attachmentsFormError превращает ошибку в загруженном через форму файле
+// в ошибку поля attachments, чтобы показать её рядом с полем.
+func attachmentsFormError(err error) error {
+	var re *requestError
+	if errors.As(err, &re) && re.status < http.StatusInternalServerError {
+		return invalidField(attachmentsFormField, re.msg)
+	}
+	return err
+}
+
+// removeAttachmentFiles удаляет из хранилища файлы вложений и их уменьшенные копии.
+func (api *API) removeAttachmentFiles(ctx context.Context, atts []storage.Attachment) {
+	var keys []string
+	for _, att := range atts {
+		for _, url := range []string{att.URL, att.ThumbnailURL} {
+			if key, ok := media.KeyFromURL(api.media, url); ok && strings.HasPrefix(key, attachmentsKey) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	api.deleteMedia(ctx, keys)
+}
+
+// attachmentsByPost возвращает вложения публикаций, сгруппированные по ID публикации.
+func (api *API) attachmentsByPost(ctx context.Context, posts ...storage.Post) (map[int][]storage.Attachment, error) {
+	if len(posts) == 0 {
+		return nil, nil
+	}
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	atts, err := api.db.Attachments(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int][]storage.Attachment)
+	for _, att := range atts {
+		res[att.PostID] = append(res[att.PostID], att)
+	}
+	return res, nil
+}
+
+// deletePost удаляет публикацию вместе с файлами её вложений.
+func (api *API) deletePost(ctx context.Context, p storage.Post) error {
+	atts, err := api.db.Attachments(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	if err := api.db.DeletePost(ctx, p); err != nil {
+		return err
+	}
+	api.removeAttachmentFiles(ctx, atts)
+	return nil
+}
+
+// authorAttachments возвращает вложения всех публикаций автора.
+func (api *API) authorAttachments(ctx context.Context, authorID int) ([]storage.Attachment, error) {
+	var ids []int
+	q := storage.PostsQuery{AuthorID: authorID, Limit: storage.MaxLimit}
+	for {
+		page, err := api.db.Posts(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Posts {
+			ids = append(ids, p.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return api.db.Attachments(ctx, ids...)
+}
+
+// findAttachment ищет вложение по имени файла или ID.
+func findAttachment(atts []storage.Attachment, name string) (storage.Attachment, bool) {
+	for _, att := range atts {
+		if att.Name == name || strconv.Itoa(att.ID) == name {
+			return att, true
+		}
+	}
+	return storage.Attachment{}, false
+}
+
+// Вложения публикации.
+func (api *API) attachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	if _, err := api.db.Post(ctx, id); err != nil {
+		storageError(w, r, err)
+		return
+	}
+	atts, err := api.db.Attachments(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	if atts == nil {
+		atts = []storage.Attachment{}
+	}
+	respond(w, r, http.StatusOK, atts)
+}
+
+// Загрузка вложений публикации: multipart/form-data с файлами в поле file.
+// Отвечает 201 со списком добавленных вложений.
+func (api *API) uploadAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := parseForm(r); err != nil || r.MultipartForm == nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			jsonError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Запрос больше %d МБ", maxRequestBody>>20))
+			return
+		}
+		jsonError(w, r, http.StatusBadRequest, "Ожидается multipart/form-data с файлами в поле "+attachmentsUploadPart)
+		return
+	}
+	headers := r.MultipartForm.File[attachmentsUploadPart]
+	if len(headers) == 0 {
+		jsonError(w, r, http.StatusBadRequest, "Не передано ни одного файла в поле "+attachmentsUploadPart)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	p, err := api.db.Post(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	if !allow(w, r, canEditPost(r, p.AuthorID)) {
+		return
+	}
+	existing, err := api.db.Attachments(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	saved, err := api.addAttachments(ctx, id, len(existing), headers)
+	var re *requestError
+	if errors.As(err, &re) {
+		jsonError(w, r, re.status, re.msg)
+		return
+	}
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", apiPath(r, fmt.Sprintf("/posts/%d/attachments", id)))
+	respond(w, r, http.StatusCreated, saved)
+}
+
+// Удаление вложения публикации вместе с его файлами.
+func (api *API) deleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	attID, err := intParam(r, "attachment")
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	p, err := api.db.Post(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	if !allow(w, r, canEditPost(r, p.AuthorID)) {
+		return
+	}
+	atts, err := api.db.Attachments(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	var att storage.Attachment
+	for _, a := range atts {
+		if a.ID == attID {
+			att = a
+		}
+	}
+	if err := api.db.DeleteAttachment(ctx, id, attID); err != nil {
+		storageError(w, r, err)
+		return
+	}
+	api.removeAttachmentFiles(ctx, []storage.Attachment{att})
+	respond(w, r, http.StatusOK, nil)
+}
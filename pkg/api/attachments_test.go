@@ -0,0 +1,255 @@
+package api
+
+import (
+	"GoNews/pkg/media"
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// uploadFile - файл в запросе загрузки вложений.
+type uploadFile struct {
+	name string
+	data []byte
+}
+
+// upload загружает файлы во вложения публикации postID с заголовком Authorization auth.
+func (s *testServer) upload(postID int, auth string, files ...uploadFile) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range files {
+		fw, err := mw.CreateFormFile(attachmentsUploadPart, f.name)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		fw.Write(f.data)
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/posts/%d/attachments", postID), &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.Header.Set("Authorization", auth)
+	return s.do(r)
+}
+
+// stored сообщает, есть ли в хранилище файл с адресом url.
+func (s *testServer) stored(url string) bool {
+	s.t.Helper()
+	key, ok := media.KeyFromURL(s.api.media, url)
+	if !ok {
+		s.t.Fatalf("адрес %q не из хранилища", url)
+	}
+	rc, _, err := s.api.media.Get(context.Background(), key)
+	if errors.Is(err, media.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	rc.Close()
+	return true
+}
+
+// attachments возвращает вложения публикации postID из БД.
+func (s *testServer) attachments(postID int) []storage.Attachment {
+	s.t.Helper()
+	atts, err := s.db.Attachments(context.Background(), postID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return atts
+}
+
+func TestUploadAttachments(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	auth := s.token(alice, scopePostsWrite)
+	id := s.post(alice.AuthorID)
+
+	w := s.upload(id, auth, uploadFile{"../фото.png", pngImage(t, 300)}, uploadFile{"notes.txt", []byte("заметки")})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("загрузка: код %d: %s", w.Code, w.Body)
+	}
+	if want := fmt.Sprintf("/api/v1/posts/%d/attachments", id); w.Header().Get("Location") != want {
+		t.Errorf("Location = %q, ожидался %q", w.Header().Get("Location"), want)
+	}
+	atts := s.attachments(id)
+	if len(atts) != 2 {
+		t.Fatalf("вложений после загрузки: %d", len(atts))
+	}
+	img, txt := atts[0], atts[1]
+	if img.Name != "фото.png" || img.ContentType != "image/png" || img.ThumbnailURL == "" {
+		t.Errorf("изображение: %+v", img)
+	}
+	if txt.Name != "notes.txt" || txt.ContentType != "text/plain; charset=utf-8" || txt.ThumbnailURL != "" || txt.Size != int64(len("заметки")) {
+		t.Errorf("текст: %+v", txt)
+	}
+	for _, url := range []string{img.URL, img.ThumbnailURL, txt.URL} {
+		if !s.stored(url) {
+			t.Errorf("файл %s не сохранён", url)
+		}
+	}
+
+	// тип берётся из содержимого, а не из имени: SVG сохраняется как текст
+	w = s.upload(id, auth, uploadFile{"image.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"/>`)})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("загрузка SVG: код %d: %s", w.Code, w.Body)
+	}
+	svg := s.attachments(id)[2]
+	if svg.ContentType != "text/plain; charset=utf-8" || !strings.HasSuffix(svg.URL, ".txt") {
+		t.Errorf("SVG сохранён как %q по адресу %s", svg.ContentType, svg.URL)
+	}
+	s.api.dropAttachments(context.Background(), id, []storage.Attachment{svg})
+
+	// список доступен без входа
+	w = s.do(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/posts/%d/attachments", id), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("список вложений: код %d", w.Code)
+	}
+	raw, _ := json.Marshal(decodeEnvelope(t, w).Data)
+	var listed []storage.Attachment
+	if err := json.Unmarshal(raw, &listed); err != nil || len(listed) != 2 || listed[0] != img || listed[1] != txt {
+		t.Errorf("список вложений %s, ожидалось %+v", raw, atts)
+	}
+	w = s.do(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/posts/%d/attachments", s.post(alice.AuthorID)), nil))
+	if raw, _ := json.Marshal(decodeEnvelope(t, w).Data); string(raw) != "[]" {
+		t.Errorf("список вложений публикации без них: %s", raw)
+	}
+	wantError(t, s.do(httptest.NewRequest(http.MethodGet, "/api/v1/posts/4242/attachments", nil)), http.StatusNotFound)
+}
+
+func TestUploadAttachmentsLimits(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	auth := s.token(alice, scopePostsWrite)
+	id := s.post(alice.AuthorID)
+
+	big := make([]byte, maxAttachmentSize+1)
+	copy(big, "текст")
+	many := make([]uploadFile, maxPostAttachments+1)
+	for i := range many {
+		many[i] = uploadFile{"f" + strconv.Itoa(i) + ".txt", []byte("текст")}
+	}
+	tests := []struct {
+		name   string
+		files  []uploadFile
+		status int
+	}{
+		{"без файлов", nil, http.StatusBadRequest},
+		{"HTML", []uploadFile{{"page.txt", []byte("<!DOCTYPE html><script>alert(1)</script>")}}, http.StatusBadRequest},
+		{"исполняемый файл", []uploadFile{{"setup.exe", []byte("MZ\x90\x00\x03\x00\x00\x00")}}, http.StatusBadRequest},
+		{"испорченное изображение", []uploadFile{{"a.png", pngImage(t, 8)[:40]}}, http.StatusBadRequest},
+		{"больше допустимого", []uploadFile{{"big.txt", big}}, http.StatusRequestEntityTooLarge},
+		{"больше запроса", []uploadFile{{"a.txt", big}, {"b.txt", big}}, http.StatusRequestEntityTooLarge},
+		{"слишком много", many, http.StatusUnprocessableEntity},
+		{"второй файл неверный", []uploadFile{{"a.png", pngImage(t, 64)}, {"b.html", []byte("<html></html>")}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantError(t, s.upload(id, auth, tt.files...), tt.status)
+			if atts := s.attachments(id); len(atts) != 0 {
+				t.Errorf("вложений после отказа: %+v", atts)
+			}
+		})
+	}
+	// файлы отклонённых загрузок в хранилище не остаются
+	var files []string
+	err := filepath.WalkDir(filepath.Join(s.api.static, attachmentsKey), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("файлы отклонённых вложений: %v", files)
+	}
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	bob := s.account("bob", rbac.Author)
+	editor := s.account("editor", rbac.Editor)
+	id := s.post(alice.AuthorID)
+
+	if w := s.upload(id, s.token(alice, scopePostsWrite), uploadFile{"a.png", pngImage(t, 64)}, uploadFile{"b.txt", []byte("текст")}); w.Code != http.StatusCreated {
+		t.Fatalf("загрузка: код %d: %s", w.Code, w.Body)
+	}
+	atts := s.attachments(id)
+
+	remove := func(acc storage.Account, attID int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/posts/%d/attachments/%d", id, attID), nil)
+		r.Header.Set("Authorization", s.token(acc, scopePostsWrite))
+		return s.do(r)
+	}
+
+	// чужую публикацию автор не меняет: ни удалить, ни загрузить
+	wantError(t, remove(bob, atts[0].ID), http.StatusForbidden)
+	wantError(t, s.upload(id, s.token(bob, scopePostsWrite), uploadFile{"c.txt", []byte("текст")}), http.StatusForbidden)
+	if got := s.attachments(id); len(got) != 2 || !s.stored(atts[0].URL) {
+		t.Fatalf("вложения после отказа: %+v", got)
+	}
+	// токен без области posts:write
+	r := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/posts/%d/attachments/%d", id, atts[0].ID), nil)
+	r.Header.Set("Authorization", s.token(alice))
+	wantError(t, s.do(r), http.StatusForbidden)
+
+	if w := remove(alice, atts[0].ID); w.Code != http.StatusOK {
+		t.Fatalf("удаление своего вложения: код %d: %s", w.Code, w.Body)
+	}
+	if s.stored(atts[0].URL) || s.stored(atts[0].ThumbnailURL) {
+		t.Error("файлы удалённого вложения остались в хранилище")
+	}
+	wantError(t, remove(alice, atts[0].ID), http.StatusNotFound)
+
+	// редактор удаляет вложения чужих публикаций
+	if w := remove(editor, atts[1].ID); w.Code != http.StatusOK {
+		t.Fatalf("удаление вложения редактором: код %d: %s", w.Code, w.Body)
+	}
+	if s.stored(atts[1].URL) || len(s.attachments(id)) != 0 {
+		t.Error("вложение не удалено редактором")
+	}
+}
+
+// Файлы вложений удаляются вместе с публикацией.
+func TestDeletePostAttachments(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	auth := s.token(alice, scopePostsWrite)
+	id := s.post(alice.AuthorID)
+	other := s.post(alice.AuthorID)
+
+	for _, postID := range []int{id, other} {
+		if w := s.upload(postID, auth, uploadFile{"a.png", pngImage(t, 64)}); w.Code != http.StatusCreated {
+			t.Fatalf("загрузка: код %d: %s", w.Code, w.Body)
+		}
+	}
+	att, kept := s.attachments(id)[0], s.attachments(other)[0]
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/v1/posts/"+strconv.Itoa(id), nil)
+	r.Header.Set("Authorization", auth)
+	if w := s.do(r); w.Code != http.StatusOK {
+		t.Fatalf("удаление публикации: код %d: %s", w.Code, w.Body)
+	}
+	if s.stored(att.URL) || s.stored(att.ThumbnailURL) {
+		t.Error("файлы вложений удалённой публикации остались в хранилище")
+	}
+	if !s.stored(kept.URL) || !s.stored(kept.ThumbnailURL) {
+		t.Error("удалены файлы вложений другой публикации")
+	}
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// normalizeLogin приводит логин к виду, в котором он хранится.
+func normalizeLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+// Хеш для сравнения, когда логин не найден: время ответа не выдаёт,
+// существует ли учётная запись.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("gonews"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// safeNext возвращает адрес для перехода после входа: только пути этого сайта.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// Форма входа.
+func (api *API) loginPageHandler(w http.ResponseWriter, r *http.Request) {
+	api.render(w, r, "login.html", storage.PageData{Next: safeNext(r.URL.Query().Get("next"))})
+}
+
+// Вход по логину и паролю.
+func (api *API) loginHandler(w http.ResponseWriter, r *http.Request) {
+	login := normalizeLogin(r.FormValue("login"))
+	password := r.FormValue("password")
+	next := safeNext(r.FormValue("next"))
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	acc, err := api.db.AccountByLogin(ctx, login)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		storageError(w, r, err)
+		return
+	}
+	hash := []byte(acc.PasswordHash)
+	if err != nil {
+		hash = dummyHash()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		api.render(w, r, "login.html", storage.PageData{Message: "Неверный логин или пароль", Next: next})
+		return
+	}
+
+	api.sessions.issue(w, acc)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// Выход. Отзывает все сессии учётной записи, а не только текущую cookie:
+// её копия, например с другого устройства, тоже перестаёт действовать.
+func (api *API) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if acc, ok := currentAccount(r); ok {
+		ctx, cancel := api.context(r)
+		defer cancel()
+		if err := api.db.RevokeSessions(ctx, acc.AuthorID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Ошибка отзыва сессий автора %d: %v", acc.AuthorID, err)
+		}
+	}
+	api.sessions.clear(w)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Форма регистрации.
+// Вошедший пользователь уже привязан к автору; других авторов заводит только администратор.
+func (api *API) addUserPageHandler(w http.ResponseWriter, r *http.Request) {
+	if acc, ok := currentAccount(r); ok && !acc.Role.Can(rbac.ManageAuthors) {
+		http.Redirect(w, r, fmt.Sprintf("/author/%d", acc.AuthorID), http.StatusSeeOther)
+		return
+	}
+	api.render(w, r, "add_user.html", storage.PageData{})
+}
+
+// обработка фоток
+// Регистрация: автор с аватаркой и его учётная запись.
+// Новая учётная запись получает роль по умолчанию; первого администратора
+// создаёт команда create-admin (CreateAdmin). Администратор может сразу
+// назначить роль заводимому пользователю.
+func (api *API) addUserHandler(w http.ResponseWriter, r *http.Request) {
+	current, loggedIn := currentAccount(r)
+	if loggedIn && !current.Role.Can(rbac.ManageAuthors) {
+		http.Error(w, "Вы уже зарегистрированы", http.StatusForbidden)
+		return
+	}
+	role := api.role
+	if v := r.FormValue("role"); v != "" && current.Role.Can(rbac.ManageRoles) {
+		var err error
+		if role, err = rbac.Parse(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Получаем имя пользователя
+	name := strings.TrimSpace(r.FormValue("name"))
+	login := normalizeLogin(r.FormValue("login"))
+	password := r.FormValue("password")
+	if err := validateSignup(name, login, password); err != nil {
+		api.formError(w, r, "add_user.html", http.StatusUnprocessableEntity, storage.PageData{}, err)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	// Проверяем логин до сохранения аватарки
+	if _, err := api.db.AccountByLogin(ctx, login); err == nil {
+		api.formError(w, r, "add_user.html", http.StatusConflict, storage.PageData{}, invalidField("login", "логин уже занят"))
+		return
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		storageError(w, r, err)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Ошибка обработки пароля", http.StatusInternalServerError)
+		return
+	}
+
+	// Получаем файл аватарки
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		http.Error(w, "Ошибка загрузки файла", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	avatarURL, err := api.saveAvatar(ctx, file, header)
+	if err != nil {
+		requestErrorResponse(w, err)
+		return
+	}
+
+	// Добавляем пользователя в БД
+	id, err := api.db.AddAuthor(ctx, storage.Author{Name: name, AvatarURL: avatarURL})
+	if err != nil {
+		api.removeAvatar(ctx, avatarURL)
+		http.Error(w, "Ошибка сохранения пользователя", storageStatus(err))
+		return
+	}
+	acc := storage.Account{
+		AuthorID:     id,
+		Login:        login,
+		PasswordHash: string(hash),
+		Role:         role,
+	}
+	err = api.db.AddAccount(ctx, acc)
+	if err != nil {
+		// Хранилища не поддерживают общих транзакций: убираем созданного автора
+		if err := api.deleteAuthor(ctx, id, storage.DeleteAuthorOptions{}); err != nil {
+			log.Printf("Ошибка удаления автора %d после неудачной регистрации: %v", id, err)
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			http.Error(w, "Логин уже занят", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Ошибка сохранения пользователя", storageStatus(err))
+		return
+	}
+
+	// Администратор заводит чужую учётную запись и остаётся в своей
+	if !loggedIn {
+		api.sessions.issue(w, acc)
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// CreateAdmin заводит автора name с учётной записью администратора login
+// и возвращает ID автора. Так создаётся первый администратор: регистрация
+// через веб-интерфейс даёт только роль по умолчанию.
+func CreateAdmin(ctx context.Context, db storage.Interface, name, login, password string) (int, error) {
+	name, login = strings.TrimSpace(name), normalizeLogin(login)
+	if err := validateSignup(name, login, password); err != nil {
+		return 0, err
+	}
+	if _, err := db.AccountByLogin(ctx, login); err == nil {
+		return 0, fmt.Errorf("логин %q занят: %w", login, storage.ErrConflict)
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return 0, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := db.AddAuthor(ctx, storage.Author{Name: name})
+	if err != nil {
+		return 0, err
+	}
+	err = db.AddAccount(ctx, storage.Account{AuthorID: id, Login: login, PasswordHash: string(hash), Role: rbac.Admin})
+	if err != nil {
+		// Хранилища не поддерживают общих транзакций: убираем созданного автора
+		if err := db.DeleteAuthor(ctx, id, storage.DeleteAuthorOptions{}); err != nil {
+			log.Printf("Ошибка удаления автора %d после неудачного создания администратора: %v", id, err)
+		}
+		return 0, err
+	}
+	return id, nil
+}
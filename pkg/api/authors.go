@@ -0,0 +1,388 @@
+package api
+
+import (
+	"GoNews/pkg/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Получение всех авторов.
+func (api *API) authorsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	authors, err := api.db.GetAuthors(ctx)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	if authors == nil {
+		authors = []storage.Author{}
+	}
+	respond(w, r, http.StatusOK, authors)
+}
+
+// Получение автора по ID.
+func (api *API) authorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	a, err := api.db.GetAuthorByID(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, a)
+}
+
+// Получение страницы публикаций автора.
+// Принимает те же параметры выборки, что и /posts, кроме author.
+func (api *API) authorPostsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	q, err := parsePostsQuery(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	q.AuthorID = id
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	// У несуществующего автора нет и публикаций: отвечаем 404, а не пустой страницей
+	if _, err := api.db.GetAuthorByID(ctx, id); err != nil {
+		storageError(w, r, err)
+		return
+	}
+	page, err := api.db.Posts(ctx, q)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, page)
+}
+
+// Страница автора со списком его публикаций.
+func (api *API) authorPageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q, err := parsePostsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.AuthorID = id
+	page, err := pageNumber(r, &q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	a, err := api.db.GetAuthorByID(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	res, err := api.db.Posts(ctx, q)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+
+	atts, err := api.attachmentsByPost(ctx, res.Posts...)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+
+	data := storage.PageData{Author: a, Posts: res.Posts, Attachments: atts}
+	data.PrevURL, data.NextURL = pageLinks(r, page, res)
+	api.render(w, r, "author.html", data)
+}
+
+// Запрос на изменение автора через JSON API. Пустые поля не меняются.
+type authorRequest struct {
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// Изменение имени или аватарки автора.
+func (api *API) updateAuthorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !allow(w, r, canEditAuthor(r, id)) {
+		return
+	}
+	var req authorRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		jsonError(w, r, http.StatusBadRequest, "Неверный JSON: "+err.Error())
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	a, err := api.db.GetAuthorByID(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	oldAvatar := a.AvatarURL
+	if req.Name != "" {
+		a.Name = strings.TrimSpace(req.Name)
+	}
+	if req.AvatarURL != "" && req.AvatarURL != a.AvatarURL {
+		// Файлы каталога аватарок удаляются вместе с автором: чужой файл указать нельзя
+		if _, ok := api.avatarKey(req.AvatarURL); ok {
+			storageError(w, r, invalidField("avatar_url", "загруженную аватарку можно сменить только через форму профиля"))
+			return
+		}
+		a.AvatarURL = req.AvatarURL
+	}
+	if err := validateAuthor(a); err != nil {
+		storageError(w, r, err)
+		return
+	}
+
+	if err := api.db.UpdateAuthor(ctx, a); err != nil {
+		storageError(w, r, err)
+		return
+	}
+	// Загруженная ранее аватарка больше никому не нужна
+	if a.AvatarURL != oldAvatar {
+		api.removeAvatar(ctx, oldAvatar)
+	}
+	respond(w, r, http.StatusOK, a)
+}
+
+// Разбор режима удаления автора: posts=restrict|cascade|reassign и to=ID нового автора.
+func parseDeleteOptions(mode, to string) (storage.DeleteAuthorOptions, error) {
+	var opts storage.DeleteAuthorOptions
+	switch mode {
+	case "", "restrict":
+		opts.Posts = storage.RestrictPosts
+	case "cascade":
+		opts.Posts = storage.CascadePosts
+	case "reassign":
+		opts.Posts = storage.ReassignPosts
+		id, err := strconv.Atoi(to)
+		if err != nil || id <= 0 {
+			return opts, fmt.Errorf("неверный ID нового автора публикаций: %q", to)
+		}
+		opts.ReassignTo = id
+	default:
+		return opts, fmt.Errorf("неизвестный режим удаления: %q (restrict, cascade или reassign)", mode)
+	}
+	return opts, nil
+}
+
+// Удаление автора вместе с его аватаркой, а при удалении публикаций -
+// и с файлами их вложений. Последнего администратора хранилище не удаляет
+// (storage.ErrLastAdmin, ответ 409).
+func (api *API) deleteAuthor(ctx context.Context, id int, opts storage.DeleteAuthorOptions) error {
+	a, err := api.db.GetAuthorByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	var atts []storage.Attachment
+	if opts.Posts == storage.CascadePosts {
+		if atts, err = api.authorAttachments(ctx, id); err != nil {
+			return err
+		}
+	}
+	if err := api.db.DeleteAuthor(ctx, id, opts); err != nil {
+		return err
+	}
+	api.removeAvatar(ctx, a.AvatarURL)
+	api.removeAttachmentFiles(ctx, atts)
+	return nil
+}
+
+// Удаление автора через JSON API.
+// Параметр posts задаёт судьбу его публикаций: restrict (по умолчанию) - отказать,
+// если они есть; cascade - удалить; reassign - передать автору из параметра to.
+func (api *API) deleteAuthorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !allow(w, r, canEditAuthor(r, id)) {
+		return
+	}
+	opts, err := parseDeleteOptions(r.URL.Query().Get("posts"), r.URL.Query().Get("to"))
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	if err := api.deleteAuthor(ctx, id, opts); err != nil {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, nil)
+}
+
+// Форма редактирования автора.
+func (api *API) editAuthorPageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !allow(w, r, canEditAuthor(r, id)) {
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	data, err := api.editAuthorData(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	api.render(w, r, "edit_user.html", data)
+}
+
+// Данные формы редактирования автора.
+// Остальные авторы - кандидаты на передачу публикаций при удалении.
+func (api *API) editAuthorData(ctx context.Context, id int) (storage.PageData, error) {
+	a, err := api.db.GetAuthorByID(ctx, id)
+	if err != nil {
+		return storage.PageData{}, err
+	}
+	authors, err := api.db.GetAuthors(ctx)
+	if err != nil {
+		return storage.PageData{}, err
+	}
+	others := make([]storage.Author, 0, len(authors))
+	for _, other := range authors {
+		if other.ID != id {
+			others = append(others, other)
+		}
+	}
+	return storage.PageData{Author: a, Authors: others}, nil
+}
+
+// Обработка формы редактирования автора: новое имя и, если загружена, новая аватарка.
+func (api *API) editAuthorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !allow(w, r, canEditAuthor(r, id)) {
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	data, err := api.editAuthorData(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	a := data.Author
+	oldAvatar := a.AvatarURL
+	a.Name = name
+	if err := validateAuthor(a); err != nil {
+		api.formError(w, r, "edit_user.html", http.StatusUnprocessableEntity, data, err)
+		return
+	}
+
+	// Аватарка необязательна: без файла оставляем прежнюю
+	file, header, err := r.FormFile("avatar")
+	switch {
+	case err == nil:
+		defer file.Close()
+		a.AvatarURL, err = api.saveAvatar(ctx, file, header)
+		if err != nil {
+			requestErrorResponse(w, err)
+			return
+		}
+	case !errors.Is(err, http.ErrMissingFile):
+		http.Error(w, "Ошибка загрузки файла", http.StatusBadRequest)
+		return
+	}
+
+	if err := api.db.UpdateAuthor(ctx, a); err != nil {
+		// Автор не сохранён: новая аватарка ни к кому не привязана
+		if a.AvatarURL != oldAvatar {
+			api.removeAvatar(ctx, a.AvatarURL)
+		}
+		http.Error(w, "Ошибка сохранения пользователя", storageStatus(err))
+		return
+	}
+	if a.AvatarURL != oldAvatar {
+		api.removeAvatar(ctx, oldAvatar)
+	}
+
+	http.Redirect(w, r, "/author/"+strconv.Itoa(id), http.StatusSeeOther)
+}
+
+// Обработка формы удаления автора.
+func (api *API) deleteAuthorFormHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !allow(w, r, canEditAuthor(r, id)) {
+		return
+	}
+	opts, err := parseDeleteOptions(r.FormValue("posts"), r.FormValue("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	if err := api.deleteAuthor(ctx, id, opts); err != nil {
+		if errors.Is(err, storage.ErrConflict) && !errors.Is(err, storage.ErrLastAdmin) {
+			http.Error(w, "У автора есть публикации: удалите их или передайте другому автору", http.StatusConflict)
+			return
+		}
+		storageError(w, r, err)
+		return
+	}
+	// Пользователь удалил сам себя: сессия больше не нужна
+	if acc, _ := currentAccount(r); acc.AuthorID == id {
+		api.sessions.clear(w)
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"GoNews/pkg/media"
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDeleteAuthorModes(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"restrict", "?posts=restrict", http.StatusConflict},
+		{"по умолчанию restrict", "", http.StatusConflict},
+		{"cascade", "?posts=cascade", http.StatusOK},
+		{"reassign", "?posts=reassign&to={admin}", http.StatusOK},
+		{"reassign самому себе", "?posts=reassign&to={self}", http.StatusUnprocessableEntity},
+		{"reassign несуществующему", "?posts=reassign&to=1000", http.StatusUnprocessableEntity},
+		{"reassign без автора", "?posts=reassign", http.StatusBadRequest},
+		{"неизвестный режим", "?posts=orphan", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, Options{})
+			admin := s.account("admin", rbac.Admin)
+			alice := s.account("alice", rbac.Author)
+			posts := []int{s.post(alice.AuthorID), s.post(alice.AuthorID)}
+
+			query := strings.NewReplacer("{admin}", strconv.Itoa(admin.AuthorID), "{self}", strconv.Itoa(alice.AuthorID)).Replace(tt.query)
+			r := httptest.NewRequest(http.MethodDelete, "/api/v1/authors/"+strconv.Itoa(alice.AuthorID)+query, nil)
+			r.Header.Set("Authorization", s.token(admin, scopeAuthorsAdmin))
+			w := s.do(r)
+			if tt.status != http.StatusOK {
+				wantError(t, w, tt.status)
+			} else if w.Code != tt.status {
+				t.Fatalf("код %d: %s", w.Code, w.Body)
+			}
+
+			_, err := s.db.GetAuthorByID(ctx, alice.AuthorID)
+			if deleted := !errors.Is(err, storage.ErrNotFound); deleted == (tt.status == http.StatusOK) {
+				t.Errorf("автор после удаления: %v", err)
+			}
+			for _, id := range posts {
+				p, err := s.db.Post(ctx, id)
+				switch {
+				case tt.name == "cascade":
+					if !errors.Is(err, storage.ErrNotFound) {
+						t.Errorf("публикация %d не удалена: %v", id, err)
+					}
+				case err != nil:
+					t.Errorf("публикация %d: %v", id, err)
+				case tt.name == "reassign" && p.AuthorID != admin.AuthorID:
+					t.Errorf("публикация %d у автора %d, ожидался %d", id, p.AuthorID, admin.AuthorID)
+				case tt.name != "reassign" && p.AuthorID != alice.AuthorID:
+					t.Errorf("публикация %d передана автору %d", id, p.AuthorID)
+				}
+			}
+		})
+	}
+}
+
+// Форма удаления автора отвечает страницей с ошибкой, а не JSON.
+func TestDeleteAuthorForm(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	s.post(alice.AuthorID)
+
+	form := func(mode string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/authors/"+strconv.Itoa(alice.AuthorID)+"/delete", strings.NewReader("posts="+mode))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return withCSRF(s.login(r, alice))
+	}
+	if w := s.do(form("restrict")); w.Code != http.StatusConflict || strings.HasPrefix(w.Body.String(), "{") {
+		t.Fatalf("удаление автора с публикациями: код %d: %s", w.Code, w.Body)
+	}
+	w := s.do(form("cascade"))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("удаление автора: код %d: %s", w.Code, w.Body)
+	}
+	// сам себя удалил - сессия сброшена
+	var cleared bool
+	for _, c := range w.Result().Cookies() {
+		cleared = cleared || c.Name == sessionCookie && c.MaxAge < 0
+	}
+	if !cleared {
+		t.Error("cookie сессии не сброшена после удаления себя")
+	}
+}
+
+// editAuthorForm возвращает форму редактирования автора acc с новым именем
+// и, если avatar не пуст, файлом аватарки.
+func (s *testServer) editAuthorForm(acc storage.Account, name string, avatar []byte) *http.Request {
+	s.t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", name)
+	if avatar != nil {
+		fw, err := mw.CreateFormFile("avatar", "avatar.png")
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		fw.Write(avatar)
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/authors/"+strconv.Itoa(acc.AuthorID)+"/edit", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return withCSRF(s.login(r, acc))
+}
+
+// mediaFiles возвращает ключи существующих файлов всех вариантов аватарки url.
+func (s *testServer) mediaFiles(url string) []string {
+	var keys []string
+	for _, v := range (storage.Author{AvatarURL: url}).Avatars() {
+		key, ok := s.api.avatarKey(v.URL)
+		if !ok {
+			continue
+		}
+		if rc, _, err := s.api.media.Get(context.Background(), key); err == nil {
+			rc.Close()
+			keys = append(keys, key)
+		} else if !errors.Is(err, media.ErrNotFound) {
+			s.t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+// Прежняя аватарка удаляется после замены, а новая - если автор не сохранён.
+func TestEditAuthorAvatar(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+
+	if w := s.do(s.editAuthorForm(alice, "Alice", pngImage(t, 64))); w.Code != http.StatusSeeOther {
+		t.Fatalf("загрузка аватарки: код %d: %s", w.Code, w.Body)
+	}
+	a, err := s.db.GetAuthorByID(ctx, alice.AuthorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := a.AvatarURL
+	if len(s.mediaFiles(first)) != len(storage.AvatarSizes) {
+		t.Fatalf("файлы аватарки %s не сохранены", first)
+	}
+
+	if w := s.do(s.editAuthorForm(alice, "Alice", pngImage(t, 64))); w.Code != http.StatusSeeOther {
+		t.Fatalf("замена аватарки: код %d: %s", w.Code, w.Body)
+	}
+	if a, err = s.db.GetAuthorByID(ctx, alice.AuthorID); err != nil {
+		t.Fatal(err)
+	}
+	second := a.AvatarURL
+	if second == first || len(s.mediaFiles(second)) != len(storage.AvatarSizes) {
+		t.Fatalf("аватарка не заменена: %s", second)
+	}
+	if keys := s.mediaFiles(first); len(keys) != 0 {
+		t.Errorf("прежняя аватарка не удалена: %v", keys)
+	}
+
+	// Замена внешним адресом через JSON API
+	r := httptest.NewRequest(http.MethodPut, "/api/v1/authors/"+strconv.Itoa(alice.AuthorID), strings.NewReader(`{"avatar_url": "https://example.com/alice.png"}`))
+	r.Header.Set("Content-Type", "application/json")
+	if w := s.do(withCSRF(s.login(r, alice))); w.Code != http.StatusOK {
+		t.Fatalf("смена аватарки через API: код %d: %s", w.Code, w.Body)
+	}
+	if keys := s.mediaFiles(second); len(keys) != 0 {
+		t.Errorf("загруженная аватарка не удалена после замены внешней: %v", keys)
+	}
+
+	// Ошибка сохранения автора: загруженные файлы не остаются в хранилище
+	s.api.db = failingAuthors{s.db}
+	if w := s.do(s.editAuthorForm(alice, "Alice", pngImage(t, 64))); w.Code != http.StatusInternalServerError {
+		t.Fatalf("ошибка сохранения автора: код %d: %s", w.Code, w.Body)
+	}
+	files, err := os.ReadDir(filepath.Join(s.api.static, "avatars"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("осталось файлов аватарки: %d", len(files))
+	}
+}
+
+// failingAuthors - БД, не сохраняющая изменения авторов.
+type failingAuthors struct {
+	storage.Interface
+}
+
+func (failingAuthors) UpdateAuthor(context.Context, storage.Author) error {
+	return errors.New("БД недоступна")
+}
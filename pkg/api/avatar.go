@@ -0,0 +1,280 @@
+package api
+
+import (
+	"GoNews/pkg/imaging"
+	"GoNews/pkg/media"
+	"GoNews/pkg/storage"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Префикс ключей аватарок в хранилище файлов.
+const avatarsKey = "avatars/"
+
+// URL каталога, в котором аватарки хранились до появления хранилища файлов.
+const localAvatarsURL = "/static/avatars/"
+
+// Ошибка обработки запроса с кодом ответа HTTP.
+type requestError struct {
+	status int
+	msg    string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+// Ответ на ошибку обработки запроса: requestError со своим кодом, остальные - 500.
+func requestErrorResponse(w http.ResponseWriter, err error) {
+	var re *requestError
+	if errors.As(err, &re) {
+		http.Error(w, re.msg, re.status)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// Ограничения на загружаемую аватарку.
+const (
+	maxAvatarSize   = 5 << 20     // размер файла в байтах
+	maxAvatarPixels = 6000 * 6000 // площадь изображения: защита от «бомб» при декодировании
+)
+
+// Допустимые типы аватарок, определённые по содержимому файла, и их расширения.
+var avatarTypes = map[string][]string{
+	"image/jpeg": {".jpg", ".jpeg"},
+	"image/png":  {".png"},
+	"image/gif":  {".gif"},
+}
+
+// Расширения и типы сохраняемых вариантов аватарки по формату imaging.
+var (
+	avatarExts     = map[string]string{imaging.JPEG: ".jpg", imaging.PNG: ".png"}
+	avatarMIMEType = map[string]string{imaging.JPEG: "image/jpeg", imaging.PNG: "image/png"}
+)
+
+// Имя файла аватарки: av_, 32 случайные шестнадцатеричные цифры
+// и у вариантов разного размера - сторона в пикселях.
+var avatarName = regexp.MustCompile(`^av_[0-9a-f]{32}(_[0-9]+)?\.(jpg|png)$`)
+
+// newAvatarID возвращает новую случайную основу имени файлов аватарки.
+func newAvatarID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "av_" + hex.EncodeToString(b), nil
+}
+
+// обработка фоток
+// Сохранение аватарки: проверяем расширение и содержимое файла, обрезаем
+// до квадрата, поворачиваем по EXIF и записываем в хранилище файлов варианты
+// всех размеров storage.AvatarSizes под случайным именем.
+// Имя пользователя в путь не попадает. Возвращает URL наименьшего варианта.
+func (api *API) saveAvatar(ctx context.Context, file multipart.File, header *multipart.FileHeader) (string, error) {
+	if header.Size > maxAvatarSize {
+		return "", &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Файл аватарки больше %d МБ", maxAvatarSize>>20)}
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarSize+1))
+	if err != nil {
+		return "", &requestError{http.StatusBadRequest, "Ошибка загрузки файла"}
+	}
+	if len(data) > maxAvatarSize {
+		return "", &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Файл аватарки больше %d МБ", maxAvatarSize>>20)}
+	}
+
+	// Тип определяем по содержимому, расширение должно ему соответствовать
+	exts, ok := avatarTypes[http.DetectContentType(data)]
+	if !ok {
+		return "", &requestError{http.StatusBadRequest, "Неподдерживаемый формат (только JPG, PNG, GIF)"}
+	}
+	if ext := strings.ToLower(filepath.Ext(header.Filename)); !slices.Contains(exts, ext) {
+		return "", &requestError{http.StatusBadRequest, "Расширение файла не соответствует его содержимому"}
+	}
+
+	images, format, err := imaging.Squares(data, storage.AvatarSizes, maxAvatarPixels)
+	switch {
+	case errors.Is(err, imaging.ErrTooLarge):
+		return "", &requestError{http.StatusBadRequest, "Слишком большое изображение"}
+	case err != nil:
+		return "", &requestError{http.StatusBadRequest, "Ошибка декодирования изображения"}
+	}
+
+	id, err := newAvatarID()
+	if err != nil {
+		return "", err
+	}
+	out := imaging.OutputFormat(format)
+	var keys []string
+	for i, img := range images {
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, img, format); err != nil {
+			api.deleteMedia(ctx, keys)
+			return "", &requestError{http.StatusInternalServerError, "Ошибка кодирования изображения"}
+		}
+		key := fmt.Sprintf("%s%s_%d%s", avatarsKey, id, storage.AvatarSizes[i], avatarExts[out])
+		if err := api.media.Put(ctx, key, &buf, avatarMIMEType[out]); err != nil {
+			log.Printf("Ошибка сохранения аватарки %s: %v", key, err)
+			api.deleteMedia(ctx, keys)
+			return "", &requestError{http.StatusInternalServerError, "Ошибка сохранения файла"}
+		}
+		keys = append(keys, key)
+	}
+	return api.media.URL(keys[0]), nil
+}
+
+// deleteMedia удаляет файлы из хранилища, записывая ошибки в журнал.
+func (api *API) deleteMedia(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := api.media.Delete(ctx, key); err != nil {
+			log.Printf("Ошибка удаления файла %s: %v", key, err)
+		}
+	}
+}
+
+// avatarKey возвращает ключ аватарки в хранилище файлов по её URL.
+// Файлы вне каталога аватарок не подходят.
+func (api *API) avatarKey(url string) (string, bool) {
+	key, ok := media.KeyFromURL(api.media, url)
+	return key, ok && strings.HasPrefix(key, avatarsKey)
+}
+
+// Удаление файлов аватарки со всеми вариантами по её URL.
+// Файлы вне каталога аватарок не трогаем.
+func (api *API) removeAvatar(ctx context.Context, url string) {
+	var keys []string
+	for _, v := range (storage.Author{AvatarURL: url}).Avatars() {
+		if key, ok := api.avatarKey(v.URL); ok {
+			keys = append(keys, key)
+		}
+	}
+	api.deleteMedia(ctx, keys)
+}
+
+// localAvatar возвращает путь к файлу аватарки, сохранённой в каталоге
+// static/avatars до появления хранилища файлов.
+func localAvatar(static, url string) (string, bool) {
+	name := path.Base(url)
+	if !strings.HasPrefix(url, localAvatarsURL) || path.Join(localAvatarsURL, name) != url || name == "." || name == ".." {
+		return "", false
+	}
+	return filepath.Join(static, "avatars", name), true
+}
+
+// copyAvatar копирует локальные файлы всех вариантов аватарки автора в хранилище
+// store под новым случайным именем. Возвращает URL наименьшего варианта и пути
+// скопированных файлов; пустой URL - аватарка не локальная или не читается.
+func copyAvatar(ctx context.Context, store media.Store, static string, a storage.Author) (string, []string, error) {
+	id, err := newAvatarID()
+	if err != nil {
+		return "", nil, err
+	}
+	variants := a.Avatars()
+	var keys, paths []string
+	cleanup := func() {
+		for _, key := range keys {
+			store.Delete(ctx, key)
+		}
+	}
+	for _, v := range variants {
+		p, ok := localAvatar(static, v.URL)
+		if !ok {
+			cleanup()
+			return "", nil, nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			log.Printf("Аватарка автора %d (%s) не перенесена: %v", a.ID, v.URL, err)
+			cleanup()
+			return "", nil, nil
+		}
+		ctype := http.DetectContentType(data)
+		if ctype != "image/jpeg" && ctype != "image/png" {
+			log.Printf("Аватарка автора %d (%s) не перенесена: неподдерживаемый формат", a.ID, v.URL)
+			cleanup()
+			return "", nil, nil
+		}
+		// Аватарки прежней схемы хранились в одном размере и остаются без суффикса
+		key := avatarsKey + id + avatarTypes[ctype][0]
+		if len(variants) > 1 {
+			key = fmt.Sprintf("%s%s_%d%s", avatarsKey, id, v.Size, avatarTypes[ctype][0])
+		}
+		if err := store.Put(ctx, key, bytes.NewReader(data), ctype); err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("аватарка автора %d: %w", a.ID, err)
+		}
+		keys = append(keys, key)
+		paths = append(paths, p)
+	}
+	if len(keys) == 0 {
+		return "", nil, nil
+	}
+	return store.URL(keys[0]), paths, nil
+}
+
+// MigrateAvatars переносит в хранилище store аватарки из каталога static/avatars:
+// сохранённые по прежней схеме av_<имя>.<расширение>, а при хранилище вне этого
+// каталога (например, S3) - все, со всеми вариантами размеров. Файлы получают
+// случайные имена, авторы обновляются. Прежние файлы удаляются, когда на них
+// не остаётся ссылок. Возвращает число перенесённых аватарок.
+func MigrateAvatars(ctx context.Context, db storage.Interface, static string, store media.Store) (int, error) {
+	authors, err := db.GetAuthors(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	var old []string
+	for _, a := range authors {
+		if key, ok := media.KeyFromURL(store, a.AvatarURL); ok && avatarName.MatchString(path.Base(key)) {
+			continue
+		}
+		url, paths, err := copyAvatar(ctx, store, static, a)
+		if err != nil {
+			return migrated, err
+		}
+		if url == "" {
+			continue
+		}
+		oldURL := a.AvatarURL
+		a.AvatarURL = url
+		if err := db.UpdateAuthor(ctx, a); err != nil {
+			for _, v := range a.Avatars() {
+				if key, ok := media.KeyFromURL(store, v.URL); ok {
+					store.Delete(ctx, key)
+				}
+			}
+			return migrated, fmt.Errorf("автор %d: %w", a.ID, err)
+		}
+		migrated++
+		for _, p := range paths {
+			if !slices.Contains(old, p) {
+				old = append(old, p)
+			}
+		}
+		log.Printf("Аватарка автора %d перенесена: %s -> %s", a.ID, oldURL, a.AvatarURL)
+	}
+
+	// По прежней схеме одноимённые авторы делили один файл: удаляем его,
+	// только когда перенесены все ссылающиеся на него авторы
+	for _, p := range old {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Ошибка удаления прежней аватарки %s: %v", p, err)
+		}
+	}
+	return migrated, nil
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"GoNews/pkg/media"
+	"GoNews/pkg/storage"
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+// pngImage возвращает PNG размером size×size.
+func pngImage(t *testing.T, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// avatarUpload возвращает файл формы с именем name и содержимым data,
+// как его получает обработчик.
+func avatarUpload(t *testing.T, name string, data []byte) (multipart.File, *multipart.FileHeader) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("avatar", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file, header
+}
+
+func TestSaveAvatar(t *testing.T) {
+	s := newTestServer(t, Options{})
+	ctx := context.Background()
+
+	big := make([]byte, maxAvatarSize+1)
+	copy(big, pngImage(t, 8))
+	tests := []struct {
+		name   string
+		file   string
+		data   []byte
+		status int
+	}{
+		{"расширение не по содержимому", "a.jpg", pngImage(t, 8), http.StatusBadRequest},
+		{"не изображение", "a.png", []byte("<html><script>alert(1)</script></html>"), http.StatusBadRequest},
+		{"повреждённый PNG", "a.png", pngImage(t, 8)[:40], http.StatusBadRequest},
+		{"больше допустимого", "a.png", big, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, header := avatarUpload(t, tt.file, tt.data)
+			_, err := s.api.saveAvatar(ctx, file, header)
+			var re *requestError
+			if !errors.As(err, &re) || re.status != tt.status {
+				t.Fatalf("ошибка %v, ожидался код %d", err, tt.status)
+			}
+		})
+	}
+
+	file, header := avatarUpload(t, "My Photo.PNG", pngImage(t, 300))
+	url, err := s.api.saveAvatar(ctx, file, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variants := storage.Author{AvatarURL: url}.Avatars()
+	if len(variants) != len(storage.AvatarSizes) {
+		t.Fatalf("вариантов аватарки %s: %d", url, len(variants))
+	}
+	for _, v := range variants {
+		key, ok := s.api.avatarKey(v.URL)
+		if !ok || !avatarName.MatchString(path.Base(key)) {
+			t.Fatalf("адрес варианта %s", v.URL)
+		}
+		rc, info, err := s.api.media.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+		if info.ContentType != "image/png" {
+			t.Errorf("%s: тип %q", key, info.ContentType)
+		}
+	}
+
+	s.api.removeAvatar(ctx, url)
+	for _, v := range variants {
+		key, _ := s.api.avatarKey(v.URL)
+		if _, _, err := s.api.media.Get(ctx, key); !errors.Is(err, media.ErrNotFound) {
+			t.Errorf("%s после удаления: %v", key, err)
+		}
+	}
+}
+
+func TestLocalAvatar(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/static/avatars/av_alice.png", filepath.Join("static", "avatars", "av_alice.png")},
+		{"/static/avatars/../secret.txt", ""},
+		{"/static/avatars/..", ""},
+		{"/static/avatars/./av_alice.png", ""},
+		{"/static/avatars//av_alice.png", ""},
+		{"/static/avatars/sub/av_alice.png", ""},
+		{"/static/avatars/", ""},
+		{"/static/../avatars/av_alice.png", ""},
+		{"/static/av_alice.png", ""},
+		{"https://example.com/static/avatars/av_alice.png", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := localAvatar("static", tt.url)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("localAvatar(%q) = %q, %v, ожидалось %q", tt.url, got, ok, tt.want)
+		}
+	}
+}
+
+func TestMigrateAvatars(t *testing.T) {
+	ctx := context.Background()
+	db := newTestServer(t, Options{}).db
+	static := t.TempDir()
+	store := media.NewFS(t.TempDir(), "/media/")
+
+	dir := filepath.Join(static, "avatars")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	shared := filepath.Join(dir, "av_alice.png")
+	if err := os.WriteFile(shared, pngImage(t, 32), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	text := filepath.Join(dir, "av_text.png")
+	if err := os.WriteFile(text, []byte("не изображение"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Два одноимённых автора делят один файл прежней схемы
+	authors := []storage.Author{
+		{Name: "Alice", AvatarURL: "/static/avatars/av_alice.png"},
+		{Name: "Alice", AvatarURL: "/static/avatars/av_alice.png"},
+		{Name: "Bob", AvatarURL: "https://example.com/bob.png"},
+		{Name: "Carol", AvatarURL: "/static/avatars/av_gone.png"},
+		{Name: "Dave", AvatarURL: "/static/avatars/av_text.png"},
+		{Name: "Eve", AvatarURL: "/static/avatars/../../secret.png"},
+	}
+	for i := range authors {
+		id, err := db.AddAuthor(ctx, authors[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		authors[i].ID = id
+	}
+
+	n, err := MigrateAvatars(ctx, db, static, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("перенесено %d аватарок, ожидалось 2", n)
+	}
+
+	got := map[int]string{}
+	for _, a := range authors {
+		b, err := db.GetAuthorByID(ctx, a.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[a.ID] = b.AvatarURL
+	}
+	for _, a := range authors[:2] {
+		key, ok := media.KeyFromURL(store, got[a.ID])
+		if !ok || !avatarName.MatchString(path.Base(key)) {
+			t.Fatalf("аватарка автора %d после переноса: %q", a.ID, got[a.ID])
+		}
+		rc, _, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if !bytes.Equal(data, pngImage(t, 32)) {
+			t.Errorf("содержимое %s не совпадает с прежним файлом", key)
+		}
+	}
+	if got[authors[0].ID] == got[authors[1].ID] {
+		t.Errorf("авторы делят перенесённый файл %s", got[authors[0].ID])
+	}
+	for _, a := range authors[2:] {
+		if got[a.ID] != a.AvatarURL {
+			t.Errorf("аватарка автора %s изменена: %q", a.Name, got[a.ID])
+		}
+	}
+	if _, err := os.Stat(shared); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("прежний общий файл не удалён: %v", err)
+	}
+	if _, err := os.Stat(text); err != nil {
+		t.Errorf("неперенесённый файл удалён: %v", err)
+	}
+
+	// Повторный запуск ничего не меняет
+	n, err = MigrateAvatars(ctx, db, static, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("повторно перенесено %d аватарок", n)
+	}
+	for _, a := range authors {
+		b, err := db.GetAuthorByID(ctx, a.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.AvatarURL != got[a.ID] {
+			t.Errorf("аватарка автора %d изменена повторным запуском: %q -> %q", a.ID, got[a.ID], b.AvatarURL)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS - настройки запросов к API со страниц других источников (например, SPA).
+type CORS struct {
+	Origins     []string      // разрешённые источники вида https://example.com; "*" - любой, кроме запросов с Credentials; пусто - CORS выключен
+	Methods     []string      // разрешённые методы (по умолчанию GET, POST, PUT, DELETE)
+	Headers     []string      // разрешённые заголовки запроса (по умолчанию Authorization, Content-Type, X-CSRF-Token)
+	Credentials bool          // разрешить запросы с cookie
+	MaxAge      time.Duration // сколько браузер может помнить ответ на предварительный запрос
+}
+
+// Значения по умолчанию для настроек CORS.
+var (
+	corsMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	corsHeaders = []string{"Authorization", "Content-Type", csrfHeader}
+)
+
+// allowOrigin возвращает значение Access-Control-Allow-Origin для источника origin
+// или пустую строку, если источник не разрешён. С Credentials "*" не действует:
+// иначе любой сайт мог бы обращаться к API с cookie пользователя.
+func (c CORS) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	if slices.Contains(c.Origins, "*") && !c.Credentials {
+		return "*"
+	}
+	for _, o := range c.Origins {
+		if o != "*" && strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// isPreflight сообщает, что запрос - предварительный запрос CORS.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// cors - промежуточный обработчик CORS. На предварительные запросы отвечает сам,
+// не вызывая обработчики маршрутов; к остальным запросам разрешённых источников
+// добавляет заголовки Access-Control-Allow-*.
+func (api *API) cors(next http.Handler) http.Handler {
+	c := api.corsOpts
+	methods := strings.Join(c.Methods, ", ")
+	headers := strings.Join(c.Headers, ", ")
+	maxAge := strconv.Itoa(int(c.MaxAge / time.Second))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		allowed := c.allowOrigin(r.Header.Get("Origin"))
+		if len(c.Origins) > 0 && allowed != "*" {
+			h.Add("Vary", "Origin")
+		}
+		if allowed != "" {
+			h.Set("Access-Control-Allow-Origin", allowed)
+			if c.Credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if !isPreflight(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Источнику без разрешения отвечаем без заголовков: браузер сам отклонит запрос
+		if allowed != "" {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			if c.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Ответ на запрос OPTIONS, который не является предварительным запросом CORS.
+func optionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		cors   CORS
+		origin string
+		want   string
+	}{
+		{"без Origin", CORS{Origins: []string{"*"}}, "", ""},
+		{"любой источник", CORS{Origins: []string{"*"}}, "https://evil.example", "*"},
+		{"перечисленный источник", CORS{Origins: []string{"https://app.example"}}, "https://app.example", "https://app.example"},
+		{"регистр не важен", CORS{Origins: []string{"https://App.Example"}}, "https://app.example", "https://app.example"},
+		{"чужой источник", CORS{Origins: []string{"https://app.example"}}, "https://evil.example", ""},
+		{"с cookie источник отражается", CORS{Origins: []string{"https://app.example"}, Credentials: true}, "https://app.example", "https://app.example"},
+		{"с cookie звёздочка не действует", CORS{Origins: []string{"*"}, Credentials: true}, "https://evil.example", ""},
+		{"с cookie звёздочка и источник", CORS{Origins: []string{"*", "https://app.example"}, Credentials: true}, "https://evil.example", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cors.allowOrigin(tt.origin); got != tt.want {
+				t.Errorf("allowOrigin(%q) = %q, ожидалось %q", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSCredentialsWildcard(t *testing.T) {
+	s := newTestServer(t, Options{CORS: CORS{Origins: []string{"*"}, Credentials: true}})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/posts", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	w := s.do(r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q для произвольного источника при credentials", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q для неразрешённого источника", got)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	s := newTestServer(t, Options{CORS: CORS{Origins: []string{"https://app.example"}, Credentials: true}})
+	acc := s.account("alice", rbac.Admin)
+	id := s.post(acc.AuthorID)
+
+	for _, path := range []string{"/api/v1/posts/", "/posts/"} {
+		for _, origin := range []string{"https://app.example", "https://evil.example"} {
+			r := httptest.NewRequest(http.MethodOptions, path+strconv.Itoa(id), nil)
+			r.Header.Set("Origin", origin)
+			r.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+			w := s.do(withCSRF(s.login(r, acc)))
+
+			if w.Code != http.StatusNoContent {
+				t.Errorf("OPTIONS %s от %s: код %d, ожидался 204", r.URL.Path, origin, w.Code)
+			}
+			allowed := origin == "https://app.example"
+			if got := w.Header().Get("Access-Control-Allow-Methods") != ""; got != allowed {
+				t.Errorf("OPTIONS %s от %s: Access-Control-Allow-Methods = %q", r.URL.Path, origin, w.Header().Get("Access-Control-Allow-Methods"))
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("OPTIONS %s от %s: тело ответа %q", r.URL.Path, origin, w.Body)
+			}
+		}
+	}
+
+	// предварительный запрос не доходит до обработчика удаления
+	if _, err := s.db.Post(context.Background(), id); err != nil {
+		t.Errorf("публикация после предварительных запросов: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"GoNews/pkg/storage"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Защита от подделки межсайтовых запросов (CSRF) по схеме double-submit cookie:
+// браузер получает случайный токен в cookie, а изменяющий запрос должен повторить
+// его в заголовке X-CSRF-Token или в поле формы csrf_token. Чужой сайт cookie
+// прочитать не может, поэтому и токен подставить не сможет.
+const (
+	csrfCookie = "gonews_csrf"
+	csrfHeader = "X-CSRF-Token"
+	csrfField  = "csrf_token"
+)
+
+// Длина токена в байтах до кодирования.
+const csrfTokenLen = 32
+
+// Ключ CSRF-токена в контексте запроса.
+type csrfKey struct{}
+
+// csrfToken возвращает CSRF-токен, который нужно передать с изменяющим запросом.
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfKey{}).(string)
+	return token
+}
+
+func newCSRFToken() string {
+	b := make([]byte, csrfTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// safeMethod сообщает, что метод не изменяет данные.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// isFormPost сообщает, что запрос отправлен HTML-формой.
+func isFormPost(r *http.Request) bool {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return ct == "application/x-www-form-urlencoded" || ct == "multipart/form-data"
+}
+
+// csrf - промежуточный обработчик: выдаёт браузеру CSRF-токен и проверяет его
+// у запросов POST, PUT, PATCH и DELETE. Запросы к JSON API с заголовком
+// Authorization: Bearer не проверяются: браузер не отправляет его сам, токен
+// проверяет bearerAuth, а cookie сессии на таких запросах не действует.
+// Страницы с формами проверяются всегда.
+func (api *API) csrf(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if c, err := r.Cookie(csrfCookie); err == nil && len(c.Value) == base64.RawURLEncoding.EncodedLen(csrfTokenLen) {
+			token = c.Value
+		}
+		fresh := token == ""
+		if fresh {
+			token = newCSRFToken()
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   api.sessions.secure,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		r = r.WithContext(context.WithValue(r.Context(), csrfKey{}, token))
+
+		if safeMethod(r.Method) || byToken(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sent := r.Header.Get(csrfHeader)
+		if sent == "" && isFormPost(r) {
+			if err := parseForm(r); err != nil {
+				formParseError(w, err)
+				return
+			}
+			sent = r.PostForm.Get(csrfField)
+		}
+		if fresh || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			api.csrfError(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasBearer сообщает, что запрос выполняется по API-токену.
+func hasBearer(r *http.Request) bool {
+	scheme, _, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	return strings.EqualFold(scheme, "Bearer")
+}
+
+// csrfError отвечает 403 на запрос без верного CSRF-токена:
+// страницей для отправленных форм и JSON для остальных запросов.
+func (api *API) csrfError(w http.ResponseWriter, r *http.Request) {
+	const msg = "Запрос отклонён: неверный или устаревший CSRF-токен. Обновите страницу и повторите действие."
+	if !isFormPost(r) {
+		jsonError(w, r, http.StatusForbidden, msg)
+		return
+	}
+	w.WriteHeader(http.StatusForbidden)
+	api.render(w, r, "error.html", storage.PageData{Message: msg})
+}
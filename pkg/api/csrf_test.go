@@ -0,0 +1,127 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+
+	form := func(token string) *http.Request {
+		body := "title=Заголовок&content=Текст"
+		if token != "" {
+			body += "&" + csrfField + "=" + token
+		}
+		r := httptest.NewRequest(http.MethodPost, "/add-post", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return s.login(r, alice)
+	}
+	cookieToken := newCSRFToken()
+	withCookie := func(r *http.Request) *http.Request {
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: cookieToken})
+		return r
+	}
+
+	tests := []struct {
+		name string
+		r    *http.Request
+	}{
+		{"форма без токена и cookie", form("")},
+		{"форма без токена", withCookie(form(""))},
+		{"форма с чужим токеном", withCookie(form(newCSRFToken()))},
+		{"токен без cookie", form(cookieToken)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(tt.r)
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("код %d, ожидался 403", w.Code)
+			}
+			if body := w.Body.String(); !strings.Contains(body, "CSRF") || strings.HasPrefix(body, "{") {
+				t.Errorf("отказ форме: %s, ожидалась страница с сообщением", body)
+			}
+		})
+	}
+	if w := s.do(withCookie(form(cookieToken))); w.Code != http.StatusSeeOther {
+		t.Errorf("форма с верным токеном: код %d: %s", w.Code, w.Body)
+	}
+
+	// fetch без заголовка получает JSON
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(`{"title": "Заголовок", "content": "Текст"}`))
+	r.Header.Set("Content-Type", "application/json")
+	wantError(t, s.do(withCookie(s.login(r, alice))), http.StatusForbidden)
+}
+
+// Запросы с токеном в заголовке Authorization браузер сам не отправляет:
+// CSRF-токен им не нужен.
+func TestCSRFBearerExempt(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(`{"title": "Заголовок", "content": "Текст"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", s.token(alice, scopePostsWrite))
+	if w := s.do(r); w.Code != http.StatusCreated {
+		t.Fatalf("запрос с токеном без CSRF: код %d: %s", w.Code, w.Body)
+	}
+
+	page, err := s.db.Posts(context.Background(), storage.PostsQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Posts) != 1 {
+		t.Errorf("публикаций после запроса с токеном: %d", len(page.Posts))
+	}
+}
+
+// Заголовок Authorization не освобождает страницы с формами от проверки CSRF,
+// а на маршрутах JSON API с заголовком не действует cookie сессии.
+func TestCSRFBearerForms(t *testing.T) {
+	s := newTestServer(t, Options{})
+	admin := s.account("admin", rbac.Admin)
+	bob := s.account("bob", rbac.Author)
+
+	form := func(path, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Authorization", "Bearer junk")
+		return s.login(r, admin)
+	}
+	if w := s.do(form("/add-post", "title=Заголовок&content=Текст")); w.Code != http.StatusForbidden {
+		t.Errorf("публикация формой с заголовком Bearer: код %d, ожидался 403", w.Code)
+	}
+	path := "/admin/roles/" + strconv.Itoa(bob.AuthorID)
+	if w := s.do(form(path, "role=admin")); w.Code != http.StatusForbidden {
+		t.Errorf("смена роли формой с заголовком Bearer: код %d, ожидался 403", w.Code)
+	}
+
+	// неверный токен не заменяется cookie сессии
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(`{"title": "Заголовок", "content": "Текст"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer junk")
+	wantError(t, s.do(s.login(r, admin)), http.StatusUnauthorized)
+
+	ctx := context.Background()
+	page, err := s.db.Posts(ctx, storage.PostsQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Posts) != 0 {
+		t.Errorf("публикаций после отклонённых запросов: %d", len(page.Posts))
+	}
+	acc, err := s.db.AccountByAuthor(ctx, bob.AuthorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Role != rbac.Author {
+		t.Errorf("роль после отклонённого запроса: %s", acc.Role)
+	}
+}
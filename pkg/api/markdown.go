@@ -0,0 +1,153 @@
+package api
+
+import (
+	"GoNews/pkg/markdown"
+	"GoNews/pkg/storage"
+	"GoNews/pkg/validate"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Сколько версий публикаций хранит кеш HTML.
+const htmlCacheSize = 1000
+
+// Префикс ссылок на вложения публикации в тексте: ![подпись](attachment:имя файла).
+const attachmentScheme = "attachment:"
+
+// htmlCache хранит HTML последних показанных версий публикаций
+// и вытесняет давно не запрошенные.
+type htmlCache struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List // от недавно запрошенных к давно запрошенным
+}
+
+type htmlCacheEntry struct {
+	key  string
+	html template.HTML
+}
+
+func newHTMLCache(size int) *htmlCache {
+	return &htmlCache{size: size, items: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *htmlCache) get(key string) (template.HTML, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(htmlCacheEntry).html, true
+}
+
+func (c *htmlCache) put(key string, html template.HTML) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(htmlCacheEntry{key, html})
+	for c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(htmlCacheEntry).key)
+	}
+}
+
+// revisionKey - ключ версии публикации в кеше: ID и хеш текста и вложений,
+// на которые он может ссылаться. Любое изменение даёт новый ключ,
+// поэтому старые версии не нужно удалять из кеша явно.
+func revisionKey(p storage.Post, atts []storage.Attachment) string {
+	h := sha256.New()
+	h.Write([]byte(p.Content))
+	for _, att := range atts {
+		fmt.Fprintf(h, "\x00%d\x00%s\x00%s\x00%s", att.ID, att.Name, att.URL, att.ThumbnailURL)
+	}
+	return fmt.Sprintf("%d:%s", p.ID, hex.EncodeToString(h.Sum(nil)))
+}
+
+// renderMarkdown преобразует текст в HTML, подставляя адреса вложений atts.
+func renderMarkdown(content string, atts []storage.Attachment) template.HTML {
+	return template.HTML(markdown.Render(content, markdown.Options{
+		Resolve: func(dest string) (string, bool, bool) {
+			name, ok := strings.CutPrefix(dest, attachmentScheme)
+			if !ok {
+				return "", false, false
+			}
+			att, ok := findAttachment(atts, strings.TrimSpace(name))
+			return att.URL, att.IsImage(), ok
+		},
+	}))
+}
+
+// postHTML возвращает HTML текста публикации с вложениями atts.
+// Результат кешируется для каждой версии публикации.
+func (api *API) postHTML(p storage.Post, atts []storage.Attachment) template.HTML {
+	key := revisionKey(p, atts)
+	if html, ok := api.html.get(key); ok {
+		return html
+	}
+	html := renderMarkdown(p.Content, atts)
+	api.html.put(key, html)
+	return html
+}
+
+// Запрос предварительного просмотра текста публикации. С post_id ссылки
+// на вложения разрешаются по вложениям этой публикации.
+type previewRequest struct {
+	Content string `json:"content"`
+	PostID  int    `json:"post_id"`
+}
+
+// Ответ предварительного просмотра.
+type previewResponse struct {
+	HTML string `json:"html"`
+}
+
+// Предварительный просмотр: HTML текста публикации в Markdown.
+// Публикация не сохраняется, поэтому результат не кешируется.
+func (api *API) previewHandler(w http.ResponseWriter, r *http.Request) {
+	var req previewRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		jsonError(w, r, http.StatusBadRequest, "Неверный JSON: "+err.Error())
+		return
+	}
+	// Пустой текст допустим: форма ещё не заполнена
+	if err := validate.Check(validate.F("content", req.Content, validate.MaxLen(maxContentLen), validate.Text())); err != nil {
+		storageError(w, r, err)
+		return
+	}
+
+	var atts []storage.Attachment
+	if req.PostID != 0 {
+		ctx, cancel := api.context(r)
+		defer cancel()
+
+		p, err := api.db.Post(ctx, req.PostID)
+		if err != nil {
+			storageError(w, r, err)
+			return
+		}
+		if !allow(w, r, canEditPost(r, p.AuthorID)) {
+			return
+		}
+		if atts, err = api.db.Attachments(ctx, p.ID); err != nil {
+			storageError(w, r, err)
+			return
+		}
+	}
+	respond(w, r, http.StatusOK, previewResponse{HTML: string(renderMarkdown(req.Content, atts))})
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Текст с HTML и опасными ссылками, которые не должны попасть в страницу.
+var unsafeContent = strings.Join([]string{
+	"**жирный**",
+	`<script>alert("script")</script>`,
+	`<img src=x onerror=alert("img")>`,
+	`<a href="javascript:alert('a')">ссылка</a>`,
+	`[md](javascript:alert("md"))`,
+	`[md](JaVaScRiPt:alert("md"))`,
+	`![img](javascript:alert("img"))`,
+	`<iframe src="https://evil.example"></iframe>`,
+	`<p onclick="alert('p')">абзац</p>`,
+}, "\n\n")
+
+// Признаки исполняемого содержимого в HTML: теги script и iframe, обработчики
+// событий с вызовом из текста и ссылки javascript:. Экранированный текст
+// (&lt;script&gt;) под них не подходит.
+var unsafeHTML = regexp.MustCompile(`(?i)<script>alert|<iframe|<[a-z]+[^<>]*\son[a-z]+="?alert|(href|src)="\s*javascript`)
+
+// checkSafeHTML проверяет, что в HTML нет исполняемого содержимого из unsafeContent.
+func checkSafeHTML(t *testing.T, html string) {
+	t.Helper()
+	if bad := unsafeHTML.FindAllString(html, -1); len(bad) != 0 {
+		t.Errorf("HTML содержит %q:\n%s", bad, html)
+	}
+	if !strings.Contains(html, "<strong>жирный</strong>") {
+		t.Errorf("Markdown не преобразован:\n%s", html)
+	}
+}
+
+func TestPreviewUnsafe(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	bob := s.account("bob", rbac.Author)
+
+	preview := func(acc storage.Account, req previewRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/preview", strings.NewReader(string(body)))
+		r.Header.Set("Content-Type", "application/json")
+		return s.do(withCSRF(s.login(r, acc)))
+	}
+
+	w := preview(alice, previewRequest{Content: unsafeContent})
+	if w.Code != http.StatusOK {
+		t.Fatalf("код %d: %s", w.Code, w.Body)
+	}
+	data, _ := decodeEnvelope(t, w).Data.(map[string]any)
+	html, _ := data["html"].(string)
+	checkSafeHTML(t, html)
+
+	// с post_id - только для своей публикации
+	wantError(t, preview(bob, previewRequest{Content: "текст", PostID: s.post(alice.AuthorID)}), http.StatusForbidden)
+	wantError(t, preview(alice, previewRequest{Content: "текст", PostID: 4242}), http.StatusNotFound)
+
+	// без входа просмотра нет
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/preview", strings.NewReader(`{"content": "текст"}`))
+	r.Header.Set("Content-Type", "application/json")
+	wantError(t, s.do(withCSRF(r)), http.StatusUnauthorized)
+}
+
+func TestPostPageUnsafe(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	id, err := s.db.AddPost(context.Background(), storage.Post{
+		Title:     `<script>alert("title")</script>`,
+		Content:   unsafeContent,
+		AuthorID:  alice.AuthorID,
+		CreatedAt: 1700000000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/post/" + strconv.Itoa(id), "/"} {
+		w := s.do(httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: код %d", path, w.Code)
+		}
+		body := w.Body.String()
+		checkSafeHTML(t, body)
+		if !strings.Contains(body, `&lt;script&gt;alert(&#34;title&#34;)&lt;/script&gt;`) {
+			t.Errorf("GET %s: заголовок не экранирован", path)
+		}
+	}
+}
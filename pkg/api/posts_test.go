@@ -0,0 +1,168 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreatePost(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	auth := s.token(alice, scopePostsWrite)
+
+	create := func(path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", auth)
+		return s.do(r)
+	}
+
+	w := create("/api/v1/posts", `{"title": "Заголовок", "content": "Текст"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("код %d, ожидался 201: %s", w.Code, w.Body)
+	}
+	data, _ := decodeEnvelope(t, w).Data.(map[string]any)
+	id, _ := data["ID"].(float64)
+	if id == 0 || data["Title"] != "Заголовок" || data["AuthorID"] != float64(alice.AuthorID) {
+		t.Fatalf("созданная публикация %v", data)
+	}
+	location := w.Header().Get("Location")
+	if want := "/api/v1/posts/" + strconv.Itoa(int(id)); location != want {
+		t.Errorf("Location = %q, ожидался %q", location, want)
+	}
+	if w := s.do(httptest.NewRequest(http.MethodGet, location, nil)); w.Code != http.StatusOK {
+		t.Errorf("GET %s: код %d", location, w.Code)
+	}
+
+	// прежний адрес ссылается на прежний адрес публикации
+	w = create("/posts", `{"title": "Заголовок", "content": "Текст"}`)
+	var legacy storage.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &legacy); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("POST /posts: код %d: %s", w.Code, w.Body)
+	}
+	if want := "/posts/" + strconv.Itoa(legacy.ID); w.Header().Get("Location") != want {
+		t.Errorf("Location = %q, ожидался %q", w.Header().Get("Location"), want)
+	}
+}
+
+func TestCreatePostErrors(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	bob := s.account("bob", rbac.Author)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		field  string // поле с ошибкой проверки
+	}{
+		{"не JSON", `title=Заголовок`, http.StatusBadRequest, ""},
+		{"неизвестное поле", `{"title": "Заголовок", "content": "Текст", "created_at": 1}`, http.StatusBadRequest, ""},
+		{"неверный тип", `{"title": 1, "content": "Текст"}`, http.StatusBadRequest, ""},
+		{"без заголовка", `{"content": "Текст"}`, http.StatusUnprocessableEntity, "title"},
+		{"заголовок из пробелов", `{"title": "   ", "content": "Текст"}`, http.StatusUnprocessableEntity, "title"},
+		{"многострочный заголовок", `{"title": "a\nb", "content": "Текст"}`, http.StatusUnprocessableEntity, "title"},
+		{"длинный заголовок", `{"title": "` + strings.Repeat("я", maxTitleLen+1) + `", "content": "Текст"}`, http.StatusUnprocessableEntity, "title"},
+		{"без текста", `{"title": "Заголовок"}`, http.StatusUnprocessableEntity, "content"},
+		{"от имени другого автора", `{"title": "Заголовок", "content": "Текст", "author_id": ` + strconv.Itoa(bob.AuthorID) + `}`, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Authorization", s.token(alice, scopePostsWrite))
+			w := s.do(r)
+			wantError(t, w, tt.status)
+			if tt.field == "" {
+				return
+			}
+			if env := decodeEnvelope(t, w); env.Error.Fields[tt.field] == "" {
+				t.Errorf("нет ошибки поля %q: %+v", tt.field, env.Error)
+			}
+		})
+	}
+
+	// редактор публикует от имени другого автора, но только существующего
+	editor := s.account("editor", rbac.Editor)
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(`{"title": "Заголовок", "content": "Текст", "author_id": 4242}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", s.token(editor, scopePostsWrite))
+	w := s.do(r)
+	wantError(t, w, http.StatusUnprocessableEntity)
+	if env := decodeEnvelope(t, w); env.Error.Fields["author_id"] == "" {
+		t.Errorf("нет ошибки поля author_id: %+v", env.Error)
+	}
+
+	page, err := s.db.Posts(context.Background(), storage.PostsQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Posts) != 0 {
+		t.Errorf("создано публикаций при ошибках: %d", len(page.Posts))
+	}
+}
+
+func TestEditPostForm(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	bob := s.account("bob", rbac.Author)
+	editor := s.account("editor", rbac.Editor)
+	id := s.post(alice.AuthorID)
+	path := "/posts/" + strconv.Itoa(id) + "/edit"
+
+	edit := func(acc storage.Account, title string) *httptest.ResponseRecorder {
+		// дата создания из формы не принимается
+		body := "title=" + title + "&content=Новый+текст&created_at=1&CreatedAt=1"
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return s.do(withCSRF(s.login(r, acc)))
+	}
+	post := func() storage.Post {
+		t.Helper()
+		p, err := s.db.Post(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// чужую публикацию автор не меняет и формы не видит
+	if w := edit(bob, "Чужой"); w.Code != http.StatusForbidden {
+		t.Errorf("изменение чужой публикации: код %d", w.Code)
+	}
+	if w := s.do(s.login(httptest.NewRequest(http.MethodGet, path, nil), bob)); w.Code != http.StatusForbidden {
+		t.Errorf("форма чужой публикации: код %d", w.Code)
+	}
+	if w := edit(alice, ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("пустой заголовок: код %d", w.Code)
+	}
+	if p := post(); p.Title != "Заголовок" || p.UpdatedAt != 0 {
+		t.Fatalf("публикация после отказов: %+v", p)
+	}
+
+	for _, acc := range []storage.Account{alice, editor} {
+		before := time.Now().Unix()
+		w := edit(acc, acc.Login)
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/post/"+strconv.Itoa(id) {
+			t.Fatalf("%s: код %d, Location %q: %s", acc.Login, w.Code, w.Header().Get("Location"), w.Body)
+		}
+		p := post()
+		if p.Title != acc.Login || p.Content != "Новый текст" || p.AuthorID != alice.AuthorID {
+			t.Errorf("%s: публикация после изменения: %+v", acc.Login, p)
+		}
+		if p.CreatedAt != 1700000000 {
+			t.Errorf("%s: дата создания %d, ожидалась прежняя", acc.Login, p.CreatedAt)
+		}
+		if p.UpdatedAt < before || p.UpdatedAt > time.Now().Unix() || p.UpdatedBy != acc.AuthorID {
+			t.Errorf("%s: изменена в %d автором %d", acc.Login, p.UpdatedAt, p.UpdatedBy)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"GoNews/pkg/storage"
+	"GoNews/pkg/validate"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Префикс версионированного JSON API. Прежние адреса без префикса
+// обслуживаются теми же обработчиками и отвечают без конверта.
+const apiPrefix = "/api/v1"
+
+// Заголовок с идентификатором запроса.
+const requestIDHeader = "X-Request-ID"
+
+// Ключ идентификатора запроса в контексте.
+type requestIDKey struct{}
+
+// requestID возвращает идентификатор запроса.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID сообщает, можно ли принять идентификатор запроса от клиента.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID - промежуточный обработчик: присваивает запросу идентификатор
+// (берёт из заголовка X-Request-ID или создаёт новый) и возвращает его в ответе.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			b := make([]byte, 8)
+			if _, err := rand.Read(b); err != nil {
+				panic(err)
+			}
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// versioned сообщает, что запрос пришёл в версионированный JSON API.
+func versioned(r *http.Request) bool {
+	return r.URL.Path == apiPrefix || strings.HasPrefix(r.URL.Path, apiPrefix+"/")
+}
+
+// apiPath возвращает адрес ресурса JSON API с учётом версии запроса.
+func apiPath(r *http.Request, path string) string {
+	if versioned(r) {
+		return apiPrefix + path
+	}
+	return path
+}
+
+// envelope - ответ версионированного JSON API: данные или ошибка
+// и идентификатор запроса.
+type envelope struct {
+	Data      any       `json:"data,omitempty"`
+	Error     *apiError `json:"error,omitempty"`
+	RequestID string    `json:"request_id"`
+}
+
+// apiError - описание ошибки в ответе JSON API.
+type apiError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// invalidField возвращает ошибку проверки одного поля.
+func invalidField(field, msg string) error {
+	return validate.Errors{field: msg}
+}
+
+// errorCode - машиночитаемый код ошибки для кода ответа HTTP.
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "too_large"
+	case http.StatusUnprocessableEntity:
+		return "validation_failed"
+	case http.StatusGatewayTimeout:
+		return "timeout"
+	}
+	return "internal"
+}
+
+// respond отвечает данными JSON API: в конверте для /api/v1, как есть - по прежним адресам.
+// Данные nil по прежним адресам дают пустой ответ.
+func respond(w http.ResponseWriter, r *http.Request, status int, data any) {
+	switch {
+	case versioned(r):
+		writeJSON(w, status, envelope{Data: data, RequestID: requestID(r)})
+	case data == nil:
+		w.WriteHeader(status)
+	default:
+		writeJSON(w, status, data)
+	}
+}
+
+// fail отвечает ошибкой JSON API с сообщениями по полям.
+// Прежние адреса получают только сообщение: {"error": "..."}.
+func fail(w http.ResponseWriter, r *http.Request, status int, msg string, fields map[string]string) {
+	if !versioned(r) {
+		writeJSON(w, status, map[string]string{"error": msg})
+		return
+	}
+	writeJSON(w, status, envelope{
+		Error:     &apiError{Code: errorCode(status), Message: msg, Fields: fields},
+		RequestID: requestID(r),
+	})
+}
+
+// Ответ JSON API с ошибкой.
+func jsonError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	fail(w, r, status, msg, nil)
+}
+
+// storageMessage возвращает сообщение об ошибке хранилища, которое можно показать
+// клиенту. Текст ошибок драйверов наружу не выдаётся: в нём бывают детали запросов.
+func storageMessage(err error) string {
+	var fe validate.Errors
+	switch {
+	case errors.As(err, &fe):
+		return "Некорректные данные: " + fe.Error()
+	case errors.Is(err, storage.ErrNotFound):
+		return "Запись не найдена"
+	case errors.Is(err, storage.ErrLastAdmin):
+		return "Нельзя снять роль с последнего администратора или удалить его"
+	case errors.Is(err, storage.ErrConflict):
+		return "Запись конфликтует с уже существующими данными"
+	case errors.Is(err, storage.ErrInvalid):
+		return "Некорректные данные"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Хранилище не ответило вовремя"
+	}
+	return "Внутренняя ошибка сервера"
+}
+
+// Ответ на ошибку хранилища с кодом, соответствующим её виду:
+// JSON для API, текст для страниц. Подробности пишутся в журнал.
+func storageError(w http.ResponseWriter, r *http.Request, err error) {
+	status := storageStatus(err)
+	if status >= http.StatusInternalServerError {
+		log.Printf("[%s] %s %s: %v", requestID(r), r.Method, r.URL.Path, err)
+	}
+	msg := storageMessage(err)
+	if !isAPI(r) {
+		http.Error(w, msg, status)
+		return
+	}
+	var fe validate.Errors
+	errors.As(err, &fe)
+	fail(w, r, status, msg, fe)
+}
+
+// Ответ на запрос к несуществующему адресу или с неподдерживаемым методом.
+func notFoundHandler(status int) http.Handler {
+	msg := "Адрес не найден"
+	if status == http.StatusMethodNotAllowed {
+		msg = "Метод не поддерживается"
+	}
+	return withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if versioned(r) {
+			jsonError(w, r, status, msg)
+			return
+		}
+		http.Error(w, msg, status)
+	}))
+}
+
+// Ответ в формате JSON.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(bytes)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	id := strconv.Itoa(s.post(alice.AuthorID))
+
+	// /api/v1: данные в конверте с идентификатором запроса из заголовка
+	w := s.do(httptest.NewRequest(http.MethodGet, "/api/v1/posts/"+id, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/posts/%s: код %d", id, w.Code)
+	}
+	env := decodeEnvelope(t, w)
+	data, ok := env.Data.(map[string]any)
+	if !ok || data["Title"] != "Заголовок" || env.Error != nil {
+		t.Errorf("конверт %+v, ожидалась публикация в data", env)
+	}
+	if env.RequestID == "" || env.RequestID != w.Header().Get(requestIDHeader) {
+		t.Errorf("request_id = %q, заголовок %q", env.RequestID, w.Header().Get(requestIDHeader))
+	}
+
+	// прежний адрес: публикация без конверта, идентификатор только в заголовке
+	w = s.do(httptest.NewRequest(http.MethodGet, "/posts/"+id, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /posts/%s: код %d", id, w.Code)
+	}
+	var legacy map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if legacy["Title"] != "Заголовок" || legacy["data"] != nil || legacy["request_id"] != nil {
+		t.Errorf("ответ прежнего адреса %v, ожидалась публикация без конверта", legacy)
+	}
+	if w.Header().Get(requestIDHeader) == "" {
+		t.Errorf("прежний адрес без заголовка %s", requestIDHeader)
+	}
+}
+
+func TestEnvelopeErrors(t *testing.T) {
+	s := newTestServer(t, Options{})
+
+	wantError(t, s.do(httptest.NewRequest(http.MethodGet, "/api/v1/posts/4242", nil)), http.StatusNotFound)
+	wantError(t, s.do(httptest.NewRequest(http.MethodGet, "/api/v1/no-such-route", nil)), http.StatusNotFound)
+	wantError(t, s.do(httptest.NewRequest(http.MethodGet, "/api/v1/posts/x", nil)), http.StatusBadRequest)
+
+	// прежние адреса отвечают только сообщением
+	w := s.do(httptest.NewRequest(http.MethodGet, "/posts/4242", nil))
+	var legacy map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if msg, ok := legacy["error"].(string); w.Code != http.StatusNotFound || !ok || msg == "" || legacy["request_id"] != nil {
+		t.Errorf("ошибка прежнего адреса: код %d, %v", w.Code, legacy)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	s := newTestServer(t, Options{})
+
+	tests := []struct {
+		name string
+		sent string
+		keep bool
+	}{
+		{"свой идентификатор", "trace-42.a_b", true},
+		{"недопустимые символы", "bad id\n", false},
+		{"без заголовка", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/posts/4242", nil)
+			if tt.sent != "" {
+				r.Header.Set(requestIDHeader, tt.sent)
+			}
+			w := s.do(r)
+			got := w.Header().Get(requestIDHeader)
+			if got == "" || (got == tt.sent) != tt.keep {
+				t.Errorf("%s = %q при отправленном %q", requestIDHeader, got, tt.sent)
+			}
+			if env := decodeEnvelope(t, w); env.RequestID != got {
+				t.Errorf("request_id = %q, заголовок %q", env.RequestID, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package api
+
+import (
+	"GoNews/pkg/diff"
+	"GoNews/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Версия публикации с автором изменения. У удалённого автора остаётся
+// только ID в поле AuthorID.
+type revisionView struct {
+	ID        int            `json:"id"`
+	PostID    int            `json:"post_id"`
+	AuthorID  int            `json:"author_id"`
+	Title     string         `json:"title"`
+	Content   string         `json:"content"`
+	CreatedAt int64          `json:"created_at"`
+	Author    storage.Author `json:"author"`
+}
+
+func newRevisionView(rev storage.Revision, a storage.Author) revisionView {
+	return revisionView{
+		ID:        rev.ID,
+		PostID:    rev.PostID,
+		AuthorID:  rev.AuthorID,
+		Title:     rev.Title,
+		Content:   rev.Content,
+		CreatedAt: rev.CreatedAt,
+		Author:    a,
+	}
+}
+
+// FormattedDate возвращает дату версии для вывода на странице.
+func (v revisionView) FormattedDate() string {
+	return storage.FormatDate(v.CreatedAt)
+}
+
+// Построчное сравнение двух версий публикации.
+type revisionDiff struct {
+	From    revisionView `json:"from"`
+	To      revisionView `json:"to"`
+	Title   []diff.Line  `json:"title"`
+	Content []diff.Line  `json:"content"`
+}
+
+// Данные страниц истории изменений публикации.
+type historyPage struct {
+	Post      storage.Post
+	Revisions []revisionView // новые версии первыми
+	Diff      revisionDiff   // только на странице сравнения
+}
+
+// historyPost возвращает публикацию, если вошедший пользователь может видеть
+// её историю. Историю видят те, кто может менять публикацию: в старых версиях
+// бывает то, что из текста убрали намеренно.
+func (api *API) historyPost(ctx context.Context, w http.ResponseWriter, r *http.Request, id int) (storage.Post, bool) {
+	p, err := api.db.Post(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return storage.Post{}, false
+	}
+	return p, allow(w, r, canEditPost(r, p.AuthorID))
+}
+
+// revisionViews возвращает версии публикации вместе с авторами изменений.
+func (api *API) revisionViews(ctx context.Context, postID int) ([]revisionView, error) {
+	revs, err := api.db.Revisions(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	authors, err := api.db.GetAuthors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]storage.Author, len(authors))
+	for _, a := range authors {
+		byID[a.ID] = a
+	}
+	views := make([]revisionView, 0, len(revs))
+	for _, rev := range revs {
+		views = append(views, newRevisionView(rev, byID[rev.AuthorID]))
+	}
+	return views, nil
+}
+
+// compareRevisions сравнивает версии from и to из списка версий публикации.
+// Нулевой to - последняя версия, нулевой from - версия перед to.
+func compareRevisions(views []revisionView, from, to int) (revisionDiff, error) {
+	if len(views) == 0 {
+		return revisionDiff{}, fmt.Errorf("версии публикации: %w", storage.ErrNotFound)
+	}
+	find := func(id int) (int, error) {
+		for i, v := range views {
+			if v.ID == id {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("версия %d: %w", id, storage.ErrNotFound)
+	}
+
+	j := len(views) - 1
+	if to != 0 {
+		var err error
+		if j, err = find(to); err != nil {
+			return revisionDiff{}, err
+		}
+	}
+	i := max(j-1, 0)
+	if from != 0 {
+		var err error
+		if i, err = find(from); err != nil {
+			return revisionDiff{}, err
+		}
+	}
+
+	a, b := views[i], views[j]
+	return revisionDiff{
+		From:    a,
+		To:      b,
+		Title:   diff.Lines(a.Title, b.Title),
+		Content: diff.Lines(a.Content, b.Content),
+	}, nil
+}
+
+// queryID возвращает необязательный числовой ID из параметра запроса name:
+// 0, если параметра нет.
+func queryID(r *http.Request, name string) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("неверный параметр %s: %q", name, s)
+	}
+	return id, nil
+}
+
+// restoreRevision сохраняет заголовок и текст версии revID как новую версию
+// публикации p от имени вошедшего пользователя. Старые версии не меняются.
+func (api *API) restoreRevision(ctx context.Context, r *http.Request, p storage.Post, revID int) (storage.Post, error) {
+	rev, err := api.db.Revision(ctx, p.ID, revID)
+	if err != nil {
+		return storage.Post{}, err
+	}
+	acc, _ := currentAccount(r)
+	p.Title, p.Content = rev.Title, rev.Content
+	p.UpdatedAt = time.Now().Unix()
+	p.UpdatedBy = acc.AuthorID
+	// Версию проверяем заново: правила могли стать строже с момента её сохранения
+	if p.Author, err = api.checkPost(ctx, p); err != nil {
+		return storage.Post{}, err
+	}
+	if err := api.db.UpdatePost(ctx, p); err != nil {
+		return storage.Post{}, err
+	}
+	return p, nil
+}
+
+// Версии публикации в порядке создания.
+func (api *API) revisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	if _, ok := api.historyPost(ctx, w, r, id); !ok {
+		return
+	}
+	views, err := api.revisionViews(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, views)
+}
+
+// Версия публикации.
+func (api *API) revisionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	revID, err := intParam(r, "revision")
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	if _, ok := api.historyPost(ctx, w, r, id); !ok {
+		return
+	}
+	rev, err := api.db.Revision(ctx, id, revID)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	a, err := api.db.GetAuthorByID(ctx, rev.AuthorID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, newRevisionView(rev, a))
+}
+
+// Построчное сравнение версий публикации: ?from=<ID версии>&to=<ID версии>.
+// Без параметров сравниваются две последние версии.
+func (api *API) diffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	from, err := queryID(r, "from")
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := queryID(r, "to")
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	if _, ok := api.historyPost(ctx, w, r, id); !ok {
+		return
+	}
+	views, err := api.revisionViews(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	d, err := compareRevisions(views, from, to)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, d)
+}
+
+// Восстановление версии публикации: её заголовок и текст становятся новой версией.
+// Отвечает изменённой публикацией.
+func (api *API) restoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	revID, err := intParam(r, "revision")
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	p, ok := api.historyPost(ctx, w, r, id)
+	if !ok {
+		return
+	}
+	p, err = api.restoreRevision(ctx, r, p, revID)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, p)
+}
+
+// Страница истории изменений публикации.
+func (api *API) revisionsPageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	p, ok := api.historyPost(ctx, w, r, id)
+	if !ok {
+		return
+	}
+	views, err := api.revisionViews(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	slices.Reverse(views)
+	api.render(w, r, "revisions.html", historyPage{Post: p, Revisions: views})
+}
+
+// Страница сравнения версий публикации.
+func (api *API) diffPageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := queryID(r, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := queryID(r, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	p, ok := api.historyPost(ctx, w, r, id)
+	if !ok {
+		return
+	}
+	views, err := api.revisionViews(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	d, err := compareRevisions(views, from, to)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	slices.Reverse(views)
+	api.render(w, r, "diff.html", historyPage{Post: p, Revisions: views, Diff: d})
+}
+
+// Обработка формы восстановления версии на странице истории.
+func (api *API) restoreRevisionFormHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	revID, err := intParam(r, "revision")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	p, ok := api.historyPost(ctx, w, r, id)
+	if !ok {
+		return
+	}
+	if _, err := api.restoreRevision(ctx, r, p, revID); err != nil {
+		storageError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/post/"+strconv.Itoa(id), http.StatusSeeOther)
+}
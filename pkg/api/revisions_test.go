@@ -0,0 +1,261 @@
+package api
+
+import (
+	"GoNews/pkg/diff"
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// revisionsFixture - публикация alice с тремя версиями: исходной, её правкой
+// и правкой редактора.
+type revisionsFixture struct {
+	*testServer
+	alice, bob, editor storage.Account
+	postID             int
+	revs               []storage.Revision
+}
+
+func newRevisionsFixture(t *testing.T) *revisionsFixture {
+	s := newTestServer(t, Options{})
+	f := &revisionsFixture{
+		testServer: s,
+		alice:      s.account("alice", rbac.Author),
+		bob:        s.account("bob", rbac.Author),
+		editor:     s.account("editor", rbac.Editor),
+	}
+	f.postID = s.post(f.alice.AuthorID)
+
+	ctx := context.Background()
+	for _, u := range []struct {
+		by             storage.Account
+		title, content string
+	}{
+		{f.alice, "Заголовок 2", "Текст\nвторая строка"},
+		{f.editor, "Заголовок 3", "Текст\nвторая строка\nтретья строка"},
+	} {
+		p, err := s.db.Post(ctx, f.postID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Title, p.Content, p.UpdatedBy = u.title, u.content, u.by.AuthorID
+		if err := s.db.UpdatePost(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revs, err := s.db.Revisions(ctx, f.postID)
+	if err != nil || len(revs) != 3 {
+		t.Fatalf("версии публикации: %v, %v", revs, err)
+	}
+	f.revs = revs
+	return f
+}
+
+// get выполняет GET-запрос JSON API от имени acc; нулевой acc - без входа.
+func (f *revisionsFixture) get(acc storage.Account, path string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if acc.AuthorID != 0 {
+		r = f.login(r, acc)
+	}
+	return f.do(r)
+}
+
+// envelopeData возвращает поле data ответа в виде исходного JSON.
+func envelopeData(t *testing.T, w *httptest.ResponseRecorder) json.RawMessage {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("код %d: %s", w.Code, w.Body)
+	}
+	var env struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatal(err)
+	}
+	return env.Data
+}
+
+func TestRevisionsList(t *testing.T) {
+	f := newRevisionsFixture(t)
+	path := fmt.Sprintf("/api/v1/posts/%d/revisions", f.postID)
+
+	// ключи JSON в нижнем регистре
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(envelopeData(t, f.get(f.alice, path)), &raw); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"author", "author_id", "content", "created_at", "id", "post_id", "title"}
+	for _, m := range raw {
+		var keys []string
+		for k := range m {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, want) {
+			t.Fatalf("ключи версии %v, ожидались %v", keys, want)
+		}
+	}
+
+	var views []revisionView
+	if err := json.Unmarshal(envelopeData(t, f.get(f.editor, path)), &views); err != nil {
+		t.Fatal(err)
+	}
+	if len(views) != len(f.revs) {
+		t.Fatalf("версий %d, ожидалось %d", len(views), len(f.revs))
+	}
+	authors := []storage.Account{f.alice, f.alice, f.editor}
+	for i, v := range views {
+		rev := f.revs[i]
+		if v.ID != rev.ID || v.PostID != f.postID || v.Title != rev.Title || v.Content != rev.Content || v.CreatedAt != rev.CreatedAt {
+			t.Errorf("версия %d: %+v, ожидалась %+v", i, v, rev)
+		}
+		if v.AuthorID != authors[i].AuthorID || v.Author.ID != authors[i].AuthorID || v.Author.Name != authors[i].Login {
+			t.Errorf("версия %d: автор %d %+v, ожидался %s", i, v.AuthorID, v.Author, authors[i].Login)
+		}
+	}
+
+	// одна версия
+	var v revisionView
+	if err := json.Unmarshal(envelopeData(t, f.get(f.alice, fmt.Sprintf("%s/%d", path, f.revs[1].ID))), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.ID != f.revs[1].ID || v.Title != "Заголовок 2" || v.Author.Name != "alice" {
+		t.Errorf("версия %+v", v)
+	}
+
+	// историю видят только те, кто может менять публикацию
+	wantError(t, f.get(storage.Account{}, path), http.StatusUnauthorized)
+	wantError(t, f.get(f.bob, path), http.StatusForbidden)
+	wantError(t, f.get(f.bob, fmt.Sprintf("%s/%d", path, f.revs[0].ID)), http.StatusForbidden)
+	wantError(t, f.get(f.alice, "/api/v1/posts/4242/revisions"), http.StatusNotFound)
+	wantError(t, f.get(f.alice, fmt.Sprintf("%s/%d", path, 4242)), http.StatusNotFound)
+
+	// версия другой публикации по адресу этой не отдаётся
+	other := f.post(f.alice.AuthorID)
+	revs, err := f.db.Revisions(context.Background(), other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantError(t, f.get(f.alice, fmt.Sprintf("%s/%d", path, revs[0].ID)), http.StatusNotFound)
+}
+
+func TestRevisionsDiff(t *testing.T) {
+	f := newRevisionsFixture(t)
+	path := fmt.Sprintf("/api/v1/posts/%d/diff", f.postID)
+
+	var d revisionDiff
+	if err := json.Unmarshal(envelopeData(t, f.get(f.alice, path)), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.From.ID != f.revs[1].ID || d.To.ID != f.revs[2].ID {
+		t.Errorf("по умолчанию сравниваются версии %d и %d, ожидались %d и %d", d.From.ID, d.To.ID, f.revs[1].ID, f.revs[2].ID)
+	}
+	wantContent := []diff.Line{{Op: diff.Equal, Text: "Текст"}, {Op: diff.Equal, Text: "вторая строка"}, {Op: diff.Insert, Text: "третья строка"}}
+	if !slices.Equal(d.Content, wantContent) {
+		t.Errorf("сравнение текста %+v, ожидалось %+v", d.Content, wantContent)
+	}
+	wantTitle := []diff.Line{{Op: diff.Delete, Text: "Заголовок 2"}, {Op: diff.Insert, Text: "Заголовок 3"}}
+	if !slices.Equal(d.Title, wantTitle) {
+		t.Errorf("сравнение заголовка %+v, ожидалось %+v", d.Title, wantTitle)
+	}
+
+	d = revisionDiff{}
+	if err := json.Unmarshal(envelopeData(t, f.get(f.alice, fmt.Sprintf("%s?from=%d&to=%d", path, f.revs[2].ID, f.revs[0].ID))), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.From.Title != "Заголовок 3" || d.To.Title != "Заголовок" || len(d.Content) != 3 || d.Content[1].Op != diff.Delete {
+		t.Errorf("сравнение в обратную сторону: %+v", d)
+	}
+
+	wantError(t, f.get(f.alice, path+"?from=x"), http.StatusBadRequest)
+	wantError(t, f.get(f.alice, path+"?to=4242"), http.StatusNotFound)
+	wantError(t, f.get(f.bob, path), http.StatusForbidden)
+	wantError(t, f.get(storage.Account{}, path), http.StatusUnauthorized)
+}
+
+func TestRestoreRevision(t *testing.T) {
+	f := newRevisionsFixture(t)
+	restore := func(auth string, revID int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/posts/%d/revisions/%d/restore", f.postID, revID), nil)
+		r.Header.Set("Authorization", auth)
+		return f.do(r)
+	}
+	revisions := func() []storage.Revision {
+		t.Helper()
+		revs, err := f.db.Revisions(context.Background(), f.postID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return revs
+	}
+
+	wantError(t, restore(f.token(f.bob, scopePostsWrite), f.revs[0].ID), http.StatusForbidden)
+	wantError(t, restore(f.token(f.alice), f.revs[0].ID), http.StatusForbidden)
+	wantError(t, restore(f.token(f.alice, scopePostsWrite), 4242), http.StatusNotFound)
+	if got := revisions(); len(got) != len(f.revs) {
+		t.Fatalf("версий после отказов: %d", len(got))
+	}
+
+	var p storage.Post
+	if err := json.Unmarshal(envelopeData(t, restore(f.token(f.alice, scopePostsWrite), f.revs[0].ID)), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != f.revs[0].Title || p.Content != f.revs[0].Content || p.UpdatedBy != f.alice.AuthorID {
+		t.Errorf("публикация после восстановления: %+v", p)
+	}
+	stored, err := f.db.Post(context.Background(), f.postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != f.revs[0].Title || stored.CreatedAt != 1700000000 {
+		t.Errorf("сохранённая публикация: %+v", stored)
+	}
+
+	// восстановление - новая версия, прежние не меняются
+	revs := revisions()
+	if len(revs) != len(f.revs)+1 || !slices.Equal(revs[:len(f.revs)], f.revs) {
+		t.Fatalf("версии после восстановления: %+v", revs)
+	}
+	last := revs[len(revs)-1]
+	if last.Title != f.revs[0].Title || last.Content != f.revs[0].Content || last.AuthorID != f.alice.AuthorID {
+		t.Errorf("новая версия %+v", last)
+	}
+
+	// через форму на странице истории - редактором
+	r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/post/%d/revisions/%d/restore", f.postID, f.revs[2].ID), nil)
+	w := f.do(withCSRF(f.login(r, f.editor)))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("восстановление формой: код %d: %s", w.Code, w.Body)
+	}
+	revs = revisions()
+	if len(revs) != len(f.revs)+2 || revs[len(revs)-1].Title != f.revs[2].Title || revs[len(revs)-1].AuthorID != f.editor.AuthorID {
+		t.Errorf("версии после восстановления формой: %+v", revs)
+	}
+}
+
+func TestRevisionsPages(t *testing.T) {
+	f := newRevisionsFixture(t)
+
+	for _, path := range []string{
+		fmt.Sprintf("/post/%d/revisions", f.postID),
+		fmt.Sprintf("/post/%d/diff?from=%d", f.postID, f.revs[0].ID),
+	} {
+		w := f.get(f.alice, path)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: код %d", path, w.Code)
+		}
+		if body := w.Body.String(); !strings.Contains(body, "editor") || !strings.Contains(body, "alice") {
+			t.Errorf("GET %s: нет авторов версий", path)
+		}
+		if w := f.get(f.bob, path); w.Code != http.StatusForbidden {
+			t.Errorf("GET %s чужим автором: код %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"errors"
+	"net/http"
+)
+
+// Строка таблицы ролей: учётная запись и её автор.
+type roleRow struct {
+	Account storage.Account
+	Author  storage.Author
+}
+
+// Страница назначения ролей.
+func (api *API) rolesPageHandler(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, can(r, rbac.ManageRoles)) {
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	accounts, err := api.db.Accounts(ctx)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	authors, err := api.db.GetAuthors(ctx)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	byID := make(map[int]storage.Author, len(authors))
+	for _, a := range authors {
+		byID[a.ID] = a
+	}
+
+	rows := make([]roleRow, 0, len(accounts))
+	for _, acc := range accounts {
+		rows = append(rows, roleRow{Account: acc, Author: byID[acc.AuthorID]})
+	}
+	api.render(w, r, "roles.html", rows)
+}
+
+// Назначение роли учётной записи автора.
+// Последнего администратора понизить нельзя: хранилище отвечает storage.ErrLastAdmin (409).
+func (api *API) setRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, can(r, rbac.ManageRoles)) {
+		return
+	}
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	role, err := rbac.Parse(r.FormValue("role"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	if err := api.db.SetRole(ctx, id, role); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "У автора нет учётной записи", http.StatusNotFound)
+			return
+		}
+		storageError(w, r, err)
+		return
+	}
+	// Смена роли отзывает сессии учётной записи; свою сессию выдаём заново
+	if cur, _ := currentAccount(r); cur.AuthorID == id {
+		if acc, err := api.db.AccountByAuthor(ctx, id); err == nil {
+			api.sessions.issue(w, acc)
+		}
+	}
+	http.Redirect(w, r, "/admin/roles", http.StatusSeeOther)
+}
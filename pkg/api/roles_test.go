@@ -0,0 +1,125 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLastAdmin(t *testing.T) {
+	s := newTestServer(t, Options{})
+	root := s.account("root", rbac.Admin)
+	id := strconv.Itoa(root.AuthorID)
+
+	form := func(path, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return withCSRF(s.login(r, root))
+	}
+	role := func() rbac.Role {
+		t.Helper()
+		acc, err := s.db.AccountByAuthor(context.Background(), root.AuthorID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return acc.Role
+	}
+
+	if w := s.do(form("/admin/roles/"+id, "role=editor")); w.Code != http.StatusConflict {
+		t.Errorf("понижение последнего администратора: код %d", w.Code)
+	}
+	w := s.do(form("/authors/"+id+"/delete", "posts=cascade"))
+	if w.Code != http.StatusConflict || strings.Contains(w.Body.String(), "публикации") {
+		t.Errorf("удаление последнего администратора формой: код %d: %s", w.Code, w.Body)
+	}
+	wantError(t, s.do(withCSRF(s.login(httptest.NewRequest(http.MethodDelete, "/api/v1/authors/"+id, nil), root))), http.StatusConflict)
+	if got := role(); got != rbac.Admin {
+		t.Fatalf("роль последнего администратора после отказов: %q", got)
+	}
+
+	// со вторым администратором роль снимается
+	s.account("admin2", rbac.Admin)
+	if w := s.do(form("/admin/roles/"+id, "role=editor")); w.Code != http.StatusSeeOther {
+		t.Errorf("понижение администратора при втором: код %d: %s", w.Code, w.Body)
+	}
+	if got := role(); got != rbac.Editor {
+		t.Errorf("роль после понижения: %q", got)
+	}
+}
+
+func TestCreateAdmin(t *testing.T) {
+	s := newTestServer(t, Options{})
+	ctx := context.Background()
+
+	id, err := CreateAdmin(ctx, s.db, "Администратор", " Root ", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc, err := s.db.AccountByLogin(ctx, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.AuthorID != id || acc.Role != rbac.Admin {
+		t.Errorf("учётная запись %+v, ожидался администратор %d", acc, id)
+	}
+
+	if _, err := CreateAdmin(ctx, s.db, "Другой", "root", "password1"); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("повторный логин: %v, ожидалась storage.ErrConflict", err)
+	}
+	if _, err := CreateAdmin(ctx, s.db, "Другой", "admin", "short"); formErrors(err)["password"] == "" {
+		t.Errorf("короткий пароль: %v, ожидалась ошибка поля password", err)
+	}
+	authors, err := s.db.GetAuthors(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authors) != 1 {
+		t.Errorf("авторов после неудачных попыток: %d, ожидался 1", len(authors))
+	}
+}
+
+// Первый зарегистрированный пользователь получает роль по умолчанию:
+// администратора создаёт только CreateAdmin.
+func TestSignupNotAdmin(t *testing.T) {
+	s := newTestServer(t, Options{})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	token := newCSRFToken()
+	for k, v := range map[string]string{csrfField: token, "name": "Alice", "login": "alice", "password": "password1"} {
+		mw.WriteField(k, v)
+	}
+	fw, err := mw.CreateFormFile("avatar", "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(fw, image.NewRGBA(image.Rect(0, 0, 64, 64))); err != nil {
+		t.Fatal(err)
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/add-user", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+	if w := s.do(r); w.Code != http.StatusSeeOther {
+		t.Fatalf("регистрация: код %d: %s", w.Code, w.Body)
+	}
+
+	acc, err := s.db.AccountByLogin(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Role != rbac.Author {
+		t.Errorf("роль первого пользователя %q, ожидалась %q", acc.Role, rbac.Author)
+	}
+}
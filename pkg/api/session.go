@@ -0,0 +1,226 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Имя cookie сессии.
+const sessionCookie = "gonews_session"
+
+// Время жизни сессии по умолчанию.
+const sessionTTL = 7 * 24 * time.Hour
+
+// sessions выдаёт и проверяет cookie сессий.
+// Cookie хранит ID автора, версию сессий учётной записи, отпечаток хеша пароля
+// и срок действия, подписанные HMAC-SHA256, поэтому на сервере состояние
+// сессий не хранится. Выход и смена роли увеличивают версию, смена пароля
+// меняет отпечаток: после этого выданные раньше cookie не действуют.
+type sessions struct {
+	key    []byte
+	ttl    time.Duration
+	secure bool // cookie только для HTTPS
+}
+
+// session - данные подписанной cookie сессии.
+type session struct {
+	authorID    int
+	version     int
+	fingerprint string
+}
+
+// valid сообщает, что сессия выдана для текущего состояния учётной записи acc.
+func (s session) valid(acc storage.Account) bool {
+	return s.version == acc.SessionVersion &&
+		hmac.Equal([]byte(s.fingerprint), []byte(passwordFingerprint(acc.PasswordHash)))
+}
+
+// passwordFingerprint возвращает короткий отпечаток хеша пароля для cookie:
+// сам хеш в cookie не попадает.
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+// newSessions создаёт менеджер сессий. Без ключа генерируется случайный:
+// тогда сессии не переживают перезапуск сервера.
+func newSessions(secret string, ttl time.Duration, secure bool) sessions {
+	s := sessions{key: []byte(secret), ttl: ttl, secure: secure}
+	if s.ttl <= 0 {
+		s.ttl = sessionTTL
+	}
+	if len(s.key) == 0 {
+		s.key = make([]byte, 32)
+		if _, err := rand.Read(s.key); err != nil {
+			panic(err)
+		}
+		log.Println("Ключ сессий не задан, используется случайный: после перезапуска потребуется войти заново")
+	}
+	return s
+}
+
+func (s sessions) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue выдаёт cookie сессии учётной записи acc.
+func (s sessions) issue(w http.ResponseWriter, acc storage.Account) {
+	expires := time.Now().Add(s.ttl)
+	payload := strings.Join([]string{
+		strconv.Itoa(acc.AuthorID),
+		strconv.Itoa(acc.SessionVersion),
+		passwordFingerprint(acc.PasswordHash),
+		strconv.FormatInt(expires.Unix(), 10),
+	}, ".")
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    payload + "." + s.sign(payload),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clear удаляет cookie сессии.
+func (s sessions) clear(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// session возвращает данные действительной cookie сессии.
+func (s sessions) session(r *http.Request) (session, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return session{}, false
+	}
+	i := strings.LastIndexByte(c.Value, '.')
+	if i < 0 {
+		return session{}, false
+	}
+	payload, sig := c.Value[:i], c.Value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return session{}, false
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 4 {
+		return session{}, false
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return session{}, false
+	}
+	version, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return session{}, false
+	}
+	exp, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || time.Now().Unix() >= exp {
+		return session{}, false
+	}
+	return session{authorID: id, version: version, fingerprint: parts[2]}, true
+}
+
+// Ключ учётной записи вошедшего пользователя в контексте запроса.
+type accountKey struct{}
+
+// currentAccount возвращает учётную запись вошедшего пользователя.
+func currentAccount(r *http.Request) (storage.Account, bool) {
+	acc, ok := r.Context().Value(accountKey{}).(storage.Account)
+	return acc, ok
+}
+
+// authenticate - промежуточный обработчик: по cookie сессии находит учётную запись
+// и кладёт её в контекст запроса. Запросы без сессии проходят как анонимные.
+func (api *API) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, ok := api.sessions.session(r)
+		if !ok || byToken(r) {
+			// запрос по токену выполняется только от имени владельца токена
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := api.context(r)
+		acc, err := api.db.AccountByAuthor(ctx, sess.authorID)
+		cancel()
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			// учётная запись удалена вместе с автором
+			api.sessions.clear(w)
+		case err != nil:
+			log.Printf("Ошибка загрузки учётной записи %d: %v", sess.authorID, err)
+		case !sess.valid(acc):
+			// сессия отозвана выходом, сменой роли или пароля
+			api.sessions.clear(w)
+		default:
+			r = r.WithContext(context.WithValue(r.Context(), accountKey{}, acc))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireLogin пропускает запрос только вошедшего пользователя.
+// Страницы перенаправляют на форму входа, остальные запросы получают 401.
+func requireLogin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := currentAccount(r); ok {
+			next(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && !isAPI(r) {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		accessError(w, r, http.StatusUnauthorized, "Требуется вход")
+	}
+}
+
+// can сообщает, даёт ли роль вошедшего пользователя право p.
+func can(r *http.Request, p rbac.Permission) bool {
+	acc, ok := currentAccount(r)
+	return ok && acc.Role.Can(p)
+}
+
+// canEditPost сообщает, может ли вошедший пользователь менять публикации автора owner.
+func canEditPost(r *http.Request, owner int) bool {
+	acc, ok := currentAccount(r)
+	return ok && rbac.CanEditPost(acc.Role, acc.AuthorID, owner)
+}
+
+// canEditAuthor сообщает, может ли вошедший пользователь менять автора authorID.
+func canEditAuthor(r *http.Request, authorID int) bool {
+	acc, ok := currentAccount(r)
+	return ok && rbac.CanEditAuthor(acc.Role, acc.AuthorID, authorID)
+}
+
+// allow отвечает 403 и возвращает false, если действие не разрешено.
+func allow(w http.ResponseWriter, r *http.Request, allowed bool) bool {
+	if !allowed {
+		accessError(w, r, http.StatusForbidden, "Недостаточно прав")
+	}
+	return allowed
+}
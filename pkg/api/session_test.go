@@ -0,0 +1,150 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRequireLogin(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	id := s.post(alice.AuthorID)
+	path := "/api/v1/posts/" + strconv.Itoa(id)
+
+	w := s.do(httptest.NewRequest(http.MethodGet, path+"/revisions", nil))
+	wantError(t, w, http.StatusUnauthorized)
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("ответ 401 без WWW-Authenticate")
+	}
+
+	w = s.do(withCSRF(httptest.NewRequest(http.MethodDelete, path, nil)))
+	wantError(t, w, http.StatusUnauthorized)
+	if _, err := s.db.Post(context.Background(), id); err != nil {
+		t.Errorf("публикация после анонимного удаления: %v", err)
+	}
+
+	// страницы отправляют на форму входа
+	w = s.do(httptest.NewRequest(http.MethodGet, "/add-post", nil))
+	if w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/login?next=") {
+		t.Errorf("GET /add-post без входа: код %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestEditForeignPost(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	bob := s.account("bob", rbac.Author)
+	editor := s.account("carol", rbac.Editor)
+	id := s.post(alice.AuthorID)
+	path := "/api/v1/posts/" + strconv.Itoa(id)
+
+	update := func(title string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"title": "`+title+`", "content": "Текст"}`))
+		r.Header.Set("Content-Type", "application/json")
+		return withCSRF(r)
+	}
+
+	wantError(t, s.do(s.login(update("Чужой"), bob)), http.StatusForbidden)
+	wantError(t, s.do(withCSRF(s.login(httptest.NewRequest(http.MethodDelete, path, nil), bob))), http.StatusForbidden)
+	p, err := s.db.Post(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Заголовок" {
+		t.Errorf("заголовок после отказа: %q", p.Title)
+	}
+
+	// свою публикацию меняет автор, чужую - редактор
+	for _, acc := range []storage.Account{alice, editor} {
+		if w := s.do(s.login(update(acc.Login), acc)); w.Code != http.StatusOK {
+			t.Errorf("%s: изменение публикации: код %d: %s", acc.Login, w.Code, w.Body)
+		}
+	}
+}
+
+func TestSessionRevoked(t *testing.T) {
+	s := newTestServer(t, Options{})
+	admin := s.account("root", rbac.Admin)
+	alice := s.account("alice", rbac.Author)
+	id := s.post(alice.AuthorID)
+	revisions := "/api/v1/posts/" + strconv.Itoa(id) + "/revisions"
+
+	// cookie сессии, которую затем отзывают
+	cookies := func(acc storage.Account) []*http.Cookie {
+		return s.login(httptest.NewRequest(http.MethodGet, "/", nil), acc).Cookies()
+	}
+	with := func(r *http.Request, cookies []*http.Cookie) *http.Request {
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		return r
+	}
+	get := func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		return s.do(with(httptest.NewRequest(http.MethodGet, revisions, nil), cookies))
+	}
+
+	cookie := cookies(alice)
+	if w := get(cookie); w.Code != http.StatusOK {
+		t.Fatalf("запрос с сессией: код %d: %s", w.Code, w.Body)
+	}
+	if w := s.do(withCSRF(with(httptest.NewRequest(http.MethodPost, "/logout", nil), cookie))); w.Code != http.StatusSeeOther {
+		t.Fatalf("выход: код %d", w.Code)
+	}
+	wantError(t, get(cookie), http.StatusUnauthorized)
+
+	// смена роли тоже отзывает сессии
+	acc, err := s.db.AccountByAuthor(context.Background(), alice.AuthorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie = cookies(acc)
+	if w := get(cookie); w.Code != http.StatusOK {
+		t.Fatalf("запрос с новой сессией: код %d", w.Code)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/admin/roles/"+strconv.Itoa(alice.AuthorID), strings.NewReader("role=editor"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if w := s.do(withCSRF(s.login(r, admin))); w.Code != http.StatusSeeOther {
+		t.Fatalf("смена роли: код %d: %s", w.Code, w.Body)
+	}
+	wantError(t, get(cookie), http.StatusUnauthorized)
+}
+
+// Cookie действует, пока у учётной записи прежние версия сессий и пароль.
+func TestSessionValid(t *testing.T) {
+	s := newTestServer(t, Options{})
+	acc := s.account("alice", rbac.Author)
+	r := s.login(httptest.NewRequest(http.MethodGet, "/", nil), acc)
+
+	sess, ok := s.api.sessions.session(r)
+	if !ok || sess.authorID != acc.AuthorID {
+		t.Fatalf("session() = %+v, %v", sess, ok)
+	}
+	if !sess.valid(acc) {
+		t.Error("сессия недействительна для той же учётной записи")
+	}
+	changed := acc
+	changed.PasswordHash = "другой хеш"
+	if sess.valid(changed) {
+		t.Error("сессия действительна после смены пароля")
+	}
+	changed = acc
+	changed.SessionVersion++
+	if sess.valid(changed) {
+		t.Error("сессия действительна после отзыва")
+	}
+
+	// подпись не даёт подменить версию в cookie
+	c, _ := r.Cookie(sessionCookie)
+	parts := strings.SplitN(c.Value, ".", 3)
+	forged := httptest.NewRequest(http.MethodGet, "/", nil)
+	forged.AddCookie(&http.Cookie{Name: sessionCookie, Value: parts[0] + ".1." + parts[2]})
+	if _, ok := s.api.sessions.session(forged); ok {
+		t.Error("принята cookie с изменённой версией")
+	}
+}
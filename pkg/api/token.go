@@ -0,0 +1,348 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// Области действия API-токенов.
+const (
+	scopePostsWrite   = "posts:write"   // создание, изменение и удаление публикаций
+	scopeAuthorsAdmin = "authors:admin" // изменение и удаление авторов
+)
+
+// Права, без которых роль владельца не может получить токен с областью действия.
+var scopePermissions = map[string]rbac.Permission{
+	scopePostsWrite:   rbac.CreatePost,
+	scopeAuthorsAdmin: rbac.ManageAuthors,
+}
+
+var knownScopes = []string{scopePostsWrite, scopeAuthorsAdmin}
+
+// Префикс выдаваемых токенов: по нему токен легко узнать в логах и настройках.
+const tokenPrefix = "gn_"
+
+const maxTokenName = 100
+
+// newToken создаёт случайный токен.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken возвращает хеш, под которым токен хранится в БД.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Ключи контекста запроса к JSON API и токена, по которому он выполнен.
+type (
+	apiKey   struct{}
+	tokenKey struct{}
+)
+
+// isAPI сообщает, что запрос обрабатывается JSON API: ошибки доступа отдаются в JSON.
+func isAPI(r *http.Request) bool {
+	return r.Context().Value(apiKey{}) != nil
+}
+
+// currentToken возвращает токен, по которому выполнен запрос.
+func currentToken(r *http.Request) (storage.Token, bool) {
+	tok, ok := r.Context().Value(tokenKey{}).(storage.Token)
+	return tok, ok
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="gonews"`)
+	jsonError(w, r, http.StatusUnauthorized, msg)
+}
+
+// accessError отвечает на отказ в доступе: JSON для API, текст для страниц.
+func accessError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	switch {
+	case isAPI(r) && status == http.StatusUnauthorized:
+		unauthorized(w, r, msg)
+	case isAPI(r):
+		jsonError(w, r, status, msg)
+	default:
+		http.Error(w, msg, status)
+	}
+}
+
+// tokenHandler - обработчик маршрута JSON API, на котором действует вход по токену.
+type tokenHandler struct{ http.Handler }
+
+// byToken сообщает, что запрос с заголовком Authorization: Bearer пришёл на
+// маршрут JSON API, где токен проверяет bearerAuth. На остальных маршрутах
+// заголовок не учитывается.
+func byToken(r *http.Request) bool {
+	if !hasBearer(r) {
+		return false
+	}
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	_, ok := route.GetHandler().(tokenHandler)
+	return ok
+}
+
+// bearerAuth - промежуточный обработчик JSON API. Запрос с заголовком
+// Authorization: Bearer <токен> выполняется от имени владельца токена;
+// без заголовка действует cookie сессии.
+func (api *API) bearerAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), apiKey{}, true)
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		scheme, token, _ := strings.Cut(header, " ")
+		token = strings.TrimSpace(token)
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			unauthorized(w, r, "Неверный заголовок Authorization: ожидается Bearer <токен>")
+			return
+		}
+
+		dbctx, cancel := api.context(r)
+		defer cancel()
+
+		tok, err := api.db.TokenByHash(dbctx, hashToken(token))
+		if errors.Is(err, storage.ErrNotFound) {
+			unauthorized(w, r, "Неверный или отозванный токен")
+			return
+		}
+		if err != nil {
+			jsonError(w, r, storageStatus(err), "Ошибка проверки токена")
+			return
+		}
+		if tok.ExpiresAt != 0 && time.Now().Unix() >= tok.ExpiresAt {
+			unauthorized(w, r, "Срок действия токена истёк")
+			return
+		}
+		acc, err := api.db.AccountByAuthor(dbctx, tok.AuthorID)
+		if errors.Is(err, storage.ErrNotFound) {
+			unauthorized(w, r, "Учётная запись владельца токена не найдена")
+			return
+		}
+		if err != nil {
+			jsonError(w, r, storageStatus(err), "Ошибка проверки токена")
+			return
+		}
+
+		// Токен важнее cookie: запрос выполняется от имени его владельца
+		ctx = context.WithValue(ctx, accountKey{}, acc)
+		ctx = context.WithValue(ctx, tokenKey{}, tok)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireScope пропускает запрос вошедшего пользователя. При входе по токену
+// у токена должна быть область действия scope.
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return requireLogin(func(w http.ResponseWriter, r *http.Request) {
+		if tok, ok := currentToken(r); ok && !slices.Contains(tok.Scopes, scope) {
+			jsonError(w, r, http.StatusForbidden, "У токена нет области действия "+scope)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// requireSession пропускает только запросы с cookie сессии:
+// токеном нельзя выпускать и отзывать другие токены.
+func requireSession(next http.HandlerFunc) http.HandlerFunc {
+	return requireLogin(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := currentToken(r); ok {
+			jsonError(w, r, http.StatusForbidden, "Токенами можно управлять только после входа по паролю")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// Запрос на создание токена.
+type tokenRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int64    `json:"expires_in"` // срок действия в секундах, 0 - бессрочный
+}
+
+// Токен в ответе API. Сам токен показывается только один раз, при создании.
+type tokenResponse struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+	Token     string   `json:"token,omitempty"`
+}
+
+func newTokenResponse(t storage.Token) tokenResponse {
+	scopes := t.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return tokenResponse{ID: t.ID, Name: t.Name, Scopes: scopes, CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt}
+}
+
+// Список токенов автора.
+func (api *API) tokensHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !allow(w, r, canEditAuthor(r, id)) {
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	tokens, err := api.db.Tokens(ctx, id)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	res := make([]tokenResponse, 0, len(tokens))
+	for _, t := range tokens {
+		res = append(res, newTokenResponse(t))
+	}
+	respond(w, r, http.StatusOK, res)
+}
+
+// Выпуск токена автора. Отвечает 201 с токеном, который больше нигде не показывается.
+func (api *API) createTokenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !allow(w, r, canEditAuthor(r, id)) {
+		return
+	}
+
+	var req tokenRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		jsonError(w, r, http.StatusBadRequest, "Неверный JSON: "+err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	switch {
+	case req.Name == "":
+		jsonError(w, r, http.StatusUnprocessableEntity, "Не задано название токена")
+		return
+	case utf8.RuneCountInString(req.Name) > maxTokenName:
+		jsonError(w, r, http.StatusUnprocessableEntity, fmt.Sprintf("Название токена длиннее %d символов", maxTokenName))
+		return
+	case len(req.Scopes) == 0:
+		jsonError(w, r, http.StatusUnprocessableEntity, "Не заданы области действия токена: "+strings.Join(knownScopes, ", "))
+		return
+	case req.ExpiresIn < 0:
+		jsonError(w, r, http.StatusUnprocessableEntity, "Срок действия токена не может быть отрицательным")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(knownScopes, scope) {
+			jsonError(w, r, http.StatusUnprocessableEntity, fmt.Sprintf("Неизвестная область действия %q", scope))
+			return
+		}
+	}
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	// Токен действует от имени учётной записи автора и не даёт больше её прав
+	owner, err := api.db.AccountByAuthor(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		jsonError(w, r, http.StatusUnprocessableEntity, "У автора нет учётной записи")
+		return
+	}
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !owner.Role.Can(scopePermissions[scope]) {
+			jsonError(w, r, http.StatusForbidden, fmt.Sprintf("Роль %s не даёт области действия %s", owner.Role, scope))
+			return
+		}
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		jsonError(w, r, http.StatusInternalServerError, "Ошибка создания токена")
+		return
+	}
+	t := storage.Token{
+		AuthorID:  id,
+		Name:      req.Name,
+		Hash:      hashToken(secret),
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now().Unix(),
+	}
+	if req.ExpiresIn > 0 {
+		t.ExpiresAt = t.CreatedAt + req.ExpiresIn
+	}
+	t.ID, err = api.db.AddToken(ctx, t)
+	if err != nil {
+		storageError(w, r, err)
+		return
+	}
+
+	res := newTokenResponse(t)
+	res.Token = secret
+	w.Header().Set("Location", apiPath(r, fmt.Sprintf("/authors/%d/tokens/%d", id, t.ID)))
+	respond(w, r, http.StatusCreated, res)
+}
+
+// Отзыв токена автора.
+func (api *API) deleteTokenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	tokenID, err := intParam(r, "token")
+	if err != nil {
+		jsonError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !allow(w, r, canEditAuthor(r, id)) {
+		return
+	}
+
+	ctx, cancel := api.context(r)
+	defer cancel()
+
+	if err := api.db.DeleteToken(ctx, id, tokenID); err != nil {
+		storageError(w, r, err)
+		return
+	}
+	respond(w, r, http.StatusOK, nil)
+}
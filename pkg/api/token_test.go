@@ -0,0 +1,73 @@
+package api
+
+import (
+	"GoNews/pkg/rbac"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBearerAuth(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+	id := s.post(alice.AuthorID)
+	path := "/api/v1/posts/" + strconv.Itoa(id)
+
+	update := func(auth string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"title": "Новый", "content": "Текст"}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", auth)
+		return s.do(r)
+	}
+
+	tests := []struct {
+		name   string
+		auth   string
+		status int
+	}{
+		{"неизвестный токен", "Bearer gn_unknown", http.StatusUnauthorized},
+		{"без области действия", s.token(alice), http.StatusForbidden},
+		{"другая область действия", s.token(alice, scopeAuthorsAdmin), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := update(tt.auth)
+			wantError(t, w, tt.status)
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("ответ 401 без WWW-Authenticate")
+			}
+		})
+	}
+	p, err := s.db.Post(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Заголовок" {
+		t.Errorf("заголовок после отказов: %q", p.Title)
+	}
+
+	if w := update(s.token(alice, scopePostsWrite)); w.Code != http.StatusOK {
+		t.Errorf("токен с областью %s: код %d: %s", scopePostsWrite, w.Code, w.Body)
+	}
+}
+
+// Токеном нельзя выпускать другие токены: только после входа по паролю.
+func TestTokensRequireSession(t *testing.T) {
+	s := newTestServer(t, Options{})
+	alice := s.account("alice", rbac.Author)
+
+	path := "/api/v1/authors/" + strconv.Itoa(alice.AuthorID) + "/tokens"
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"name": "ci", "scopes": ["posts:write"]}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", s.token(alice, scopePostsWrite, scopeAuthorsAdmin))
+	wantError(t, s.do(r), http.StatusForbidden)
+
+	r = httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"name": "ci", "scopes": ["posts:write"]}`))
+	r.Header.Set("Content-Type", "application/json")
+	if w := s.do(withCSRF(s.login(r, alice))); w.Code != http.StatusCreated {
+		t.Errorf("выпуск токена после входа: код %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"GoNews/pkg/storage"
+	"GoNews/pkg/validate"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Ограничения на размер полей в символах.
+// bcrypt учитывает не больше 72 байт пароля, более длинные отвергаем.
+const (
+	maxTitleLen     = 200
+	maxContentLen   = 50000
+	maxNameLen      = 100
+	maxAvatarURLLen = 500
+	minPasswordLen  = 8
+	maxPasswordLen  = 72
+)
+
+var (
+	namePattern  = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} .'_-]*$`)
+	loginPattern = regexp.MustCompile(`^[a-z0-9_.-]{3,32}$`)
+)
+
+// Правила проверки полей публикаций, авторов и учётных записей.
+// Имена полей совпадают с именами в JSON и в HTML-формах.
+var (
+	titleRules   = []validate.Rule{validate.Required(), validate.MaxLen(maxTitleLen), validate.SingleLine()}
+	contentRules = []validate.Rule{validate.Required(), validate.MaxLen(maxContentLen), validate.Text()}
+	nameRules    = []validate.Rule{validate.Required(), validate.MaxLen(maxNameLen),
+		validate.Match(namePattern, "только буквы, цифры, пробелы и символы «.», «'», «_», «-»")}
+	avatarURLRules = []validate.Rule{validate.MaxLen(maxAvatarURLLen), avatarURL}
+	loginRules     = []validate.Rule{validate.Match(loginPattern, "от 3 до 32 символов: латинские буквы, цифры, «_», «.» и «-»")}
+	passwordRules  = []validate.Rule{validate.MinLen(minPasswordLen), validate.MaxBytes(maxPasswordLen)}
+)
+
+// avatarURL - путь на этом сайте или адрес http(s).
+func avatarURL(s string) string {
+	local := strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") && !strings.HasPrefix(s, "/\\")
+	remote := strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+	if s != "" && !local && !remote || strings.ContainsAny(s, " \t\r\n\"'<>") {
+		return "ожидается путь на сайте или адрес http(s)"
+	}
+	return ""
+}
+
+// validatePost проверяет поля публикации.
+func validatePost(p storage.Post) error {
+	return validate.Check(
+		validate.F("title", p.Title, titleRules...),
+		validate.F("content", p.Content, contentRules...),
+	)
+}
+
+// validateAuthor проверяет поля автора.
+func validateAuthor(a storage.Author) error {
+	return validate.Check(
+		validate.F("name", a.Name, nameRules...),
+		validate.F("avatar_url", a.AvatarURL, avatarURLRules...),
+	)
+}
+
+// validateSignup проверяет имя автора, логин и пароль при регистрации.
+func validateSignup(name, login, password string) error {
+	return validate.Check(
+		validate.F("name", name, nameRules...),
+		validate.F("login", login, loginRules...),
+		validate.F("password", password, passwordRules...),
+	)
+}
+
+// Проверка публикации перед сохранением: поля и существование автора.
+// Ошибки проверки - validate.Errors.
+func (api *API) checkPost(ctx context.Context, p storage.Post) (storage.Author, error) {
+	if err := validatePost(p); err != nil {
+		return storage.Author{}, err
+	}
+	a, err := api.db.GetAuthorByID(ctx, p.AuthorID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return storage.Author{}, invalidField("author_id", fmt.Sprintf("автор %d не найден", p.AuthorID))
+	}
+	return a, err
+}
+
+// formErrors возвращает ошибки по полям для вывода в форме или nil,
+// если err - не ошибка проверки.
+func formErrors(err error) validate.Errors {
+	var errs validate.Errors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return nil
+}
+
+// formError выводит форму name заново с ошибками проверки по полям
+// и отправленными значениями.
+func (api *API) formError(w http.ResponseWriter, r *http.Request, name string, status int, data storage.PageData, err error) {
+	data.Errors = formErrors(err)
+	data.Form = r.PostForm
+	w.WriteHeader(status)
+	api.render(w, r, name, data)
+}
@@ -0,0 +1,437 @@
+// Package config собирает настройки сервера GoNews из значений по умолчанию,
+// файла YAML, переменных окружения и флагов командной строки.
+// Каждый следующий источник переопределяет предыдущий.
+package config
+
+import (
+	"GoNews/pkg/rbac"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Драйверы хранилища.
+const (
+	StoragePostgres = "postgres"
+	StorageMongo    = "mongo"
+	StorageMemDB    = "memdb"
+)
+
+// Драйверы хранилища загруженных файлов.
+const (
+	MediaFS = "fs"
+	MediaS3 = "s3"
+)
+
+// MinSessionSecret - минимальная длина ключа подписи сессий.
+const MinSessionSecret = 32
+
+// Config - настройки сервера.
+type Config struct {
+	Storage   string        `yaml:"storage"`   // драйвер хранилища: postgres, mongo или memdb
+	Addr      string        `yaml:"addr"`      // адрес, на котором слушает HTTP-сервер
+	Templates string        `yaml:"templates"` // каталог HTML-шаблонов
+	Static    string        `yaml:"static"`    // каталог статических файлов
+	Timeout   time.Duration `yaml:"timeout"`   // ограничение времени запроса к хранилищу
+	Migrate   bool          `yaml:"migrate"`   // применять миграции PostgreSQL при запуске
+
+	DefaultRole string `yaml:"default_role"` // роль новых пользователей: editor, author или reader
+
+	Postgres struct {
+		DSN string `yaml:"dsn"` // строка подключения
+	} `yaml:"postgres"`
+
+	Mongo struct {
+		URI      string `yaml:"uri"`      // строка подключения
+		Database string `yaml:"database"` // имя базы данных
+	} `yaml:"mongo"`
+
+	Session struct {
+		Secret string        `yaml:"secret"` // ключ подписи cookie сессий; пустой - случайный при каждом запуске
+		TTL    time.Duration `yaml:"ttl"`    // время жизни сессии
+		Secure bool          `yaml:"secure"` // выдавать cookie только для HTTPS
+	} `yaml:"session"`
+
+	CORS struct {
+		Origins     []string      `yaml:"origins"`     // источники, которым разрешены запросы к API; "*" - любые
+		Methods     []string      `yaml:"methods"`     // разрешённые методы
+		Headers     []string      `yaml:"headers"`     // разрешённые заголовки запроса
+		Credentials bool          `yaml:"credentials"` // разрешить запросы с cookie
+		MaxAge      time.Duration `yaml:"max_age"`     // время кеширования ответа на предварительный запрос
+	} `yaml:"cors"`
+
+	Media struct {
+		Driver string `yaml:"driver"` // хранилище загруженных файлов: fs или s3
+		Dir    string `yaml:"dir"`    // каталог для fs; пустой - каталог статических файлов
+		URL    string `yaml:"url"`    // адрес файлов для fs; пустой - /static/ или /media/, если каталог свой
+
+		S3 struct {
+			Endpoint  string `yaml:"endpoint"`   // адрес S3-совместимого сервиса
+			Bucket    string `yaml:"bucket"`     // корзина
+			Region    string `yaml:"region"`     // регион
+			AccessKey string `yaml:"access_key"` // ключ доступа
+			SecretKey string `yaml:"secret_key"` // секретный ключ
+			PublicURL string `yaml:"public_url"` // адрес, по которому браузер читает файлы; пустой - endpoint/bucket
+		} `yaml:"s3"`
+	} `yaml:"media"`
+
+	// Вывести итоговую конфигурацию и завершить работу.
+	PrintConfig bool `yaml:"-"`
+}
+
+// Default возвращает конфигурацию по умолчанию.
+func Default() Config {
+	var c Config
+	c.Storage = StoragePostgres
+	c.Addr = ":8080"
+	c.Templates = "templates"
+	c.Static = "static"
+	c.Timeout = 5 * time.Second
+	c.Mongo.Database = "GoNews"
+	c.Session.TTL = 7 * 24 * time.Hour
+	c.DefaultRole = string(rbac.Author)
+	c.CORS.Methods = []string{"GET", "POST", "PUT", "DELETE"}
+	c.CORS.Headers = []string{"Authorization", "Content-Type", "X-CSRF-Token"}
+	c.CORS.MaxAge = 10 * time.Minute
+	c.Media.Driver = MediaFS
+	c.Media.S3.Region = "us-east-1"
+	return c
+}
+
+// option - настройка, которую можно задать флагом и переменной окружения.
+type option struct {
+	flag   string
+	env    string
+	usage  string
+	isBool bool
+	set    func(c *Config, v string) error
+}
+
+func str(dst func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		*dst(c) = v
+		return nil
+	}
+}
+
+// list разбирает список через запятую.
+func list(dst func(c *Config) *[]string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst(c) = items
+		return nil
+	}
+}
+
+var options = []option{
+	{flag: "storage", env: "GONEWS_STORAGE", usage: "драйвер хранилища: postgres, mongo или memdb",
+		set: str(func(c *Config) *string { return &c.Storage })},
+	{flag: "addr", env: "GONEWS_ADDR", usage: "адрес HTTP-сервера, например :8080",
+		set: str(func(c *Config) *string { return &c.Addr })},
+	{flag: "templates", env: "GONEWS_TEMPLATES", usage: "каталог HTML-шаблонов",
+		set: str(func(c *Config) *string { return &c.Templates })},
+	{flag: "static", env: "GONEWS_STATIC", usage: "каталог статических файлов",
+		set: str(func(c *Config) *string { return &c.Static })},
+	{flag: "timeout", env: "GONEWS_TIMEOUT", usage: "ограничение времени запроса к хранилищу, например 5s",
+		set: func(c *Config, v string) (err error) {
+			c.Timeout, err = time.ParseDuration(v)
+			return err
+		}},
+	{flag: "migrate", env: "GONEWS_MIGRATE", usage: "применить миграции PostgreSQL перед запуском сервера", isBool: true,
+		set: func(c *Config, v string) (err error) {
+			c.Migrate, err = strconv.ParseBool(v)
+			return err
+		}},
+	{flag: "default-role", env: "GONEWS_DEFAULT_ROLE", usage: "роль новых пользователей: editor, author или reader",
+		set: str(func(c *Config) *string { return &c.DefaultRole })},
+	{flag: "postgres-dsn", env: "GONEWS_POSTGRES_DSN", usage: "строка подключения к PostgreSQL",
+		set: str(func(c *Config) *string { return &c.Postgres.DSN })},
+	{flag: "mongo-uri", env: "MONGO_URI", usage: "строка подключения к MongoDB",
+		set: str(func(c *Config) *string { return &c.Mongo.URI })},
+	{flag: "mongo-db", env: "MONGO_DB", usage: "имя базы данных MongoDB",
+		set: str(func(c *Config) *string { return &c.Mongo.Database })},
+	{flag: "session-secret", env: "GONEWS_SESSION_SECRET", usage: "ключ подписи cookie сессий, не короче 32 символов",
+		set: str(func(c *Config) *string { return &c.Session.Secret })},
+	{flag: "session-ttl", env: "GONEWS_SESSION_TTL", usage: "время жизни сессии, например 168h",
+		set: func(c *Config, v string) (err error) {
+			c.Session.TTL, err = time.ParseDuration(v)
+			return err
+		}},
+	{flag: "secure-cookies", env: "GONEWS_SECURE_COOKIES", usage: "выдавать cookie сессий только для HTTPS", isBool: true,
+		set: func(c *Config, v string) (err error) {
+			c.Session.Secure, err = strconv.ParseBool(v)
+			return err
+		}},
+	{flag: "cors-origins", env: "GONEWS_CORS_ORIGINS", usage: "источники через запятую, которым разрешены запросы к API, например https://app.example.com",
+		set: list(func(c *Config) *[]string { return &c.CORS.Origins })},
+	{flag: "cors-methods", env: "GONEWS_CORS_METHODS", usage: "разрешённые для CORS методы через запятую",
+		set: list(func(c *Config) *[]string { return &c.CORS.Methods })},
+	{flag: "cors-headers", env: "GONEWS_CORS_HEADERS", usage: "разрешённые для CORS заголовки через запятую",
+		set: list(func(c *Config) *[]string { return &c.CORS.Headers })},
+	{flag: "cors-credentials", env: "GONEWS_CORS_CREDENTIALS", usage: "разрешить запросы CORS с cookie", isBool: true,
+		set: func(c *Config, v string) (err error) {
+			c.CORS.Credentials, err = strconv.ParseBool(v)
+			return err
+		}},
+	{flag: "cors-max-age", env: "GONEWS_CORS_MAX_AGE", usage: "время кеширования ответа на предварительный запрос CORS, например 10m",
+		set: func(c *Config, v string) (err error) {
+			c.CORS.MaxAge, err = time.ParseDuration(v)
+			return err
+		}},
+	{flag: "media", env: "GONEWS_MEDIA", usage: "хранилище загруженных файлов: fs или s3",
+		set: str(func(c *Config) *string { return &c.Media.Driver })},
+	{flag: "media-dir", env: "GONEWS_MEDIA_DIR", usage: "каталог загруженных файлов для fs (по умолчанию каталог статических файлов)",
+		set: str(func(c *Config) *string { return &c.Media.Dir })},
+	{flag: "media-url", env: "GONEWS_MEDIA_URL", usage: "адрес загруженных файлов для fs, например https://cdn.example.com/",
+		set: str(func(c *Config) *string { return &c.Media.URL })},
+	{flag: "s3-endpoint", env: "GONEWS_S3_ENDPOINT", usage: "адрес S3-совместимого сервиса, например http://localhost:9000",
+		set: str(func(c *Config) *string { return &c.Media.S3.Endpoint })},
+	{flag: "s3-bucket", env: "GONEWS_S3_BUCKET", usage: "корзина S3",
+		set: str(func(c *Config) *string { return &c.Media.S3.Bucket })},
+	{flag: "s3-region", env: "GONEWS_S3_REGION", usage: "регион S3",
+		set: str(func(c *Config) *string { return &c.Media.S3.Region })},
+	{flag: "s3-access-key", env: "GONEWS_S3_ACCESS_KEY", usage: "ключ доступа S3",
+		set: str(func(c *Config) *string { return &c.Media.S3.AccessKey })},
+	{flag: "s3-secret-key", env: "GONEWS_S3_SECRET_KEY", usage: "секретный ключ S3",
+		set: str(func(c *Config) *string { return &c.Media.S3.SecretKey })},
+	{flag: "s3-public-url", env: "GONEWS_S3_PUBLIC_URL", usage: "адрес, по которому браузер читает файлы из S3",
+		set: str(func(c *Config) *string { return &c.Media.S3.PublicURL })},
+}
+
+// Load разбирает флаги args в наборе fs и собирает итоговую конфигурацию.
+// Путь к файлу конфигурации задаётся флагом -config или переменной GONEWS_CONFIG.
+// Возвращает аргументы, оставшиеся после флагов.
+func Load(fs *flag.FlagSet, args []string) (Config, []string, error) {
+	path := os.Getenv("GONEWS_CONFIG")
+	fs.StringVar(&path, "config", path, "файл конфигурации YAML (переменная GONEWS_CONFIG)")
+	var printConfig bool
+	fs.BoolVar(&printConfig, "print-config", false, "вывести итоговую конфигурацию и завершить работу")
+
+	// Значения флагов применяются после файла и окружения,
+	// поэтому при разборе только запоминаем их.
+	type flagValue struct {
+		opt   option
+		value string
+	}
+	var flags []flagValue
+	for _, opt := range options {
+		opt := opt
+		usage := fmt.Sprintf("%s (переменная %s)", opt.usage, opt.env)
+		record := func(v string) error {
+			flags = append(flags, flagValue{opt, v})
+			return nil
+		}
+		if opt.isBool {
+			fs.BoolFunc(opt.flag, usage, record)
+		} else {
+			fs.Func(opt.flag, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	c := Default()
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return Config{}, nil, err
+		}
+	}
+	for _, opt := range options {
+		if v, ok := os.LookupEnv(opt.env); ok && v != "" {
+			if err := opt.set(&c, v); err != nil {
+				return Config{}, nil, fmt.Errorf("переменная %s: %w", opt.env, err)
+			}
+		}
+	}
+	for _, f := range flags {
+		if err := f.opt.set(&c, f.value); err != nil {
+			return Config{}, nil, fmt.Errorf("флаг -%s: %w", f.opt.flag, err)
+		}
+	}
+	if c.Postgres.DSN == "" {
+		c.Postgres.DSN = legacyPostgresDSN()
+	}
+	c.PrintConfig = printConfig
+
+	if err := c.Validate(); err != nil {
+		return Config{}, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+// loadFile читает настройки из файла YAML поверх текущих значений.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("файл конфигурации: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("файл конфигурации %s: %w", path, err)
+	}
+	return nil
+}
+
+// legacyPostgresDSN собирает строку подключения из переменных DB_*,
+// которые использовались до появления GONEWS_POSTGRES_DSN.
+func legacyPostgresDSN() string {
+	host := os.Getenv("DB_HOST")
+	port := os.Getenv("DB_PORT")
+	user := os.Getenv("DB_USER")
+	password := os.Getenv("DB_PASSWORD")
+	dbname := os.Getenv("DB_NAME")
+	if host == "" || port == "" || user == "" || dbname == "" {
+		return ""
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     host + ":" + port,
+		Path:     "/" + dbname,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
+
+// Validate проверяет согласованность настроек.
+func (c Config) Validate() error {
+	var errs []error
+	switch c.Storage {
+	case StoragePostgres:
+		if c.Postgres.DSN == "" {
+			errs = append(errs, errors.New("для postgres нужна строка подключения (postgres.dsn, GONEWS_POSTGRES_DSN или DB_*)"))
+		}
+	case StorageMongo:
+		if c.Mongo.URI == "" {
+			errs = append(errs, errors.New("для mongo нужна строка подключения (mongo.uri или MONGO_URI)"))
+		}
+	case StorageMemDB:
+	default:
+		errs = append(errs, fmt.Errorf("неизвестный драйвер хранилища %q: ожидается postgres, mongo или memdb", c.Storage))
+	}
+	if c.Migrate && c.Storage != StoragePostgres {
+		errs = append(errs, errors.New("миграции поддерживаются только для postgres"))
+	}
+	if c.Addr == "" {
+		errs = append(errs, errors.New("не задан адрес сервера"))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("время запроса должно быть положительным: %s", c.Timeout))
+	}
+	if role, err := rbac.Parse(c.DefaultRole); err != nil {
+		errs = append(errs, fmt.Errorf("роль по умолчанию: %w", err))
+	} else if role == rbac.Admin {
+		errs = append(errs, errors.New("роль по умолчанию не может быть admin: администратора создаёт команда create-admin"))
+	}
+	if c.Session.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("время жизни сессии должно быть положительным: %s", c.Session.TTL))
+	}
+	if c.Session.Secret != "" && len(c.Session.Secret) < MinSessionSecret {
+		errs = append(errs, fmt.Errorf("ключ сессий короче %d символов", MinSessionSecret))
+	}
+	errs = append(errs, c.validateCORS()...)
+	errs = append(errs, c.validateMedia()...)
+	for name, dir := range map[string]string{"шаблонов": c.Templates, "статических файлов": c.Static} {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("каталог %s не найден: %q", name, dir))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateCORS проверяет настройки CORS.
+func (c Config) validateCORS() []error {
+	var errs []error
+	for _, o := range c.CORS.Origins {
+		if o == "*" {
+			if c.CORS.Credentials {
+				errs = append(errs, errors.New("cors: источник \"*\" нельзя сочетать с credentials, перечислите источники явно"))
+			}
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			errs = append(errs, fmt.Errorf("cors: неверный источник %q, ожидается схема и хост, например https://example.com", o))
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("cors: время кеширования не может быть отрицательным: %s", c.CORS.MaxAge))
+	}
+	return errs
+}
+
+// validateMedia проверяет настройки хранилища загруженных файлов.
+func (c Config) validateMedia() []error {
+	var errs []error
+	switch c.Media.Driver {
+	case MediaFS:
+		if c.Media.Dir != "" {
+			if fi, err := os.Stat(c.Media.Dir); err != nil || !fi.IsDir() {
+				errs = append(errs, fmt.Errorf("media: каталог загруженных файлов не найден: %q", c.Media.Dir))
+			}
+		}
+	case MediaS3:
+		s3 := c.Media.S3
+		if u, err := url.Parse(s3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("media: неверный адрес S3 %q, ожидается например http://localhost:9000", s3.Endpoint))
+		}
+		if s3.Bucket == "" {
+			errs = append(errs, errors.New("media: не задана корзина S3 (media.s3.bucket или GONEWS_S3_BUCKET)"))
+		}
+		if s3.AccessKey == "" || s3.SecretKey == "" {
+			errs = append(errs, errors.New("media: не заданы ключи доступа S3 (GONEWS_S3_ACCESS_KEY и GONEWS_S3_SECRET_KEY)"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("media: неизвестное хранилище файлов %q: ожидается fs или s3", c.Media.Driver))
+	}
+	return errs
+}
+
+// Write выводит конфигурацию в формате YAML, скрывая пароли в строках подключения
+// ключ сессий и секретный ключ S3.
+func (c Config) Write(w io.Writer) error {
+	c.Postgres.DSN = redact(c.Postgres.DSN)
+	c.Mongo.URI = redact(c.Mongo.URI)
+	if c.Session.Secret != "" {
+		c.Session.Secret = "xxxxx"
+	}
+	if c.Media.S3.SecretKey != "" {
+		c.Media.S3.SecretKey = "xxxxx"
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+var dsnPassword = regexp.MustCompile(`(password=)(?:'[^']*'|\S+)`)
+
+// redact скрывает пароль в строке подключения вида URL или key=value.
+func redact(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		return u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}xxxxx")
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dirs создаёт каталоги шаблонов и статических файлов и возвращает флаги с ними:
+// без существующих каталогов конфигурация не проходит проверку.
+func dirs(t *testing.T) []string {
+	t.Helper()
+	dir := t.TempDir()
+	return []string{"-templates", dir, "-static", dir}
+}
+
+// writeFile записывает файл конфигурации и возвращает путь к нему.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// load разбирает args в новом наборе флагов.
+func load(t *testing.T, args ...string) (Config, []string, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args)
+}
+
+// clearEnv убирает переменные, которые могли остаться в окружении запуска тестов.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, opt := range options {
+		t.Setenv(opt.env, "")
+	}
+	for _, env := range []string{"GONEWS_CONFIG", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME"} {
+		t.Setenv(env, "")
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	clearEnv(t)
+	c, rest, err := load(t, append(dirs(t), "-storage", "memdb", "serve")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != ":8080" || c.Timeout != 5*time.Second || c.Session.TTL != 7*24*time.Hour || c.Media.Driver != MediaFS {
+		t.Errorf("значения по умолчанию: %+v", c)
+	}
+	if !slices.Equal(rest, []string{"serve"}) {
+		t.Errorf("оставшиеся аргументы = %q, ожидалось [serve]", rest)
+	}
+}
+
+// Каждый следующий источник переопределяет предыдущий: значения по умолчанию,
+// файл, окружение, флаги.
+func TestPrecedence(t *testing.T) {
+	file := writeFile(t, `
+storage: memdb
+addr: ":1001"
+timeout: 2s
+cors:
+  origins: [https://file.example]
+`)
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		addr    string
+		timeout time.Duration
+		origins []string
+	}{
+		{"файл", nil, nil, ":1001", 2 * time.Second, []string{"https://file.example"}},
+		{"окружение поверх файла",
+			map[string]string{"GONEWS_ADDR": ":1002", "GONEWS_CORS_ORIGINS": "https://a.example, https://b.example"},
+			nil, ":1002", 2 * time.Second, []string{"https://a.example", "https://b.example"}},
+		{"флаги поверх окружения",
+			map[string]string{"GONEWS_ADDR": ":1002", "GONEWS_TIMEOUT": "3s"},
+			[]string{"-addr", ":1003"}, ":1003", 3 * time.Second, []string{"https://file.example"}},
+		{"пустая переменная не переопределяет файл",
+			map[string]string{"GONEWS_ADDR": ""}, nil, ":1001", 2 * time.Second, []string{"https://file.example"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("GONEWS_CONFIG", file)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c, _, err := load(t, append(dirs(t), tt.args...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Addr != tt.addr || c.Timeout != tt.timeout || !slices.Equal(c.CORS.Origins, tt.origins) {
+				t.Errorf("addr=%q timeout=%s origins=%q, ожидалось %q %s %q", c.Addr, c.Timeout, c.CORS.Origins, tt.addr, tt.timeout, tt.origins)
+			}
+		})
+	}
+}
+
+// Флаг -config важнее переменной GONEWS_CONFIG.
+func TestConfigFlag(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("GONEWS_CONFIG", writeFile(t, "storage: memdb\naddr: \":1001\"\n"))
+	c, _, err := load(t, append(dirs(t), "-config", writeFile(t, "storage: memdb\naddr: \":1002\"\n"))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != ":1002" {
+		t.Errorf("addr = %q, ожидалось :1002", c.Addr)
+	}
+}
+
+func TestLegacyPostgresDSN(t *testing.T) {
+	clearEnv(t)
+	for k, v := range map[string]string{"DB_HOST": "db", "DB_PORT": "5432", "DB_USER": "u", "DB_PASSWORD": "p@ss", "DB_NAME": "news"} {
+		t.Setenv(k, v)
+	}
+	c, _, err := load(t, dirs(t)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "postgres://u:p%40ss@db:5432/news?sslmode=disable"; c.Postgres.DSN != want {
+		t.Errorf("DSN = %q, ожидалось %q", c.Postgres.DSN, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"неизвестное поле файла", nil, []string{"-config", "FILE:storage: memdb\nstorrage: x\n"}, "storrage"},
+		{"нет файла", nil, []string{"-config", "/nonexistent/config.yaml"}, "файл конфигурации"},
+		{"неверная длительность в окружении", map[string]string{"GONEWS_TIMEOUT": "пять"}, nil, "переменная GONEWS_TIMEOUT"},
+		{"неверная длительность во флаге", nil, []string{"-timeout", "пять"}, "флаг -timeout"},
+		{"неверное логическое значение", map[string]string{"GONEWS_MIGRATE": "может быть"}, nil, "переменная GONEWS_MIGRATE"},
+		{"неизвестный флаг", nil, []string{"-unknown"}, "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("GONEWS_STORAGE", "memdb")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := dirs(t)
+			for _, a := range tt.args {
+				if content, ok := strings.CutPrefix(a, "FILE:"); ok {
+					a = writeFile(t, content)
+				}
+				args = append(args, a)
+			}
+			_, _, err := load(t, args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка %v, ожидалась с %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// valid возвращает правильную конфигурацию с существующими каталогами.
+func valid(t *testing.T) Config {
+	t.Helper()
+	c := Default()
+	c.Storage = StorageMemDB
+	c.Templates = t.TempDir()
+	c.Static = c.Templates
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"postgres без DSN", func(c *Config) { c.Storage = StoragePostgres }, "для postgres нужна строка подключения"},
+		{"mongo без URI", func(c *Config) { c.Storage = StorageMongo }, "для mongo нужна строка подключения"},
+		{"неизвестный драйвер", func(c *Config) { c.Storage = "sqlite" }, "неизвестный драйвер хранилища"},
+		{"миграции не для postgres", func(c *Config) { c.Migrate = true }, "миграции поддерживаются только для postgres"},
+		{"пустой адрес", func(c *Config) { c.Addr = "" }, "не задан адрес сервера"},
+		{"нулевое время запроса", func(c *Config) { c.Timeout = 0 }, "время запроса должно быть положительным"},
+		{"неизвестная роль", func(c *Config) { c.DefaultRole = "boss" }, "роль по умолчанию"},
+		{"роль admin по умолчанию", func(c *Config) { c.DefaultRole = "admin" }, "роль по умолчанию не может быть admin"},
+		{"нулевое время сессии", func(c *Config) { c.Session.TTL = 0 }, "время жизни сессии"},
+		{"короткий ключ сессий", func(c *Config) { c.Session.Secret = "short" }, "ключ сессий короче"},
+		{"нет каталога шаблонов", func(c *Config) { c.Templates = "/nonexistent" }, "каталог шаблонов не найден"},
+		{"нет каталога статических файлов", func(c *Config) { c.Static = "/nonexistent" }, "каталог статических файлов не найден"},
+		{"нет каталога файлов", func(c *Config) { c.Media.Dir = "/nonexistent" }, "media: каталог загруженных файлов не найден"},
+		{"неизвестное хранилище файлов", func(c *Config) { c.Media.Driver = "ftp" }, "media: неизвестное хранилище файлов"},
+		{"s3 без настроек", func(c *Config) { c.Media.Driver = MediaS3 }, "media: неверный адрес S3"},
+		{"s3 без корзины", func(c *Config) {
+			c.Media.Driver = MediaS3
+			c.Media.S3.Endpoint = "http://localhost:9000"
+			c.Media.S3.AccessKey, c.Media.S3.SecretKey = "a", "s"
+		}, "media: не задана корзина S3"},
+		{"s3 без ключей", func(c *Config) {
+			c.Media.Driver = MediaS3
+			c.Media.S3.Endpoint = "http://localhost:9000"
+			c.Media.S3.Bucket = "b"
+		}, "media: не заданы ключи доступа S3"},
+	}
+	if err := valid(t).Validate(); err != nil {
+		t.Fatalf("правильная конфигурация: %v", err)
+	}
+	for _, tt := range tests {
+		c := valid(t)
+		tt.modify(&c)
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ошибка %v, ожидалась с %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestValidateCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		maxAge      time.Duration
+		want        string // пусто - ошибок нет
+	}{
+		{"явные источники", []string{"https://a.example", "http://localhost:3000"}, true, time.Minute, ""},
+		{"любой источник без cookie", []string{"*"}, false, 0, ""},
+		{"любой источник с cookie", []string{"*"}, true, 0, `источник "*" нельзя сочетать с credentials`},
+		{"без схемы", []string{"a.example"}, false, 0, `неверный источник "a.example"`},
+		{"другая схема", []string{"ftp://a.example"}, false, 0, `неверный источник "ftp://a.example"`},
+		{"с путём", []string{"https://a.example/app"}, false, 0, `неверный источник "https://a.example/app"`},
+		{"с параметрами", []string{"https://a.example?x=1"}, false, 0, `неверный источник "https://a.example?x=1"`},
+		{"отрицательное время кеширования", nil, false, -time.Second, "время кеширования не может быть отрицательным"},
+	}
+	for _, tt := range tests {
+		var c Config
+		c.CORS.Origins, c.CORS.Credentials, c.CORS.MaxAge = tt.origins, tt.credentials, tt.maxAge
+		errs := c.validateCORS()
+		switch {
+		case tt.want == "" && len(errs) > 0:
+			t.Errorf("%s: неожиданные ошибки %v", tt.name, errs)
+		case tt.want != "" && (len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.want)):
+			t.Errorf("%s: ошибки %v, ожидалась одна с %q", tt.name, errs, tt.want)
+		}
+	}
+}
+
+// -print-config не мешает загрузке, а вывод скрывает секреты.
+func TestPrintConfig(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("GONEWS_SESSION_SECRET", strings.Repeat("s", MinSessionSecret))
+	t.Setenv("GONEWS_S3_SECRET_KEY", "s3-secret")
+	args := append(dirs(t), "-print-config", "-storage", "postgres",
+		"-postgres-dsn", "postgres://user:pg-secret@db/news", "-mongo-uri", "mongodb://user:mongo-secret@db")
+	c, _, err := load(t, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.PrintConfig {
+		t.Fatal("PrintConfig = false")
+	}
+
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, secret := range []string{"pg-secret", "mongo-secret", "s3-secret", c.Session.Secret} {
+		if strings.Contains(out, secret) {
+			t.Errorf("вывод содержит секрет %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "storage: postgres") || !strings.Contains(out, "postgres://user:xxxxx@db/news") {
+		t.Errorf("вывод:\n%s", out)
+	}
+	// Секреты скрываются только в выводе
+	if c.Postgres.DSN != "postgres://user:pg-secret@db/news" {
+		t.Errorf("Write изменил конфигурацию: %q", c.Postgres.DSN)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct{ dsn, want string }{
+		{"postgres://u:p@h/db", "postgres://u:xxxxx@h/db"},
+		{"host=h password=secret dbname=db", "host=h password=xxxxx dbname=db"},
+		{"host=h password='a b' dbname=db", "host=h password=xxxxx dbname=db"},
+		{"postgres://h/db", "postgres://h/db"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.dsn); got != tt.want {
+			t.Errorf("redact(%q) = %q, ожидалось %q", tt.dsn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package diff сравнивает тексты построчно.
+package diff
+
+import "strings"
+
+// Op - вид строки в результате сравнения.
+type Op string
+
+const (
+	Equal  Op = "equal"  // строка есть в обоих текстах
+	Delete Op = "delete" // строка есть только в старом тексте
+	Insert Op = "insert" // строка есть только в новом тексте
+)
+
+// Line - строка результата сравнения.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Предел числа правок для алгоритма Майерса: время и память растут вместе
+// с ним. Если тексты различаются сильнее, старый текст целиком заменяется новым.
+const maxEdits = 1000
+
+// Lines сравнивает тексты a и b построчно и возвращает кратчайший набор
+// удалений и вставок, превращающий a в b, вместе с общими строками.
+// Переводы строк \r\n считаются равными \n.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// Общие начало и конец не участвуют в поиске правок
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	res := make([]Line, 0, len(x)+len(y)-pre-suf)
+	for _, s := range x[:pre] {
+		res = append(res, Line{Equal, s})
+	}
+	res = append(res, myers(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, s := range x[len(x)-suf:] {
+		res = append(res, Line{Equal, s})
+	}
+	return res
+}
+
+// split разбивает текст на строки. Перевод строки в конце текста
+// не даёт лишней пустой строки.
+func split(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// myers находит кратчайший набор правок алгоритмом Майерса.
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	// v[off+k] - наибольший x на диагонали k = x - y
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] - значения v на диагоналях -d..d перед шагом d
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1] // вставка
+			} else {
+				x = v[off+k-1] + 1 // удаление
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return replace(a, b)
+}
+
+// backtrack восстанавливает правки по сохранённым значениям v,
+// проходя от конца текстов к началу.
+func backtrack(a, b []string, trace [][]int) []Line {
+	var res []Line
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		if d == 0 {
+			for x > 0 {
+				x--
+				res = append(res, Line{Equal, a[x]})
+			}
+			break
+		}
+		v := trace[d] // v[i] соответствует диагонали i - d
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := v[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			res = append(res, Line{Equal, a[x]})
+		}
+		if x == prevX {
+			res = append(res, Line{Insert, b[prevY]})
+		} else {
+			res = append(res, Line{Delete, a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
+
+// replace заменяет старый текст новым целиком.
+func replace(a, b []string) []Line {
+	res := make([]Line, 0, len(a)+len(b))
+	for _, s := range a {
+		res = append(res, Line{Delete, s})
+	}
+	for _, s := range b {
+		res = append(res, Line{Insert, s})
+	}
+	return res
+}
@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// format записывает результат сравнения в виде унифицированного diff без заголовков.
+func format(lines []Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		switch l.Op {
+		case Equal:
+			b.WriteString(" ")
+		case Delete:
+			b.WriteString("-")
+		case Insert:
+			b.WriteString("+")
+		}
+		b.WriteString(l.Text + "\n")
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"пустые", "", "", ""},
+		{"одинаковые", "a\nb\n", "a\nb", " a\n b\n"},
+		{"вставка", "", "a\nb", "+a\n+b\n"},
+		{"удаление", "a\nb", "", "-a\n-b\n"},
+		{"замена строки", "a\nb\nc", "a\nB\nc", " a\n-b\n+B\n c\n"},
+		{"вставка в середину", "a\nc", "a\nb\nc", " a\n+b\n c\n"},
+		{"перестановка", "a\nb\nc", "c\na\nb", "+c\n a\n b\n-c\n"},
+		{"переводы строк", "a\r\nb\r\n", "a\nb\n", " a\n b\n"},
+		{"пустые строки", "a\n\nb", "a\nb", " a\n-\n b\n"},
+		{"пример Майерса", "a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc",
+			"-a\n-b\n c\n+b\n a\n b\n-b\n a\n+c\n"},
+	}
+	for _, tt := range tests {
+		if got := format(Lines(tt.a, tt.b)); got != tt.want {
+			t.Errorf("%s: Lines(%q, %q) =\n%s\nожидалось\n%s", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// Правки превращают старый текст в новый, общих строк - наибольшее число.
+func TestLinesApply(t *testing.T) {
+	a := strings.Repeat("x\ny\nz\n", 50)
+	b := strings.ReplaceAll(a, "y\n", "y\nw\n")
+	b = strings.Replace(b, "x\n", "", 10)
+	lines := Lines(a, b)
+
+	var old, cur []string
+	edits := 0
+	for _, l := range lines {
+		if l.Op != Insert {
+			old = append(old, l.Text)
+		}
+		if l.Op != Delete {
+			cur = append(cur, l.Text)
+		}
+		if l.Op != Equal {
+			edits++
+		}
+	}
+	if got := strings.Join(old, "\n") + "\n"; got != a {
+		t.Errorf("старый текст не восстанавливается:\n%s", got)
+	}
+	if got := strings.Join(cur, "\n") + "\n"; got != b {
+		t.Errorf("новый текст не восстанавливается:\n%s", got)
+	}
+	if edits != 60 {
+		t.Errorf("правок: %d, ожидалось 60", edits)
+	}
+}
+
+// Сильно различающиеся тексты заменяются целиком.
+func TestLinesLimit(t *testing.T) {
+	var a, b strings.Builder
+	for i := range maxEdits {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+	lines := Lines("общая\n"+a.String(), "общая\n"+b.String())
+	if len(lines) != 2*maxEdits+1 || lines[0].Op != Equal || lines[1].Op != Delete || lines[len(lines)-1].Op != Insert {
+		t.Errorf("получено %d строк: %+v ... %+v", len(lines), lines[:2], lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// Тег EXIF с ориентацией снимка.
+const orientationTag = 0x0112
+
+// Orientation возвращает ориентацию снимка JPEG из EXIF: от 1 (как есть) до 8.
+// Без EXIF или при ошибке разбора возвращает 1.
+func Orientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	// Ищем сегмент APP1 с EXIF среди сегментов перед данными изображения
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // заполнитель
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // начало данных или конец файла
+			return 1
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+n]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + n
+	}
+	return 1
+}
+
+// tiffOrientation читает ориентацию из первого каталога (IFD0) данных TIFF.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != orientationTag {
+			continue
+		}
+		// Значение типа SHORT хранится прямо в записи
+		if order.Uint16(tiff[entry+2:]) != 3 {
+			return 1
+		}
+		if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+			return o
+		}
+		return 1
+	}
+	return 1
+}
+
+// Orient поворачивает и отражает изображение так, чтобы снимок с ориентацией
+// orientation из EXIF выглядел как задумано.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// src возвращает точку исходного изображения для точки (x, y) результата
+	var src func(x, y int) (int, int)
+	dw, dh := w, h
+	switch orientation {
+	case 2: // отражение по горизонтали
+		src = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // поворот на 180°
+		src = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // отражение по вертикали
+		src = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // отражение относительно главной диагонали
+		src = func(x, y int) (int, int) { return y, x }
+	case 6: // поворот на 90° по часовой стрелке
+		src = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // отражение относительно побочной диагонали
+		src = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // поворот на 90° против часовой стрелки
+		src = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := src(x, y)
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
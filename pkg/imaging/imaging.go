@@ -0,0 +1,119 @@
+// Package imaging готовит загруженные изображения к показу: декодирует JPEG,
+// PNG и GIF, поворачивает снимки по EXIF, обрезает до квадрата по центру
+// и уменьшает до нужных размеров.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // декодер GIF
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/nfnt/resize"
+)
+
+// Ошибки разбора изображения.
+var (
+	ErrFormat   = errors.New("неподдерживаемый формат изображения")
+	ErrTooLarge = errors.New("слишком большое изображение")
+)
+
+// Форматы изображений.
+const (
+	JPEG = "jpeg"
+	PNG  = "png"
+	GIF  = "gif"
+)
+
+// Decode декодирует изображение JPEG, PNG или GIF площадью не больше maxPixels
+// и возвращает его с форматом. Размеры проверяются до декодирования всего файла:
+// это защищает от «бомб». У анимированного GIF берётся первый кадр.
+func Decode(data []byte, maxPixels int) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrFormat
+	}
+	if format != JPEG && format != PNG && format != GIF {
+		return nil, "", ErrFormat
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, "", ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrFormat
+	}
+	return img, format, nil
+}
+
+// Square обрезает изображение до квадрата по центру и приводит к стороне size.
+// Изображения меньше size увеличиваются.
+func Square(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	crop := image.Rect(x, y, x+side, y+side)
+
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		img = sub.SubImage(crop)
+	} else {
+		img = copyRect(img, crop)
+	}
+	return resize.Resize(uint(size), uint(size), img, resize.Lanczos3)
+}
+
+// Squares готовит квадратные варианты изображения со сторонами sizes:
+// декодирует, обрезает, уменьшает и поворачивает по EXIF.
+// Возвращает варианты в порядке sizes и формат исходного изображения.
+func Squares(data []byte, sizes []int, maxPixels int) ([]image.Image, string, error) {
+	img, format, err := Decode(data, maxPixels)
+	if err != nil {
+		return nil, "", err
+	}
+	orientation := 1
+	if format == JPEG {
+		orientation = Orientation(data)
+	}
+
+	// Поворот не меняет квадрата по центру, поэтому поворачиваем уже уменьшенные
+	// варианты: это гораздо дешевле, чем поворачивать исходный снимок
+	res := make([]image.Image, len(sizes))
+	for i, size := range sizes {
+		res[i] = Orient(Square(img, size), orientation)
+	}
+	return res, format, nil
+}
+
+// OutputFormat возвращает формат, в котором сохраняются варианты изображения
+// формата format: JPEG остаётся JPEG, остальные сохраняются в PNG.
+func OutputFormat(format string) string {
+	if format == JPEG {
+		return JPEG
+	}
+	return PNG
+}
+
+// Encode кодирует изображение в формате OutputFormat(format).
+func Encode(w io.Writer, img image.Image, format string) error {
+	if OutputFormat(format) == JPEG {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	}
+	return png.Encode(w, img)
+}
+
+// copyRect копирует прямоугольник r изображения в новое изображение.
+func copyRect(img image.Image, r image.Rectangle) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			dst.Set(x, y, img.At(r.Min.X+x, r.Min.Y+y))
+		}
+	}
+	return dst
+}
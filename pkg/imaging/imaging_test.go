@@ -0,0 +1,201 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// withEXIF вставляет в JPEG сегмент APP1 с ориентацией o.
+func withEXIF(t *testing.T, data []byte, order binary.ByteOrder, o uint16) []byte {
+	t.Helper()
+	var tiff bytes.Buffer
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	binary.Write(&tiff, order, uint16(42))
+	binary.Write(&tiff, order, uint32(8))
+	binary.Write(&tiff, order, uint16(2)) // записей в IFD0
+	// Посторонняя запись перед ориентацией
+	binary.Write(&tiff, order, []uint16{0x010F, 2})
+	binary.Write(&tiff, order, []uint32{0, 0})
+	binary.Write(&tiff, order, []uint16{orientationTag, 3})
+	binary.Write(&tiff, order, uint32(1))
+	binary.Write(&tiff, order, []uint16{o, 0})
+	binary.Write(&tiff, order, uint32(0))
+
+	seg := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(seg)+2))
+	out.Write(seg)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOrientation(t *testing.T) {
+	data := encodeJPEG(t, image.NewGray(image.Rect(0, 0, 8, 8)))
+	if o := Orientation(data); o != 1 {
+		t.Errorf("Orientation() без EXIF = %d, ожидалось 1", o)
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for o := uint16(1); o <= 8; o++ {
+			if got := Orientation(withEXIF(t, data, order, o)); got != int(o) {
+				t.Errorf("Orientation() %v = %d, ожидалось %d", order, got, o)
+			}
+		}
+	}
+	if o := Orientation(withEXIF(t, data, binary.BigEndian, 9)); o != 1 {
+		t.Errorf("Orientation() с неверным значением = %d, ожидалось 1", o)
+	}
+	if o := Orientation(data[:10]); o != 1 {
+		t.Errorf("Orientation() обрезанного файла = %d, ожидалось 1", o)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// Изображение 2x3 с пронумерованными точками:
+	// 1 2
+	// 3 4
+	// 5 6
+	img := image.NewGray(image.Rect(0, 0, 2, 3))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i + 1)
+	}
+	tests := []struct {
+		orientation int
+		want        [][]uint8
+	}{
+		{1, [][]uint8{{1, 2}, {3, 4}, {5, 6}}},
+		{2, [][]uint8{{2, 1}, {4, 3}, {6, 5}}},
+		{3, [][]uint8{{6, 5}, {4, 3}, {2, 1}}},
+		{4, [][]uint8{{5, 6}, {3, 4}, {1, 2}}},
+		{5, [][]uint8{{1, 3, 5}, {2, 4, 6}}},
+		{6, [][]uint8{{5, 3, 1}, {6, 4, 2}}},
+		{7, [][]uint8{{6, 4, 2}, {5, 3, 1}}},
+		{8, [][]uint8{{2, 4, 6}, {1, 3, 5}}},
+	}
+	for _, tt := range tests {
+		got := Orient(img, tt.orientation)
+		b := got.Bounds()
+		if b.Dy() != len(tt.want) || b.Dx() != len(tt.want[0]) {
+			t.Errorf("Orient(%d) размер %v", tt.orientation, b.Size())
+			continue
+		}
+		for y, row := range tt.want {
+			for x, v := range row {
+				if g := color.GrayModel.Convert(got.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y; g != v {
+					t.Errorf("Orient(%d) точка (%d, %d) = %d, ожидалось %d", tt.orientation, x, y, g, v)
+				}
+			}
+		}
+	}
+}
+
+func TestSquare(t *testing.T) {
+	// Широкое изображение: по краям синие полосы, в центре красный квадрат
+	img := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{0, 0, 255, 255}
+			if x >= 100 && x < 200 {
+				c = color.RGBA{255, 0, 0, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	for _, size := range []int{32, 256} {
+		sq := Square(img, size)
+		if b := sq.Bounds(); b.Dx() != size || b.Dy() != size {
+			t.Fatalf("Square(%d) размер %v", size, b.Size())
+		}
+		b := sq.Bounds()
+		for _, p := range []image.Point{b.Min, {b.Max.X - 1, b.Max.Y - 1}, {b.Min.X + size/2, b.Min.Y + size/2}} {
+			if r, _, bl, _ := sq.At(p.X, p.Y).RGBA(); r>>8 < 200 || bl>>8 > 50 {
+				t.Errorf("Square(%d) точка %v не красная: %v", size, p, sq.At(p.X, p.Y))
+			}
+		}
+	}
+}
+
+func TestSquares(t *testing.T) {
+	// Высокий снимок 20x40 с EXIF «поворот на 90°»: верх белый, низ чёрный
+	img := image.NewGray(image.Rect(0, 0, 20, 40))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			img.SetGray(x, y+10, color.Gray{255})
+		}
+	}
+	for y := 20; y < 30; y++ {
+		for x := 0; x < 20; x++ {
+			img.SetGray(x, y, color.Gray{0})
+		}
+	}
+	data := withEXIF(t, encodeJPEG(t, img), binary.BigEndian, 6)
+
+	res, format, err := Squares(data, []int{16, 32}, 1000)
+	if err != nil {
+		t.Fatalf("Squares() = %v", err)
+	}
+	if format != JPEG || OutputFormat(format) != JPEG {
+		t.Errorf("формат %q, OutputFormat %q", format, OutputFormat(format))
+	}
+	if len(res) != 2 || res[0].Bounds().Dx() != 16 || res[1].Bounds().Dx() != 32 {
+		t.Fatalf("Squares() вернул %d вариантов", len(res))
+	}
+	// После поворота по часовой стрелке белая верхняя половина оказывается справа
+	sq := res[1]
+	b := sq.Bounds()
+	left := color.GrayModel.Convert(sq.At(b.Min.X+2, b.Min.Y+16)).(color.Gray).Y
+	right := color.GrayModel.Convert(sq.At(b.Max.X-3, b.Min.Y+16)).(color.Gray).Y
+	if left > 60 || right < 200 {
+		t.Errorf("снимок не повёрнут: слева %d, справа %d", left, right)
+	}
+
+	if _, _, err := Squares(data, []int{16}, 100); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Squares() большого изображения = %v, ожидалась ErrTooLarge", err)
+	}
+	if _, _, err := Squares([]byte("not an image"), []int{16}, 1000); !errors.Is(err, ErrFormat) {
+		t.Errorf("Squares() не изображения = %v, ожидалась ErrFormat", err)
+	}
+}
+
+func TestGIF(t *testing.T) {
+	pal := image.NewPaletted(image.Rect(0, 0, 10, 10), []color.Color{color.Black, color.White})
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, pal, nil); err != nil {
+		t.Fatal(err)
+	}
+	res, format, err := Squares(buf.Bytes(), []int{32}, 1000)
+	if err != nil {
+		t.Fatalf("Squares() GIF = %v", err)
+	}
+	if format != GIF || OutputFormat(format) != PNG {
+		t.Errorf("формат %q, OutputFormat %q", format, OutputFormat(format))
+	}
+	var out bytes.Buffer
+	if err := Encode(&out, res[0], format); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(&out); err != nil {
+		t.Errorf("вариант GIF не в PNG: %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// inline выводит текст блока с выделением, кодом, ссылками и изображениями.
+// Всё остальное экранируется.
+func (r *renderer) inline(b *strings.Builder, s string) {
+	text := 0 // начало ещё не выведенного обычного текста
+	flush := func(end int) {
+		b.WriteString(html.EscapeString(s[text:end]))
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		n := 0 // сколько байт занял разобранный элемент
+		switch c {
+		case '\\':
+			n = r.escape(b, s, i, flush)
+		case '`':
+			j, code, ok := codeSpan(s, i)
+			if !ok {
+				// Серия без пары остаётся текстом целиком
+				i += runLen(s, i)
+				continue
+			}
+			flush(i)
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			n = j - i
+		case '!':
+			if i+1 < len(s) && s[i+1] == '[' {
+				n = r.link(b, s, i, true, flush)
+			}
+		case '[':
+			n = r.link(b, s, i, false, flush)
+		case '<':
+			n = r.autolink(b, s, i, flush)
+		case '*', '_', '~':
+			if n = r.emphasis(b, s, i, flush); n == 0 {
+				i += runLen(s, i)
+				continue
+			}
+		case ' ':
+			n = lineBreak(b, s, i, flush)
+		case 'h':
+			n = r.bareURL(b, s, i, flush)
+		}
+		if n == 0 {
+			i++
+			continue
+		}
+		i += n
+		text = i
+	}
+	flush(len(s))
+}
+
+// escape разбирает обратную косую черту: экранирование знака препинания
+// или перенос строки.
+func (r *renderer) escape(b *strings.Builder, s string, i int, flush func(int)) int {
+	if i+1 >= len(s) {
+		return 0
+	}
+	switch next := s[i+1]; {
+	case next == '\n':
+		flush(i)
+		b.WriteString("<br>\n")
+		return 2
+	case isPunct(next):
+		flush(i)
+		b.WriteString(html.EscapeString(s[i+1 : i+2]))
+		return 2
+	}
+	return 0
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+// codeSpan разбирает код в обратных кавычках, начинающийся в s[i].
+// Возвращает позицию после закрывающих кавычек и текст кода.
+func codeSpan(s string, i int) (int, string, bool) {
+	n := runLen(s, i)
+	for j := i + n; j < len(s); {
+		k := strings.IndexByte(s[j:], '`')
+		if k < 0 {
+			break
+		}
+		j += k
+		m := runLen(s, j)
+		if m == n {
+			code := strings.ReplaceAll(s[i+n:j], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			return j + m, code, true
+		}
+		j += m
+	}
+	return 0, "", false
+}
+
+// runLen возвращает длину серии одинаковых символов, начинающейся в s[i].
+func runLen(s string, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+// lineBreak разбирает пробелы перед переводом строки: два и больше - разрыв строки,
+// остальные отбрасываются.
+func lineBreak(b *strings.Builder, s string, i int, flush func(int)) int {
+	n := runLen(s, i)
+	if i+n >= len(s) || s[i+n] != '\n' {
+		return 0
+	}
+	flush(i)
+	if n >= 2 {
+		b.WriteString("<br>")
+	}
+	return n
+}
+
+// emphasis разбирает выделение: *курсив*, **полужирный**, ***оба*** (или то же
+// с _) и ~~зачёркнутый~~ текст.
+func (r *renderer) emphasis(b *strings.Builder, s string, i int, flush func(int)) int {
+	c := s[i]
+	n := runLen(s, i)
+	// Открывающий разделитель должен стоять перед текстом, а _ - не внутри слова
+	if i+n >= len(s) || isSpace(s[i+n]) || (c == '_' && i > 0 && isWordByte(s[i-1])) {
+		return 0
+	}
+	var sizes []int
+	switch {
+	case c == '~':
+		if n != 2 {
+			return 0
+		}
+		sizes = []int{2}
+	case n >= 3:
+		sizes = []int{3, 2, 1}
+	case n == 2:
+		sizes = []int{2, 1}
+	default:
+		sizes = []int{1}
+	}
+	for _, k := range sizes {
+		end, ok := closer(s, i+n, c, k)
+		if !ok {
+			continue
+		}
+		flush(i)
+		// Лишние разделители открывающей серии остаются текстом
+		b.WriteString(strings.Repeat(string(c), n-k))
+		open, close := emphasisTags(c, k)
+		b.WriteString(open)
+		r.inline(b, s[i+n:end])
+		b.WriteString(close)
+		return end + k - i
+	}
+	return 0
+}
+
+// closer ищет закрывающую серию из не меньше чем k символов c после начала
+// текста from так, чтобы текст не был пустым.
+// Возвращает позицию последних k символов серии.
+func closer(s string, from int, c byte, k int) (int, bool) {
+	for j := from + 1; j < len(s); {
+		switch s[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			if end, _, ok := codeSpan(s, j); ok {
+				j = end
+				continue
+			}
+		case c:
+			m := runLen(s, j)
+			end := j + m - k
+			after := j + m
+			if m >= k && !isSpace(s[j-1]) && end > from && (c != '_' || after == len(s) || !isWordByte(s[after])) {
+				return end, true
+			}
+			j += m
+			continue
+		}
+		j++
+	}
+	return 0, false
+}
+
+func emphasisTags(c byte, k int) (string, string) {
+	switch {
+	case c == '~':
+		return "<del>", "</del>"
+	case k == 3:
+		return "<em><strong>", "</strong></em>"
+	case k == 2:
+		return "<strong>", "</strong>"
+	}
+	return "<em>", "</em>"
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n'
+}
+
+// isWordByte сообщает, является ли байт частью слова. Байты многобайтовых
+// символов UTF-8 считаются буквами.
+func isWordByte(c byte) bool {
+	return c >= utf8.RuneSelf || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// link разбирает ссылку [текст](адрес "заголовок") или изображение ![подпись](адрес).
+func (r *renderer) link(b *strings.Builder, s string, i int, image bool, flush func(int)) int {
+	start := i + 1
+	if image {
+		start++
+	}
+	closeText := matching(s, start-1, '[', ']')
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return 0
+	}
+	closeDest := matching(s, closeText+1, '(', ')')
+	if closeDest < 0 {
+		return 0
+	}
+	if !image && r.inLink {
+		return 0
+	}
+	label := s[start:closeText]
+	dest, title := destination(s[closeText+2 : closeDest])
+
+	url, isImage := dest, image
+	if r.opts.Resolve != nil {
+		if u, img, ok := r.opts.Resolve(dest); ok {
+			url, isImage = u, image && img
+		}
+	}
+	flush(i)
+	switch {
+	case isImage:
+		b.WriteString(`<img src="` + html.EscapeString(url) + `" alt="` + html.EscapeString(plainText(label)) + `"`)
+		if title != "" {
+			b.WriteString(` title="` + html.EscapeString(title) + `"`)
+		}
+		b.WriteString(">")
+	case image:
+		// Изображение, адрес которого ведёт не к изображению, выводится ссылкой на файл
+		b.WriteString(`<a href="` + html.EscapeString(url) + `" download>` + html.EscapeString(plainText(label)) + "</a>")
+	default:
+		b.WriteString(`<a href="` + html.EscapeString(url) + `"`)
+		if title != "" {
+			b.WriteString(` title="` + html.EscapeString(title) + `"`)
+		}
+		b.WriteString(">")
+		r.inLink = true
+		r.inline(b, label)
+		r.inLink = false
+		b.WriteString("</a>")
+	}
+	return closeDest + 1 - i
+}
+
+// matching возвращает позицию скобки close, парной к s[i], или -1.
+// Экранированные скобки и скобки в коде не учитываются.
+func matching(s string, i int, open, close byte) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			if end, _, ok := codeSpan(s, j); ok {
+				j = end - 1
+			}
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// destination разбирает содержимое круглых скобок ссылки: адрес и необязательный
+// заголовок в кавычках. Пробелы в адресе допускаются: так удобнее ссылаться
+// на вложения с пробелами в имени.
+func destination(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	var title string
+	if n := len(s); n >= 2 && (s[n-1] == '"' || s[n-1] == '\'') {
+		if k := strings.LastIndex(s[:n-1], " "+s[n-1:]); k >= 0 {
+			title = unescape(s[k+2 : n-1])
+			s = strings.TrimSpace(s[:k])
+		}
+	}
+	if len(s) >= 2 && s[0] == '<' && s[len(s)-1] == '>' {
+		s = s[1 : len(s)-1]
+	}
+	return unescape(s), title
+}
+
+// unescape убирает обратные косые черты перед знаками препинания.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// plainText возвращает текст без разметки выделения: для подписей изображений.
+func plainText(s string) string {
+	return strings.NewReplacer("**", "", "__", "", "~~", "", "*", "", "`", "").Replace(unescape(s))
+}
+
+// autolink разбирает адрес в угловых скобках: <https://example.com>.
+func (r *renderer) autolink(b *strings.Builder, s string, i int, flush func(int)) int {
+	end := strings.IndexAny(s[i+1:], "<> \n")
+	if end < 0 || s[i+1+end] != '>' || r.inLink {
+		return 0
+	}
+	url := s[i+1 : i+1+end]
+	if !hasScheme(url) {
+		return 0
+	}
+	flush(i)
+	writeLink(b, url)
+	return end + 2
+}
+
+// bareURL разбирает адрес http:// или https:// в тексте.
+func (r *renderer) bareURL(b *strings.Builder, s string, i int, flush func(int)) int {
+	if r.inLink || (i > 0 && isWordByte(s[i-1])) || !strings.HasPrefix(s[i:], "http://") && !strings.HasPrefix(s[i:], "https://") {
+		return 0
+	}
+	end := strings.IndexAny(s[i:], " \n<")
+	if end < 0 {
+		end = len(s) - i
+	}
+	url := s[i : i+end]
+	// Знаки препинания в конце относятся к предложению, а не к адресу
+	for url != "" {
+		last := url[len(url)-1]
+		if last == ')' && strings.Count(url, "(") >= strings.Count(url, ")") {
+			break
+		}
+		if !strings.ContainsRune(".,:;!?)\"'*_~", rune(last)) {
+			break
+		}
+		url = url[:len(url)-1]
+	}
+	if !strings.Contains(strings.SplitN(url, "://", 2)[1], ".") {
+		return 0
+	}
+	flush(i)
+	writeLink(b, url)
+	return len(url)
+}
+
+func hasScheme(url string) bool {
+	for _, p := range []string{"http://", "https://", "mailto:"} {
+		if len(url) > len(p) && strings.EqualFold(url[:len(p)], p) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeLink(b *strings.Builder, url string) {
+	b.WriteString(`<a href="` + html.EscapeString(url) + `">` + html.EscapeString(url) + "</a>")
+}
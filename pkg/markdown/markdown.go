@@ -0,0 +1,441 @@
+// Package markdown преобразует текст публикаций в формате Markdown в HTML.
+// Поддерживаются абзацы, заголовки, цитаты, списки, блоки кода, горизонтальные
+// линии, выделение, ссылки и изображения. HTML в исходном тексте выводится как
+// текст, а результат проходит через Sanitize: в него попадают только разрешённые
+// теги, атрибуты и адреса.
+package markdown
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Options - настройки преобразования.
+type Options struct {
+	// Resolve заменяет адрес ссылки или изображения, например ссылку на вложение
+	// публикации. Возвращает новый адрес и признак того, что по нему изображение.
+	// При ok == false адрес остаётся как есть.
+	Resolve func(dest string) (url string, image bool, ok bool)
+}
+
+// Render преобразует текст Markdown в безопасный HTML.
+func Render(src string, opts Options) string {
+	r := renderer{opts: opts}
+	var b strings.Builder
+	r.blocks(&b, splitLines(src), false)
+	return Sanitize(b.String())
+}
+
+type renderer struct {
+	opts   Options
+	inLink bool // внутри текста ссылки другие ссылки не создаются
+}
+
+// splitLines разбивает текст на строки, приводя переводы строк к \n
+// и заменяя табуляции пробелами до позиций, кратных 4.
+func splitLines(src string) []string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "\t") {
+			continue
+		}
+		var b strings.Builder
+		col := 0
+		for _, c := range line {
+			if c == '\t' {
+				n := 4 - col%4
+				b.WriteString(strings.Repeat(" ", n))
+				col += n
+				continue
+			}
+			b.WriteRune(c)
+			col++
+		}
+		lines[i] = b.String()
+	}
+	return lines
+}
+
+// blocks выводит блоки из строк lines. В «плотном» списке (tight)
+// абзацы выводятся без тегов <p>.
+func (r *renderer) blocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case isFence(line):
+			i = r.fencedCode(b, lines, i)
+		case indent(line) >= 4:
+			i = r.indentedCode(b, lines, i)
+		case headingLevel(line) > 0:
+			r.heading(b, line)
+			i++
+		case isRule(line):
+			b.WriteString("<hr>\n")
+			i++
+		case isQuote(line):
+			i = r.quote(b, lines, i)
+		case listItem(line).ok:
+			i = r.list(b, lines, i)
+		default:
+			i = r.paragraph(b, lines, i, tight)
+		}
+	}
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indent возвращает число пробелов в начале строки.
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// startsBlock сообщает, прерывает ли строка абзац.
+func startsBlock(line string) bool {
+	if isFence(line) || headingLevel(line) > 0 || isRule(line) || isQuote(line) {
+		return true
+	}
+	// Нумерованный список прерывает абзац, только если начинается с 1
+	m := listItem(line)
+	return m.ok && (!m.ordered || m.start == 1)
+}
+
+// Абзац и заголовок, подчёркнутый строкой из = или -.
+func (r *renderer) paragraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	j := i + 1
+	for ; j < len(lines); j++ {
+		line := lines[j]
+		if isBlank(line) {
+			break
+		}
+		if level := setextLevel(line); level > 0 {
+			r.writeHeading(b, level, joinText(lines[i:j]))
+			return j + 1
+		}
+		if startsBlock(line) {
+			break
+		}
+	}
+	text := joinText(lines[i:j])
+	if tight {
+		r.inline(b, text)
+		b.WriteString("\n")
+		return j
+	}
+	b.WriteString("<p>")
+	r.inline(b, text)
+	b.WriteString("</p>\n")
+	return j
+}
+
+// joinText собирает строки абзаца без отступов и пробелов в конце.
+func joinText(lines []string) string {
+	parts := make([]string, len(lines))
+	for i, line := range lines {
+		parts[i] = strings.TrimLeft(line, " ")
+	}
+	return strings.TrimRight(strings.Join(parts, "\n"), " ")
+}
+
+// setextLevel возвращает уровень заголовка для строки подчёркивания: 1 для =, 2 для -.
+func setextLevel(line string) int {
+	if indent(line) > 3 {
+		return 0
+	}
+	s := strings.TrimSpace(line)
+	switch {
+	case s != "" && strings.Trim(s, "=") == "":
+		return 1
+	case s != "" && strings.Trim(s, "-") == "":
+		return 2
+	}
+	return 0
+}
+
+// headingLevel возвращает уровень заголовка вида «## Текст» или 0.
+func headingLevel(line string) int {
+	if indent(line) > 3 {
+		return 0
+	}
+	s := strings.TrimLeft(line, " ")
+	n := len(s) - len(strings.TrimLeft(s, "#"))
+	if n < 1 || n > 6 || (len(s) > n && s[n] != ' ') {
+		return 0
+	}
+	return n
+}
+
+func (r *renderer) heading(b *strings.Builder, line string) {
+	level := headingLevel(line)
+	text := strings.TrimSpace(strings.TrimLeft(line, " ")[level:])
+	// Закрывающие решётки: «## Заголовок ##»
+	if t := strings.TrimRight(text, "#"); t == "" || strings.HasSuffix(t, " ") {
+		text = strings.TrimSpace(t)
+	}
+	r.writeHeading(b, level, text)
+}
+
+func (r *renderer) writeHeading(b *strings.Builder, level int, text string) {
+	tag := "h" + strconv.Itoa(level)
+	b.WriteString("<" + tag + ">")
+	r.inline(b, text)
+	b.WriteString("</" + tag + ">\n")
+}
+
+// isRule сообщает, является ли строка горизонтальной линией: три и больше
+// одинаковых символа -, * или _, возможно через пробелы.
+func isRule(line string) bool {
+	if indent(line) > 3 {
+		return false
+	}
+	s := strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	if len(s) < 3 {
+		return false
+	}
+	return strings.Trim(s, s[:1]) == "" && strings.ContainsAny(s[:1], "-*_")
+}
+
+func isQuote(line string) bool {
+	return indent(line) <= 3 && strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+// Цитата: строки с > и продолжающие абзац строки без него.
+func (r *renderer) quote(b *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isQuote(line) {
+			s := strings.TrimLeft(line, " ")[1:]
+			inner = append(inner, strings.TrimPrefix(s, " "))
+			continue
+		}
+		if isBlank(line) || startsBlock(line) || len(inner) == 0 || isBlank(inner[len(inner)-1]) {
+			break
+		}
+		inner = append(inner, line)
+	}
+	b.WriteString("<blockquote>\n")
+	r.blocks(b, inner, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+// Признак элемента списка.
+type marker struct {
+	ok      bool
+	ordered bool
+	start   int  // номер первого элемента нумерованного списка
+	delim   byte // -, *, + или . и ) после номера
+	width   int  // отступ текста элемента от начала строки
+}
+
+// listItem разбирает начало элемента списка: «- текст» или «1. текст».
+func listItem(line string) marker {
+	sp := indent(line)
+	if sp > 3 {
+		return marker{}
+	}
+	s := line[sp:]
+	m := marker{ok: true}
+	n := 0
+	switch {
+	case s != "" && strings.ContainsAny(s[:1], "-*+"):
+		m.delim, n = s[0], 1
+	default:
+		for n < len(s) && n < 9 && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		if n == 0 || n >= len(s) || (s[n] != '.' && s[n] != ')') {
+			return marker{}
+		}
+		m.ordered, m.delim = true, s[n]
+		m.start, _ = strconv.Atoi(s[:n])
+		n++
+	}
+	rest := s[n:]
+	if rest != "" && rest[0] != ' ' {
+		return marker{}
+	}
+	gap := indent(rest)
+	if gap == 0 || gap > 4 || isBlank(rest) {
+		gap = 1
+	}
+	m.width = sp + n + gap
+	return m
+}
+
+// Список: элементы одного вида подряд. Элементы, разделённые пустыми
+// строками, выводятся абзацами, иначе список «плотный».
+func (r *renderer) list(b *strings.Builder, lines []string, i int) int {
+	first := listItem(lines[i])
+	var items [][]string
+	loose := false
+	for i < len(lines) {
+		m := listItem(lines[i])
+		if !m.ok || m.ordered != first.ordered || m.delim != first.delim {
+			break
+		}
+		var item []string
+		if len(lines[i]) > m.width {
+			item = append(item, lines[i][m.width:])
+		} else {
+			item = append(item, "")
+		}
+		i++
+		for i < len(lines) {
+			line := lines[i]
+			if isBlank(line) {
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j == len(lines) || indent(lines[j]) < m.width {
+					break
+				}
+				for ; i < j; i++ {
+					item = append(item, "")
+				}
+				loose = true
+				continue
+			}
+			if indent(line) >= m.width {
+				item = append(item, line[m.width:])
+				i++
+				continue
+			}
+			// Продолжение абзаца без отступа
+			if !startsBlock(line) && !isBlank(item[len(item)-1]) {
+				item = append(item, line)
+				i++
+				continue
+			}
+			break
+		}
+		items = append(items, item)
+
+		// Пустые строки перед следующим элементом делают список «свободным»
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j > i {
+			if j == len(lines) {
+				i = j
+				break
+			}
+			if next := listItem(lines[j]); !next.ok || next.ordered != first.ordered || next.delim != first.delim {
+				break
+			}
+			loose = true
+			i = j
+		}
+	}
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+	if first.ordered && first.start != 1 {
+		b.WriteString("<ol start=\"" + strconv.Itoa(first.start) + "\">\n")
+	} else {
+		b.WriteString("<" + tag + ">\n")
+	}
+	for _, item := range items {
+		var inner strings.Builder
+		r.blocks(&inner, item, !loose)
+		b.WriteString("<li>")
+		b.WriteString(strings.TrimSuffix(inner.String(), "\n"))
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// fence возвращает строку ограничителя блока кода (``` или ~~~) и язык.
+func fence(line string) (string, string, bool) {
+	if indent(line) > 3 {
+		return "", "", false
+	}
+	s := strings.TrimLeft(line, " ")
+	if len(s) < 3 || (s[0] != '`' && s[0] != '~') {
+		return "", "", false
+	}
+	n := len(s) - len(strings.TrimLeft(s, s[:1]))
+	if n < 3 {
+		return "", "", false
+	}
+	info := strings.TrimSpace(s[n:])
+	if s[0] == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+	lang, _, _ := strings.Cut(info, " ")
+	return s[:n], lang, true
+}
+
+func isFence(line string) bool {
+	_, _, ok := fence(line)
+	return ok
+}
+
+// Блок кода между строками ``` или ~~~. Без закрывающей строки
+// блок продолжается до конца текста.
+func (r *renderer) fencedCode(b *strings.Builder, lines []string, i int) int {
+	open, lang, _ := fence(lines[i])
+	sp := indent(lines[i])
+	var code []string
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if s := strings.TrimSpace(line); indent(line) <= 3 && strings.HasPrefix(s, open) && strings.Trim(s, open[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, line[min(sp, indent(line)):])
+	}
+	writeCode(b, code, lang)
+	return i
+}
+
+// Блок кода с отступом в 4 пробела.
+func (r *renderer) indentedCode(b *strings.Builder, lines []string, i int) int {
+	var code []string
+	for ; i < len(lines) && (isBlank(lines[i]) || indent(lines[i]) >= 4); i++ {
+		code = append(code, lines[i][min(4, len(lines[i])):])
+	}
+	for len(code) > 0 && isBlank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+	}
+	writeCode(b, code, "")
+	return i
+}
+
+func writeCode(b *strings.Builder, code []string, lang string) {
+	b.WriteString("<pre><code")
+	if validLang(lang) {
+		b.WriteString(` class="language-` + lang + `"`)
+	}
+	b.WriteString(">")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line))
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+// validLang сообщает, годится ли язык блока кода для имени класса.
+func validLang(lang string) bool {
+	if lang == "" || len(lang) > 32 {
+		return false
+	}
+	for _, c := range lang {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("+#_-", c)) {
+			return false
+		}
+	}
+	return true
+}
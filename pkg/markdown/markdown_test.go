@@ -0,0 +1,103 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"абзацы", "Первый\nабзац\n\nВторой", "<p>Первый\nабзац</p>\n<p>Второй</p>\n"},
+		{"заголовки", "# Один\n### Три ###\nТекст\n---", "<h1>Один</h1>\n<h3>Три</h3>\n<h2>Текст</h2>\n"},
+		{"не заголовок", "#хештег", "<p>#хештег</p>\n"},
+		{"выделение", "*к* **ж** ***оба*** ~~з~~ **a *b* c**",
+			"<p><em>к</em> <strong>ж</strong> <em><strong>оба</strong></em> <del>з</del> <strong>a <em>b</em> c</strong></p>\n"},
+		{"подчёркивания в словах", "snake_case_name и _курсив_", "<p>snake_case_name и <em>курсив</em></p>\n"},
+		{"без пары", "2 * 3 = 6, a ** b", "<p>2 * 3 = 6, a ** b</p>\n"},
+		{"код", "`a <b>` и ``c ` d``", "<p><code>a &lt;b&gt;</code> и <code>c ` d</code></p>\n"},
+		{"экранирование", `\*не курсив\* \[не ссылка\]`, "<p>*не курсив* [не ссылка]</p>\n"},
+		{"перенос строки", "раз  \nдва\\\nтри", "<p>раз<br>\nдва<br>\nтри</p>\n"},
+		{"ссылка", `[сайт](https://example.com "Пример")`,
+			`<p><a href="https://example.com" title="Пример" rel="nofollow noopener">сайт</a></p>` + "\n"},
+		{"относительная ссылка", "[пост](/post/1)", `<p><a href="/post/1">пост</a></p>` + "\n"},
+		{"изображение", "![кот *рыжий*](/cat.png)", `<p><img src="/cat.png" alt="кот рыжий"></p>` + "\n"},
+		{"адрес в тексте", "См. https://go.dev/doc.", `<p>См. <a href="https://go.dev/doc" rel="nofollow noopener">https://go.dev/doc</a>.</p>` + "\n"},
+		{"адрес в скобках", "<mailto:a@example.com>", `<p><a href="mailto:a@example.com">mailto:a@example.com</a></p>` + "\n"},
+		{"цитата", "> раз\nдва\n\nтри", "<blockquote>\n<p>раз\nдва</p>\n</blockquote>\n<p>три</p>\n"},
+		{"список", "- раз\n- два\n  - вложенный\n* другой",
+			"<ul>\n<li>раз</li>\n<li>два\n<ul>\n<li>вложенный</li>\n</ul></li>\n</ul>\n<ul>\n<li>другой</li>\n</ul>\n"},
+		{"свободный список", "3. раз\n\n4. два", "<ol start=\"3\">\n<li><p>раз</p></li>\n<li><p>два</p></li>\n</ol>\n"},
+		{"блок кода", "```go\nif a < b {\n\treturn\n}\n```", "<pre><code class=\"language-go\">if a &lt; b {\n    return\n}\n</code></pre>\n"},
+		{"код с отступом", "    x := 1\n\n    y := 2\n\nтекст", "<pre><code>x := 1\n\ny := 2\n</code></pre>\n<p>текст</p>\n"},
+		{"незакрытый блок кода", "~~~\n*код*", "<pre><code>*код*\n</code></pre>\n"},
+		{"линия", "***\n- - -", "<hr>\n<hr>\n"},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.src, Options{}); got != tt.want {
+			t.Errorf("%s: Render(%q) =\n%s\nожидалось\n%s", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestRenderUnsafe(t *testing.T) {
+	tests := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`[x](javascript:alert(1))`,
+		`[x](JavaScript:alert(1))`,
+		"[x](java\tscript:alert(1))",
+		`![x](data:text/html;base64,PHNjcmlwdD4=)`,
+		`[x](https://example.com "a\" onmouseover=\"alert(1)")`,
+		`<javascript:alert(1)>`,
+	}
+	for _, src := range tests {
+		got := Render(src, Options{})
+		for _, bad := range []string{"<script", "<img", `href="j`, `href="J`, `src="data`, `" onmouseover`} {
+			if strings.Contains(got, bad) {
+				t.Errorf("Render(%q) = %q: содержит %q", src, got, bad)
+			}
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	opts := Options{Resolve: func(dest string) (string, bool, bool) {
+		switch dest {
+		case "attachment:кот.png":
+			return "/static/posts/1/a.png", true, true
+		case "attachment:отчёт 2024.pdf":
+			return "/static/posts/1/b.pdf", false, true
+		}
+		return "", false, false
+	}}
+	src := "![Кот](attachment:кот.png) ![Отчёт](attachment:отчёт 2024.pdf) ![нет](attachment:нет.png)"
+	want := `<p><img src="/static/posts/1/a.png" alt="Кот"> <a href="/static/posts/1/b.pdf" download="">Отчёт</a> </p>` + "\n"
+	if got := Render(src, opts); got != want {
+		t.Errorf("Render() =\n%s\nожидалось\n%s", got, want)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`<p onclick="x()">a</p>`, `<p>a</p>`},
+		{`<div><b>a</b></div>`, `a`},
+		{`<STYLE>p{}</style>b`, `b`},
+		{`<!-- комментарий -->a`, `a`},
+		{`<em>a<strong>b</em>`, `<em>a<strong>b</strong></em>`},
+		{`</p>a<ul><li>b`, `a<ul><li>b</li></ul>`},
+		{`a < b & c`, `a &lt; b &amp; c`},
+		{`<a href="//evil.example">x</a>`, `<a href="//evil.example" rel="nofollow noopener">x</a>`},
+		{`<code class="language-go x">a</code>`, `<code>a</code>`},
+		{`<img alt="нет адреса">`, ``},
+		{`<ol start="x1">`, `<ol></ol>`},
+	}
+	for _, tt := range tests {
+		if got := Sanitize(tt.src); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, ожидалось %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Разрешённые теги и их атрибуты. Остальные теги отбрасываются, а их текст остаётся.
+var allowedTags = map[string][]string{
+	"p": nil, "br": nil, "hr": nil, "blockquote": nil, "pre": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"strong": nil, "em": nil, "del": nil,
+	"ul": nil, "ol": {"start"}, "li": nil,
+	"code": {"class"},
+	"a":    {"href", "title", "download"},
+	"img":  {"src", "alt", "title"},
+}
+
+// Теги без закрывающего тега.
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// Теги, которые отбрасываются вместе с содержимым.
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"textarea": true, "title": true, "template": true, "noscript": true, "svg": true, "math": true,
+}
+
+// Схемы, разрешённые в адресах ссылок и изображений. Адреса без схемы
+// (относительные) разрешены.
+var allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Значения атрибутов, которые проверяются отдельно.
+var (
+	langClass = regexp.MustCompile(`^language-[A-Za-z0-9+#_-]{1,32}$`)
+	number    = regexp.MustCompile(`^[0-9]{1,9}$`)
+)
+
+// Sanitize оставляет в HTML только разрешённые теги и атрибуты, отбрасывает
+// адреса с опасными схемами (javascript:, data: и другие), заново экранирует
+// текст и закрывает незакрытые теги.
+func Sanitize(s string) string {
+	var b strings.Builder
+	var open []string // открытые теги
+	for s != "" {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			writeText(&b, s)
+			break
+		}
+		writeText(&b, s[:i])
+		s = s[i:]
+
+		if strings.HasPrefix(s, "<!--") {
+			end := strings.Index(s[4:], "-->")
+			if end < 0 {
+				break
+			}
+			s = s[4+end+3:]
+			continue
+		}
+		t, rest, ok := parseTag(s)
+		if !ok {
+			b.WriteString("&lt;")
+			s = s[1:]
+			continue
+		}
+		s = rest
+
+		switch {
+		case droppedTags[t.name]:
+			if !t.closing && !t.selfClosing {
+				s = skipElement(s, t.name)
+			}
+		case !allowed(t.name):
+			// Неразрешённый тег: выводим только содержимое
+		case t.closing:
+			for k := len(open) - 1; k >= 0; k-- {
+				if open[k] != t.name {
+					continue
+				}
+				for len(open) > k {
+					b.WriteString("</" + open[len(open)-1] + ">")
+					open = open[:len(open)-1]
+				}
+				break
+			}
+		default:
+			attrs, ok := allowedAttrs(t)
+			if !ok {
+				continue
+			}
+			b.WriteString("<" + t.name + attrs + ">")
+			if !voidTags[t.name] {
+				open = append(open, t.name)
+			}
+		}
+	}
+	for k := len(open) - 1; k >= 0; k-- {
+		b.WriteString("</" + open[k] + ">")
+	}
+	return b.String()
+}
+
+func allowed(name string) bool {
+	_, ok := allowedTags[name]
+	return ok
+}
+
+// writeText выводит текст, экранируя его заново.
+func writeText(b *strings.Builder, s string) {
+	b.WriteString(html.EscapeString(html.UnescapeString(s)))
+}
+
+// Разобранный тег.
+type tag struct {
+	name        string
+	closing     bool
+	selfClosing bool
+	attrs       [][2]string
+}
+
+// parseTag разбирает тег в начале s и возвращает его и остаток строки.
+func parseTag(s string) (tag, string, bool) {
+	var t tag
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		t.closing = true
+		i++
+	}
+	start := i
+	for i < len(s) && (isLetter(s[i]) || (i > start && s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	if i == start {
+		return tag{}, "", false
+	}
+	t.name = strings.ToLower(s[start:i])
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '>':
+			return t, s[i+1:], true
+		case c == '/':
+			t.selfClosing = true
+			i++
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r\f/>=", rune(s[i])) {
+				i++
+			}
+			name := strings.ToLower(s[start:i])
+			for i < len(s) && strings.ContainsRune(" \t\n\r\f", rune(s[i])) {
+				i++
+			}
+			var value string
+			if i < len(s) && s[i] == '=' {
+				i++
+				for i < len(s) && strings.ContainsRune(" \t\n\r\f", rune(s[i])) {
+					i++
+				}
+				if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+					end := strings.IndexByte(s[i+1:], s[i])
+					if end < 0 {
+						return tag{}, "", false
+					}
+					value = s[i+1 : i+1+end]
+					i += end + 2
+				} else {
+					start := i
+					for i < len(s) && !strings.ContainsRune(" \t\n\r\f>", rune(s[i])) {
+						i++
+					}
+					value = s[start:i]
+				}
+			}
+			t.attrs = append(t.attrs, [2]string{name, html.UnescapeString(value)})
+		}
+	}
+	return tag{}, "", false
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// skipElement пропускает содержимое отбрасываемого элемента name
+// вместе с закрывающим тегом.
+func skipElement(s, name string) string {
+	lower := strings.ToLower(s)
+	i := strings.Index(lower, "</"+name)
+	if i < 0 {
+		return ""
+	}
+	end := strings.IndexByte(s[i:], '>')
+	if end < 0 {
+		return ""
+	}
+	return s[i+end+1:]
+}
+
+// allowedAttrs возвращает разрешённые атрибуты тега для вывода. Изображение
+// без допустимого адреса не выводится вовсе.
+func allowedAttrs(t tag) (string, bool) {
+	var b strings.Builder
+	hasSrc := false
+	external := false
+	for _, a := range t.attrs {
+		name, value := a[0], a[1]
+		if !slices.Contains(allowedTags[t.name], name) {
+			continue
+		}
+		switch name {
+		case "href", "src":
+			u, ok := safeURL(value)
+			if !ok {
+				continue
+			}
+			hasSrc = hasSrc || name == "src"
+			external = external || (name == "href" && u.Host != "")
+		case "class":
+			if !langClass.MatchString(value) {
+				continue
+			}
+		case "start":
+			if !number.MatchString(value) {
+				continue
+			}
+		}
+		b.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	if t.name == "img" && !hasSrc {
+		return "", false
+	}
+	// Ссылки на другие сайты не передают им вес страницы
+	if external {
+		b.WriteString(` rel="nofollow noopener"`)
+	}
+	return b.String(), true
+}
+
+// safeURL проверяет адрес: относительный или с разрешённой схемой.
+func safeURL(s string) (*url.URL, bool) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || (u.Scheme != "" && !allowedSchemes[u.Scheme]) {
+		return nil, false
+	}
+	return u, true
+}
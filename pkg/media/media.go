@@ -0,0 +1,63 @@
+// Package media хранит загруженные файлы: аватарки и вложения публикаций.
+// Хранилище выбирается при запуске: каталог на диске (FS) или
+// S3-совместимое объектное хранилище (S3).
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNotFound - файла с таким ключом нет.
+var ErrNotFound = errors.New("файл не найден")
+
+// ErrInvalidKey - недопустимый ключ файла.
+var ErrInvalidKey = errors.New("недопустимый ключ файла")
+
+// Info - сведения о сохранённом файле.
+type Info struct {
+	Size        int64
+	ContentType string
+}
+
+// Store - хранилище файлов. Ключ - относительный путь вида avatars/av_1.png.
+type Store interface {
+	// Put сохраняет файл под ключом key, заменяя существующий.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get открывает файл для чтения. Для отсутствующего файла возвращает ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	// Delete удаляет файл. Отсутствие файла ошибкой не считается.
+	Delete(ctx context.Context, key string) error
+	// URL возвращает адрес, по которому файл доступен браузеру.
+	URL(key string) string
+}
+
+// ValidKey сообщает, подходит ли ключ: непустые части из латинских букв, цифр,
+// «.», «_» и «-», разделённые «/», без «.» и «..».
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 512 {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+		for _, c := range part {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// KeyFromURL возвращает ключ файла хранилища s по его адресу.
+func KeyFromURL(s Store, url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, s.URL(""))
+	if !ok || !ValidKey(key) {
+		return "", false
+	}
+	return key, true
+}
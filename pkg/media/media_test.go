@@ -0,0 +1,76 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	for _, key := range []string{"a.png", "avatars/av_0f.png", "posts/12/file-1.JPG"} {
+		if !ValidKey(key) {
+			t.Errorf("ValidKey(%q) = false", key)
+		}
+	}
+	for _, key := range []string{"", "/a.png", "a/", "a//b", "../a", "a/./b", "a b", "a\\b", "файл.png", "a?b"} {
+		if ValidKey(key) {
+			t.Errorf("ValidKey(%q) = true", key)
+		}
+	}
+}
+
+// testStore проверяет, что хранилище s выполняет контракт Store.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	const key = "avatars/av_test.png"
+
+	if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() отсутствующего файла = %v, ожидалась ErrNotFound", err)
+	}
+	if err := s.Put(ctx, key, bytes.NewReader([]byte("first")), "image/png"); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if err := s.Put(ctx, key, bytes.NewReader([]byte("second")), "image/png"); err != nil {
+		t.Fatalf("Put() поверх файла = %v", err)
+	}
+
+	rc, info, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "second" {
+		t.Errorf("Get() прочитано %q, %v; ожидалось %q", data, err, "second")
+	}
+	if info.Size != int64(len("second")) || info.ContentType != "image/png" {
+		t.Errorf("Get() info = %+v", info)
+	}
+
+	if got, ok := KeyFromURL(s, s.URL(key)); !ok || got != key {
+		t.Errorf("KeyFromURL(URL(%q)) = %q, %v", key, got, ok)
+	}
+	if _, ok := KeyFromURL(s, "https://elsewhere.example/"+key); ok {
+		t.Error("KeyFromURL() принял чужой адрес")
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() после Delete() = %v, ожидалась ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete() отсутствующего файла = %v", err)
+	}
+
+	if err := s.Put(ctx, "../escape.png", bytes.NewReader(nil), ""); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put() с ключом ../escape.png = %v, ожидалась ErrInvalidKey", err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	testStore(t, NewFS(t.TempDir(), "/media/"))
+}
@@ -0,0 +1,261 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options - настройки подключения к S3-совместимому хранилищу (AWS S3, MinIO и др.).
+type S3Options struct {
+	Endpoint  string // адрес сервиса, например http://localhost:9000
+	Bucket    string // корзина; должна существовать
+	Region    string // регион для подписи запросов (по умолчанию us-east-1)
+	AccessKey string
+	SecretKey string
+	PublicURL string // адрес, по которому браузер читает файлы (по умолчанию Endpoint/Bucket)
+	Client    *http.Client
+}
+
+// S3 хранит файлы в корзине S3-совместимого хранилища. Запросы адресуются
+// в стиле path (endpoint/bucket/key) и подписываются по AWS Signature V4.
+type S3 struct {
+	endpoint *url.URL
+	opts     S3Options
+}
+
+// NewS3 создаёт клиент хранилища S3.
+func NewS3(opts S3Options) (*S3, error) {
+	u, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("неверный адрес S3: %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("не задана корзина S3")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: time.Minute}
+	}
+	if opts.PublicURL == "" {
+		opts.PublicURL = u.String() + "/" + opts.Bucket
+	}
+	opts.PublicURL = strings.TrimRight(opts.PublicURL, "/") + "/"
+	return &S3{endpoint: u, opts: opts}, nil
+}
+
+// Put загружает файл целиком: размер файлов ограничен на входе в API,
+// а подписи нужен хеш содержимого.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := s.do(req, body)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	if !ValidKey(key) {
+		return nil, Info{}, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	req, err := s.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	res, err := s.do(req, nil)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return res.Body, Info{Size: res.ContentLength, ContentType: res.Header.Get("Content-Type")}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	req, err := s.request(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(req, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s *S3) URL(key string) string {
+	return s.opts.PublicURL + key
+}
+
+// request создаёт запрос к объекту key.
+func (s *S3) request(ctx context.Context, method, key string, body []byte) (*http.Request, error) {
+	u := *s.endpoint
+	u.Path = u.Path + "/" + s.opts.Bucket + "/" + key
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	return http.NewRequestWithContext(ctx, method, u.String(), r)
+}
+
+// do подписывает и выполняет запрос. Ответы кроме 2xx превращаются в ошибки,
+// 404 - в ErrNotFound.
+func (s *S3) do(req *http.Request, body []byte) (*http.Response, error) {
+	s.sign(req, body, time.Now())
+	res, err := s.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", req.URL.Path, ErrNotFound)
+	}
+	return nil, fmt.Errorf("S3 %s %s: %s: %s", req.Method, req.URL.Path, res.Status, bytes.TrimSpace(msg))
+}
+
+// Подпись запросов AWS Signature Version 4.
+const (
+	sigAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFmt   = "20060102T150405Z"
+)
+
+// sign добавляет к запросу заголовки X-Amz-Date, X-Amz-Content-Sha256 и Authorization.
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFmt)
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if body != nil {
+		req.ContentLength = int64(len(body))
+	}
+
+	canonical, signed := canonicalRequest(req, hex.EncodeToString(payload[:]))
+	req.Header.Set("Authorization", sigAlgorithm+" Credential="+s.opts.AccessKey+"/"+s.scope(amzDate)+
+		", SignedHeaders="+signed+", Signature="+s.signature(canonical, amzDate))
+}
+
+// scope - область действия подписи: дата, регион и сервис.
+func (s *S3) scope(amzDate string) string {
+	return amzDate[:8] + "/" + s.opts.Region + "/s3/aws4_request"
+}
+
+// signature подписывает каноническую форму запроса ключом, производным от секретного.
+func (s *S3) signature(canonical, amzDate string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := sigAlgorithm + "\n" + amzDate + "\n" + s.scope(amzDate) + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretKey), amzDate[:8])
+	key = hmacSHA256(key, s.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+// canonicalRequest строит каноническую форму запроса и список подписанных заголовков.
+// Подписываются Host, Content-Type, Range и все заголовки X-Amz-*.
+func canonicalRequest(req *http.Request, payloadHash string) (string, string) {
+	headers := map[string]string{"host": req.Host}
+	if headers["host"] == "" {
+		headers["host"] = req.URL.Host
+	}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var hb strings.Builder
+	for _, name := range names {
+		hb.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var qs []string
+	for _, k := range keys {
+		vs := query[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			qs = append(qs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		strings.Join(qs, "&"),
+		hb.String(),
+		signed,
+		payloadHash,
+	}, "\n"), signed
+}
+
+// uriEncode кодирует строку по правилам AWS: не кодируются только
+// латинские буквы, цифры и «-_.~», а в пути ещё и «/».
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,89 @@
+// Package rbac описывает роли пользователей GoNews и права, которые они дают.
+package rbac
+
+import "fmt"
+
+// Role - роль учётной записи.
+type Role string
+
+const (
+	Admin  Role = "admin"  // управляет авторами и ролями, меняет любые публикации
+	Editor Role = "editor" // меняет и удаляет любые публикации
+	Author Role = "author" // публикует и меняет только свои публикации
+	Reader Role = "reader" // только читает
+)
+
+// Roles - все роли от старшей к младшей.
+var Roles = []Role{Admin, Editor, Author, Reader}
+
+// Parse проверяет название роли.
+func Parse(s string) (Role, error) {
+	r := Role(s)
+	if !r.Valid() {
+		return "", fmt.Errorf("неизвестная роль %q: ожидается admin, editor, author или reader", s)
+	}
+	return r, nil
+}
+
+// Valid сообщает, что роль известна.
+func (r Role) Valid() bool {
+	_, ok := grants[r]
+	return ok
+}
+
+// Title - название роли для интерфейса.
+func (r Role) Title() string {
+	switch r {
+	case Admin:
+		return "Администратор"
+	case Editor:
+		return "Редактор"
+	case Author:
+		return "Автор"
+	case Reader:
+		return "Читатель"
+	}
+	return string(r)
+}
+
+// Permission - право на действие.
+type Permission string
+
+const (
+	CreatePost     Permission = "posts:create"   // публиковать от своего имени
+	EditOwnPosts   Permission = "posts:edit-own" // менять и удалять свои публикации
+	EditAnyPost    Permission = "posts:edit-any" // менять и удалять чужие публикации
+	EditOwnProfile Permission = "profile:edit"   // менять имя и аватарку, удалять свою учётную запись
+	ManageAuthors  Permission = "authors:manage" // создавать, менять и удалять любых авторов
+	ManageRoles    Permission = "roles:manage"   // назначать роли
+)
+
+// Права ролей. Роль без записи не даёт никаких прав.
+var grants = map[Role][]Permission{
+	Admin:  {CreatePost, EditOwnPosts, EditAnyPost, EditOwnProfile, ManageAuthors, ManageRoles},
+	Editor: {CreatePost, EditOwnPosts, EditAnyPost, EditOwnProfile},
+	Author: {CreatePost, EditOwnPosts, EditOwnProfile},
+	Reader: {EditOwnProfile},
+}
+
+// Can сообщает, даёт ли роль право p.
+func (r Role) Can(p Permission) bool {
+	for _, g := range grants[r] {
+		if g == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEditPost сообщает, может ли пользователь с ролью r и ID автора actor
+// менять и удалять публикацию автора owner.
+func CanEditPost(r Role, actor, owner int) bool {
+	return r.Can(EditAnyPost) || actor == owner && r.Can(EditOwnPosts)
+}
+
+// CanEditAuthor сообщает, может ли пользователь с ролью r и ID автора actor
+// менять и удалять автора author.
+func CanEditAuthor(r Role, actor, author int) bool {
+	return r.Can(ManageAuthors) || actor == author && r.Can(EditOwnProfile)
+}
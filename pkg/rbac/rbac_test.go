@@ -0,0 +1,69 @@
+package rbac
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	for _, r := range Roles {
+		got, err := Parse(string(r))
+		if err != nil || got != r {
+			t.Errorf("Parse(%q) = %q, %v", r, got, err)
+		}
+	}
+	for _, s := range []string{"", "root", "Admin"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): ожидалась ошибка", s)
+		}
+	}
+}
+
+func TestCanEditPost(t *testing.T) {
+	const me, other = 1, 2
+	tests := []struct {
+		role       Role
+		own, alien bool
+	}{
+		{Admin, true, true},
+		{Editor, true, true},
+		{Author, true, false},
+		{Reader, false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		if got := CanEditPost(tt.role, me, me); got != tt.own {
+			t.Errorf("%q: своя публикация = %v, ожидалось %v", tt.role, got, tt.own)
+		}
+		if got := CanEditPost(tt.role, me, other); got != tt.alien {
+			t.Errorf("%q: чужая публикация = %v, ожидалось %v", tt.role, got, tt.alien)
+		}
+	}
+}
+
+func TestCanEditAuthor(t *testing.T) {
+	const me, other = 1, 2
+	tests := []struct {
+		role       Role
+		own, alien bool
+	}{
+		{Admin, true, true},
+		{Editor, true, false},
+		{Author, true, false},
+		{Reader, true, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		if got := CanEditAuthor(tt.role, me, me); got != tt.own {
+			t.Errorf("%q: свой профиль = %v, ожидалось %v", tt.role, got, tt.own)
+		}
+		if got := CanEditAuthor(tt.role, me, other); got != tt.alien {
+			t.Errorf("%q: чужой профиль = %v, ожидалось %v", tt.role, got, tt.alien)
+		}
+	}
+}
+
+func TestOnlyAdminManagesRoles(t *testing.T) {
+	for _, r := range Roles {
+		if got := r.Can(ManageRoles); got != (r == Admin) {
+			t.Errorf("%q: ManageRoles = %v", r, got)
+		}
+	}
+}
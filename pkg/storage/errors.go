@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Ошибки, которые возвращают все реализации Interface.
+// Реализации оборачивают их с подробностями, поэтому проверять
+// следует через errors.Is.
+var (
+	ErrNotFound = errors.New("не найдено")          // запись с таким ID отсутствует
+	ErrConflict = errors.New("конфликт данных")     // нарушена уникальность или связи между записями
+	ErrInvalid  = errors.New("некорректные данные") // данные не прошли проверку хранилища
+)
+
+// ErrLastAdmin - вид ErrConflict: SetRole и DeleteAuthor не снимают роль
+// с последнего администратора и не удаляют его, иначе роли станет некому назначать.
+var ErrLastAdmin = fmt.Errorf("последний администратор: %w", ErrConflict)
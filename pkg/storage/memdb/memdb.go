@@ -1,38 +1,652 @@
 package memdb
 
-import "GoNews/pkg/storage"
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 
-// Хранилище данных.
-type Store struct{}
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+)
+
+// Хранилище данных в памяти процесса.
+// Безопасно для одновременного использования из нескольких горутин.
+type Store struct {
+	mu           sync.RWMutex
+	posts        map[int]storage.Post
+	authors      map[int]storage.Author
+	accounts     map[int]storage.Account // по ID автора
+	tokens       map[int]storage.Token
+	attachments  map[int]storage.Attachment
+	revisions    map[int][]storage.Revision // по ID публикации
+	lastPostID   int
+	lastAuthorID int
+	lastTokenID  int
+	lastAttachID int
+	lastRevID    int
+}
 
 // Конструктор объекта хранилища.
 func New() *Store {
-	return new(Store)
+	return &Store{
+		posts:       make(map[int]storage.Post),
+		authors:     make(map[int]storage.Author),
+		accounts:    make(map[int]storage.Account),
+		tokens:      make(map[int]storage.Token),
+		attachments: make(map[int]storage.Attachment),
+		revisions:   make(map[int][]storage.Revision),
+	}
+}
+
+// Posts возвращает страницу публикаций вместе с их авторами.
+func (s *Store) Posts(ctx context.Context, q storage.PostsQuery) (storage.PostsPage, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.PostsPage{}, err
+	}
+
+	s.mu.RLock()
+	all := make([]storage.Post, 0, len(s.posts))
+	for _, p := range s.posts {
+		a, ok := s.authors[p.AuthorID]
+		if !ok {
+			// как и JOIN в PostgreSQL, пропускаем публикации без автора
+			continue
+		}
+		p.Author = a
+		p.FormattedDate = storage.FormatDate(p.CreatedAt)
+		all = append(all, p)
+	}
+	s.mu.RUnlock()
+
+	return query(all, q)
+}
+
+// Добавление публикации
+func (s *Store) AddPost(ctx context.Context, p storage.Post) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.authors[p.AuthorID]; !ok {
+		return 0, fmt.Errorf("автор %d: %w", p.AuthorID, storage.ErrInvalid)
+	}
+	if p.CreatedAt == 0 {
+		p.CreatedAt = time.Now().Unix()
+	}
+	s.lastPostID++
+	p.ID = s.lastPostID
+	s.posts[p.ID] = clean(p)
+	s.addRevision(p)
+	return p.ID, nil
+}
+
+// Обновление публикации
+func (s *Store) UpdatePost(ctx context.Context, p storage.Post) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.posts[p.ID]
+	if !ok {
+		return fmt.Errorf("публикация %d: %w", p.ID, storage.ErrNotFound)
+	}
+	if _, ok := s.authors[p.AuthorID]; !ok {
+		return fmt.Errorf("автор %d: %w", p.AuthorID, storage.ErrInvalid)
+	}
+	p.CreatedAt = old.CreatedAt
+	if p.UpdatedAt == 0 {
+		p.UpdatedAt = time.Now().Unix()
+	}
+	if p.UpdatedBy == 0 {
+		p.UpdatedBy = p.AuthorID
+	}
+	s.posts[p.ID] = clean(p)
+	s.addRevision(p)
+	return nil
+}
+
+// addRevision сохраняет текущее состояние публикации как новую версию.
+// Вызывается под блокировкой записи.
+func (s *Store) addRevision(p storage.Post) {
+	s.lastRevID++
+	rev := p.Snapshot()
+	rev.ID = s.lastRevID
+	s.revisions[p.ID] = append(s.revisions[p.ID], rev)
+}
+
+// Получение версий публикации
+func (s *Store) Revisions(ctx context.Context, postID int) ([]storage.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.revisions[postID]), nil
+}
+
+// Получение версии публикации
+func (s *Store) Revision(ctx context.Context, postID, id int) (storage.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Revision{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, rev := range s.revisions[postID] {
+		if rev.ID == id {
+			return rev, nil
+		}
+	}
+	return storage.Revision{}, fmt.Errorf("версия %d публикации %d: %w", id, postID, storage.ErrNotFound)
+}
+
+// Удаление публикации
+func (s *Store) DeletePost(ctx context.Context, p storage.Post) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[p.ID]; !ok {
+		return fmt.Errorf("публикация %d: %w", p.ID, storage.ErrNotFound)
+	}
+	delete(s.posts, p.ID)
+	s.deleteAttachments(p.ID)
+	delete(s.revisions, p.ID)
+	return nil
+}
+
+// deleteAttachments удаляет вложения публикации. Вызывается под блокировкой записи.
+func (s *Store) deleteAttachments(postID int) {
+	for id, att := range s.attachments {
+		if att.PostID == postID {
+			delete(s.attachments, id)
+		}
+	}
+}
+
+// Добавление вложения
+func (s *Store) AddAttachment(ctx context.Context, att storage.Attachment) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[att.PostID]; !ok {
+		return 0, fmt.Errorf("публикация %d: %w", att.PostID, storage.ErrInvalid)
+	}
+	s.lastAttachID++
+	att.ID = s.lastAttachID
+	s.attachments[att.ID] = att
+	return att.ID, nil
+}
+
+// Получение вложений публикаций
+func (s *Store) Attachments(ctx context.Context, postIDs ...int) ([]storage.Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var res []storage.Attachment
+	for _, att := range s.attachments {
+		if slices.Contains(postIDs, att.PostID) {
+			res = append(res, att)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+// Удаление вложения публикации
+func (s *Store) DeleteAttachment(ctx context.Context, postID, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if att, ok := s.attachments[id]; !ok || att.PostID != postID {
+		return fmt.Errorf("вложение %d публикации %d: %w", id, postID, storage.ErrNotFound)
+	}
+	delete(s.attachments, id)
+	return nil
+}
+
+// Добавление автора
+func (s *Store) AddAuthor(ctx context.Context, a storage.Author) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastAuthorID++
+	a.ID = s.lastAuthorID
+	s.authors[a.ID] = a
+	return a.ID, nil
+}
+
+// Получение автора по ID
+func (s *Store) GetAuthorByID(ctx context.Context, id int) (storage.Author, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Author{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.authors[id]
+	if !ok {
+		return storage.Author{}, fmt.Errorf("автор %d: %w", id, storage.ErrNotFound)
+	}
+	return a, nil
+}
+
+// Получение всех авторов в порядке добавления
+func (s *Store) GetAuthors(ctx context.Context) ([]storage.Author, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	authors := make([]storage.Author, 0, len(s.authors))
+	for _, a := range s.authors {
+		authors = append(authors, a)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(authors, func(i, j int) bool { return authors[i].ID < authors[j].ID })
+	return authors, nil
+}
+
+// Изменение автора
+func (s *Store) UpdateAuthor(ctx context.Context, a storage.Author) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.authors[a.ID]; !ok {
+		return fmt.Errorf("автор %d: %w", a.ID, storage.ErrNotFound)
+	}
+	s.authors[a.ID] = a
+	return nil
 }
 
-func (s *Store) Posts() ([]storage.Post, error) {
-	return posts, nil
+// Удаление автора и, в зависимости от opts, его публикаций
+func (s *Store) DeleteAuthor(ctx context.Context, id int, opts storage.DeleteAuthorOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.authors[id]; !ok {
+		return fmt.Errorf("автор %d: %w", id, storage.ErrNotFound)
+	}
+	if s.lastAdmin(id) {
+		return fmt.Errorf("автор %d: %w", id, storage.ErrLastAdmin)
+	}
+
+	switch opts.Posts {
+	case storage.RestrictPosts:
+		for _, p := range s.posts {
+			if p.AuthorID == id {
+				return fmt.Errorf("у автора %d есть публикации: %w", id, storage.ErrConflict)
+			}
+		}
+	case storage.CascadePosts:
+		for pid, p := range s.posts {
+			if p.AuthorID == id {
+				delete(s.posts, pid)
+				s.deleteAttachments(pid)
+				delete(s.revisions, pid)
+			}
+		}
+	case storage.ReassignPosts:
+		if _, ok := s.authors[opts.ReassignTo]; !ok || opts.ReassignTo == id {
+			return fmt.Errorf("новый автор публикаций %d: %w", opts.ReassignTo, storage.ErrInvalid)
+		}
+		for pid, p := range s.posts {
+			if p.AuthorID == id {
+				p.AuthorID = opts.ReassignTo
+				s.posts[pid] = p
+			}
+		}
+	default:
+		return fmt.Errorf("режим удаления %d: %w", opts.Posts, storage.ErrInvalid)
+	}
+
+	delete(s.authors, id)
+	delete(s.accounts, id)
+	for tid, t := range s.tokens {
+		if t.AuthorID == id {
+			delete(s.tokens, tid)
+		}
+	}
+	return nil
 }
 
-func (s *Store) AddPost(storage.Post) error {
+// Добавление учётной записи
+func (s *Store) AddAccount(ctx context.Context, acc storage.Account) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.authors[acc.AuthorID]; !ok {
+		return fmt.Errorf("автор %d: %w", acc.AuthorID, storage.ErrInvalid)
+	}
+	if !acc.Role.Valid() {
+		return fmt.Errorf("роль %q: %w", acc.Role, storage.ErrInvalid)
+	}
+	if _, ok := s.accounts[acc.AuthorID]; ok {
+		return fmt.Errorf("у автора %d уже есть учётная запись: %w", acc.AuthorID, storage.ErrConflict)
+	}
+	for _, other := range s.accounts {
+		if other.Login == acc.Login {
+			return fmt.Errorf("логин %q занят: %w", acc.Login, storage.ErrConflict)
+		}
+	}
+	s.accounts[acc.AuthorID] = acc
 	return nil
 }
-func (s *Store) UpdatePost(storage.Post) error {
+
+// Получение учётной записи по логину
+func (s *Store) AccountByLogin(ctx context.Context, login string) (storage.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Account{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, acc := range s.accounts {
+		if acc.Login == login {
+			return acc, nil
+		}
+	}
+	return storage.Account{}, fmt.Errorf("учётная запись %q: %w", login, storage.ErrNotFound)
+}
+
+// Получение учётной записи автора
+func (s *Store) AccountByAuthor(ctx context.Context, authorID int) (storage.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Account{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	acc, ok := s.accounts[authorID]
+	if !ok {
+		return storage.Account{}, fmt.Errorf("учётная запись автора %d: %w", authorID, storage.ErrNotFound)
+	}
+	return acc, nil
+}
+
+// Получение всех учётных записей
+func (s *Store) Accounts(ctx context.Context) ([]storage.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	accounts := make([]storage.Account, 0, len(s.accounts))
+	for _, acc := range s.accounts {
+		accounts = append(accounts, acc)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].AuthorID < accounts[j].AuthorID })
+	return accounts, nil
+}
+
+// Назначение роли
+func (s *Store) SetRole(ctx context.Context, authorID int, role rbac.Role) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[authorID]
+	if !ok {
+		return fmt.Errorf("учётная запись автора %d: %w", authorID, storage.ErrNotFound)
+	}
+	if !role.Valid() {
+		return fmt.Errorf("роль %q: %w", role, storage.ErrInvalid)
+	}
+	if role != rbac.Admin && s.lastAdmin(authorID) {
+		return fmt.Errorf("учётная запись автора %d: %w", authorID, storage.ErrLastAdmin)
+	}
+	acc.Role = role
+	acc.SessionVersion++
+	s.accounts[authorID] = acc
+	return nil
+}
+
+// lastAdmin сообщает, что учётная запись автора authorID - единственный администратор.
+// Вызывается под блокировкой s.mu.
+func (s *Store) lastAdmin(authorID int) bool {
+	if s.accounts[authorID].Role != rbac.Admin {
+		return false
+	}
+	for id, acc := range s.accounts {
+		if id != authorID && acc.Role == rbac.Admin {
+			return false
+		}
+	}
+	return true
+}
+
+// Отзыв сессий учётной записи
+func (s *Store) RevokeSessions(ctx context.Context, authorID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[authorID]
+	if !ok {
+		return fmt.Errorf("учётная запись автора %d: %w", authorID, storage.ErrNotFound)
+	}
+	acc.SessionVersion++
+	s.accounts[authorID] = acc
 	return nil
 }
-func (s *Store) DeletePost(storage.Post) error {
+
+// Добавление API-токена
+func (s *Store) AddToken(ctx context.Context, t storage.Token) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.authors[t.AuthorID]; !ok {
+		return 0, fmt.Errorf("автор %d: %w", t.AuthorID, storage.ErrInvalid)
+	}
+	for _, other := range s.tokens {
+		if other.Hash == t.Hash {
+			return 0, fmt.Errorf("токен с таким хешем уже есть: %w", storage.ErrConflict)
+		}
+	}
+	s.lastTokenID++
+	t.ID = s.lastTokenID
+	s.tokens[t.ID] = cloneToken(t)
+	return t.ID, nil
+}
+
+// Получение API-токена по хешу
+func (s *Store) TokenByHash(ctx context.Context, hash string) (storage.Token, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Token{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, t := range s.tokens {
+		if t.Hash == hash {
+			return cloneToken(t), nil
+		}
+	}
+	return storage.Token{}, fmt.Errorf("токен: %w", storage.ErrNotFound)
+}
+
+// Получение API-токенов автора
+func (s *Store) Tokens(ctx context.Context, authorID int) ([]storage.Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var tokens []storage.Token
+	for _, t := range s.tokens {
+		if t.AuthorID == authorID {
+			tokens = append(tokens, cloneToken(t))
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens, nil
+}
+
+// Отзыв API-токена автора
+func (s *Store) DeleteToken(ctx context.Context, authorID, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.tokens[id]; !ok || t.AuthorID != authorID {
+		return fmt.Errorf("токен %d автора %d: %w", id, authorID, storage.ErrNotFound)
+	}
+	delete(s.tokens, id)
 	return nil
 }
 
-var posts = []storage.Post{
-	{
-		ID:      1,
-		Title:   "Effective Go",
-		Content: "Go is a new language. Although it borrows ideas from existing languages, it has unusual properties that make effective Go programs different in character from programs written in its relatives. A straightforward translation of a C++ or Java program into Go is unlikely to produce a satisfactory result—Java programs are written in Java, not Go. On the other hand, thinking about the problem from a Go perspective could produce a successful but quite different program. In other words, to write Go well, it's important to understand its properties and idioms. It's also important to know the established conventions for programming in Go, such as naming, formatting, program construction, and so on, so that programs you write will be easy for other Go programmers to understand.",
-	},
-	{
-		ID:      2,
-		Title:   "The Go Memory Model",
-		Content: "The Go memory model specifies the conditions under which reads of a variable in one goroutine can be guaranteed to observe values produced by writes to the same variable in a different goroutine.",
-	},
+// cloneToken копирует токен вместе со списком областей действия,
+// чтобы вызывающий код не менял хранимые данные.
+func cloneToken(t storage.Token) storage.Token {
+	t.Scopes = append([]string(nil), t.Scopes...)
+	return t
+}
+
+// clean убирает из публикации вычисляемые поля, которые не хранятся:
+// автор и дата для вывода заполняются при чтении.
+func clean(p storage.Post) storage.Post {
+	p.Author = storage.Author{}
+	p.FormattedDate = ""
+	return p
+}
+
+// Post возвращает публикацию по ID вместе с автором.
+func (s *Store) Post(ctx context.Context, id int) (storage.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Post{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.posts[id]
+	if !ok {
+		return storage.Post{}, fmt.Errorf("публикация %d: %w", id, storage.ErrNotFound)
+	}
+	a, ok := s.authors[p.AuthorID]
+	if !ok {
+		return storage.Post{}, fmt.Errorf("публикация %d: %w", id, storage.ErrNotFound)
+	}
+	p.Author = a
+	p.FormattedDate = storage.FormatDate(p.CreatedAt)
+	return p, nil
+}
+
+// query применяет к публикациям фильтры, сортировку и разбиение на страницы.
+func query(all []storage.Post, q storage.PostsQuery) (storage.PostsPage, error) {
+	if err := q.Validate(); err != nil {
+		return storage.PostsPage{}, err
+	}
+	var cursor *storage.Cursor
+	if q.Cursor != "" {
+		c, err := storage.DecodeCursor(q.Cursor)
+		if err != nil {
+			return storage.PostsPage{}, err
+		}
+		cursor = &c
+	}
+
+	var res []storage.Post
+	for _, p := range all {
+		switch {
+		case q.AuthorID != 0 && p.AuthorID != q.AuthorID:
+			continue
+		case q.From != 0 && p.CreatedAt < q.From:
+			continue
+		case q.To != 0 && p.CreatedAt >= q.To:
+			continue
+		case cursor != nil && !cursor.After(p, q.Sort):
+			continue
+		}
+		res = append(res, p)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if q.Sort == storage.OldestFirst {
+			a, b = b, a
+		}
+		if a.CreatedAt != b.CreatedAt {
+			return a.CreatedAt > b.CreatedAt
+		}
+		return a.ID > b.ID
+	})
+
+	if cursor == nil {
+		if q.Offset >= len(res) {
+			res = nil
+		} else {
+			res = res[q.Offset:]
+		}
+	}
+	limit := q.PageSize()
+	if len(res) > limit+1 {
+		res = res[:limit+1]
+	}
+	return storage.NewPostsPage(res, limit), nil
 }
+
+var _ storage.Interface = (*Store)(nil)
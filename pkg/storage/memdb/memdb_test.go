@@ -0,0 +1,25 @@
+package memdb
+
+import (
+	"testing"
+
+	"GoNews/pkg/storage"
+	"GoNews/pkg/storage/storagetest"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Interface {
+		return New()
+	})
+}
+
+func TestOrphans(t *testing.T) {
+	storagetest.RunOrphans(t, func(t *testing.T) storage.Interface {
+		return New()
+	}, func(t *testing.T, db storage.Interface, authorID int) {
+		s := db.(*Store)
+		s.mu.Lock()
+		delete(s.authors, authorID)
+		s.mu.Unlock()
+	})
+}
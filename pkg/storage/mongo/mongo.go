@@ -2,50 +2,191 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"GoNews/pkg/rbac"
 	"GoNews/pkg/storage"
 )
 
+// Имена коллекций.
+const (
+	postsCollection       = "posts"
+	authorsCollection     = "authors"
+	accountsCollection    = "accounts" // учётные записи, _id совпадает с ID автора
+	tokensCollection      = "tokens"
+	attachmentsCollection = "attachments" // вложения публикаций
+	revisionsCollection   = "revisions"   // версии публикаций
+	countersCollection    = "counters"    // последовательности целочисленных ID
+	migrationsCollection  = "migrations"  // выполненные разовые преобразования данных
+)
+
+// DefaultDatabase - имя базы данных, если оно не задано явно.
+const DefaultDatabase = "GoNews"
+
 // Store представляет собой хранилище MongoDB
 type Store struct {
 	client *mongo.Client
 	db     *mongo.Database
 }
 
-// New - подключение к MongoDB
-func New() (*Store, error) {
-	// Получение переменных окружения для подключения
-	uri := os.Getenv("MONGO_URI")
+// Документ публикации в коллекции posts.
+type postDoc struct {
+	ID        int        `bson:"_id"`
+	Title     string     `bson:"title"`
+	Content   string     `bson:"content"`
+	AuthorID  int        `bson:"author_id"`
+	CreatedAt int64      `bson:"created_at"`
+	UpdatedAt int64      `bson:"updated_at,omitempty"`
+	UpdatedBy int        `bson:"updated_by,omitempty"`
+	Author    *authorDoc `bson:"author,omitempty"` // заполняется через $lookup
+}
+
+// Документ автора в коллекции authors.
+type authorDoc struct {
+	ID        int    `bson:"_id"`
+	Name      string `bson:"name"`
+	AvatarURL string `bson:"avatar_url"`
+}
+
+// Документ учётной записи в коллекции accounts.
+type accountDoc struct {
+	AuthorID       int       `bson:"_id"`
+	Login          string    `bson:"login"`
+	PasswordHash   string    `bson:"password_hash"`
+	Role           rbac.Role `bson:"role"`
+	Admin          bool      `bson:"admin,omitempty"` // до появления ролей; читается, но не пишется
+	SessionVersion int       `bson:"session_version,omitempty"`
+}
+
+// Документ API-токена в коллекции tokens.
+type tokenDoc struct {
+	ID        int      `bson:"_id"`
+	AuthorID  int      `bson:"author_id"`
+	Name      string   `bson:"name"`
+	Hash      string   `bson:"hash"`
+	Scopes    []string `bson:"scopes"`
+	CreatedAt int64    `bson:"created_at"`
+	ExpiresAt int64    `bson:"expires_at"`
+}
+
+// Документ вложения в коллекции attachments.
+type attachmentDoc struct {
+	ID           int    `bson:"_id"`
+	PostID       int    `bson:"post_id"`
+	Name         string `bson:"name"`
+	ContentType  string `bson:"content_type"`
+	Size         int64  `bson:"size"`
+	URL          string `bson:"url"`
+	ThumbnailURL string `bson:"thumbnail_url"`
+	CreatedAt    int64  `bson:"created_at"`
+}
+
+// Документ версии публикации в коллекции revisions.
+type revisionDoc struct {
+	ID        int    `bson:"_id"`
+	PostID    int    `bson:"post_id"`
+	AuthorID  int    `bson:"author_id"`
+	Title     string `bson:"title"`
+	Content   string `bson:"content"`
+	CreatedAt int64  `bson:"created_at"`
+	Baseline  bool   `bson:"baseline,omitempty"` // первая версия публикации, созданной до появления истории
+}
+
+func (d revisionDoc) revision() storage.Revision {
+	return storage.Revision{ID: d.ID, PostID: d.PostID, AuthorID: d.AuthorID, Title: d.Title, Content: d.Content, CreatedAt: d.CreatedAt}
+}
+
+func (d postDoc) post() storage.Post {
+	p := storage.Post{
+		ID:            d.ID,
+		Title:         d.Title,
+		Content:       d.Content,
+		AuthorID:      d.AuthorID,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+		UpdatedBy:     d.UpdatedBy,
+		FormattedDate: storage.FormatDate(d.CreatedAt),
+	}
+	if d.Author != nil {
+		p.Author = d.Author.author()
+	}
+	return p
+}
+
+func (d authorDoc) author() storage.Author {
+	return storage.Author{ID: d.ID, Name: d.Name, AvatarURL: d.AvatarURL}
+}
+
+func (d accountDoc) account() storage.Account {
+	acc := storage.Account{
+		AuthorID:       d.AuthorID,
+		Login:          d.Login,
+		PasswordHash:   d.PasswordHash,
+		Role:           d.Role,
+		SessionVersion: d.SessionVersion,
+	}
+	if acc.Role == "" {
+		// учётная запись создана до появления ролей
+		acc.Role = rbac.Author
+		if d.Admin {
+			acc.Role = rbac.Admin
+		}
+	}
+	return acc
+}
+
+func (d tokenDoc) token() storage.Token {
+	return storage.Token(d)
+}
+
+// New - подключение к MongoDB.
+// Если dbName пустое, используется база DefaultDatabase.
+func New(uri, dbName string) (*Store, error) {
 	if uri == "" {
-		return nil, fmt.Errorf("MONGO_URI не установлен")
+		return nil, fmt.Errorf("не задана строка подключения к MongoDB")
+	}
+	if dbName == "" {
+		dbName = DefaultDatabase
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	// Подключаемся к MongoDB
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
 		log.Printf("Ошибка подключения к MongoDB: %v", err)
 		return nil, fmt.Errorf("ошибка подключения к MongoDB: %w", err)
 	}
 
 	// Проверяем соединение
-	err = client.Ping(context.Background(), nil)
+	err = client.Ping(ctx, nil)
 	if err != nil {
 		log.Printf("Ошибка пинга MongoDB: %v", err)
+		client.Disconnect(context.Background())
 		return nil, fmt.Errorf("не удалось подключиться к MongoDB: %w", err)
 	}
 
-	// Получаем доступ к базе данных
-	db := client.Database("GoNews")
+	s := &Store{client: client, db: client.Database(dbName)}
+	if err := s.ensureIndexes(ctx); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("не удалось создать индексы MongoDB: %w", err)
+	}
+	// Миграция может обойти много публикаций: таймаут подключения к ней не относится
+	if err := s.backfillRevisions(context.Background()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("не удалось создать первые версии публикаций: %w", err)
+	}
 
 	log.Println("Подключение к MongoDB успешно")
-	return &Store{client: client, db: db}, nil
+	return s, nil
 }
 
 // Close - закрытие соединения с MongoDB
@@ -57,54 +198,496 @@ func (s *Store) Close() {
 	}
 }
 
+// ensureIndexes создаёт индексы под сортировку ленты, фильтр по автору
+// и выборку вложений и версий, а также уникальные индексы логинов и хешей токенов.
+func (s *Store) ensureIndexes(ctx context.Context) error {
+	_, err := s.db.Collection(postsCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "author_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Collection(accountsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "login", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Collection(tokensCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "author_id", Value: 1}, {Key: "_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Collection(attachmentsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Collection(revisionsCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "_id", Value: 1}}},
+		// не больше одной первой версии, созданной миграцией, у публикации
+		{Keys: bson.D{{Key: "post_id", Value: 1}}, Options: options.Index().
+			SetUnique(true).
+			SetName("post_id_baseline").
+			SetPartialFilterExpression(bson.D{{Key: "baseline", Value: true}})},
+	})
+	return err
+}
+
+// Имя миграции, создающей первые версии публикаций.
+const revisionsBackfill = "revisions_backfill"
+
+// backfillRevisions - разовая миграция: публикации, созданные до появления
+// истории, получают первую версию со своим текущим состоянием. После неё
+// версии добавляют только AddPost и UpdatePost, а Revisions их лишь читает.
+// Выполненная миграция отмечается в коллекции migrations. Если её одновременно
+// запустят несколько серверов, вторую первую версию не даст вставить
+// уникальный индекс post_id_baseline.
+func (s *Store) backfillRevisions(ctx context.Context) error {
+	migrations := s.db.Collection(migrationsCollection)
+	err := migrations.FindOne(ctx, bson.D{{Key: "_id", Value: revisionsBackfill}}).Err()
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	cursor, err := s.db.Collection(postsCollection).Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := s.db.Collection(revisionsCollection)
+	added := 0
+	for cursor.Next(ctx) {
+		var d postDoc
+		if err := cursor.Decode(&d); err != nil {
+			return err
+		}
+		n, err := revisions.CountDocuments(ctx, bson.D{{Key: "post_id", Value: d.ID}}, options.Count().SetLimit(1))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := s.addRevision(ctx, d.post(), true); err != nil && !errors.Is(err, storage.ErrConflict) {
+			return err
+		}
+		added++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	_, err = migrations.InsertOne(ctx, bson.D{{Key: "_id", Value: revisionsBackfill}, {Key: "applied_at", Value: time.Now().Unix()}})
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	if added > 0 {
+		log.Printf("Созданы первые версии публикаций: %d", added)
+	}
+	return nil
+}
+
+// nextID выдаёт следующее значение последовательности для коллекции.
+func (s *Store) nextID(ctx context.Context, collection string) (int, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := s.db.Collection(countersCollection).FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: collection}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: 1}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// authorExists проверяет наличие автора: в MongoDB нет внешних ключей.
+func (s *Store) authorExists(ctx context.Context, id int) error {
+	n, err := s.db.Collection(authorsCollection).CountDocuments(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("автор %d: %w", id, storage.ErrInvalid)
+	}
+	return nil
+}
+
+// convertError приводит ошибки драйвера к ошибкам пакета storage.
+func convertError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: %v", storage.ErrConflict, err)
+	}
+	return err
+}
+
 // get post
-func (s *Store) Posts() ([]storage.Post, error) {
-	collection := s.db.Collection("posts")
-	cursor, err := collection.Find(context.Background(), bson.D{})
+func (s *Store) Posts(ctx context.Context, q storage.PostsQuery) (storage.PostsPage, error) {
+	if err := q.Validate(); err != nil {
+		return storage.PostsPage{}, err
+	}
+
+	filter := bson.D{}
+	if q.AuthorID != 0 {
+		filter = append(filter, bson.E{Key: "author_id", Value: q.AuthorID})
+	}
+	created := bson.D{}
+	if q.From != 0 {
+		created = append(created, bson.E{Key: "$gte", Value: q.From})
+	}
+	if q.To != 0 {
+		created = append(created, bson.E{Key: "$lt", Value: q.To})
+	}
+	if len(created) > 0 {
+		filter = append(filter, bson.E{Key: "created_at", Value: created})
+	}
+
+	dir, cmp := -1, "$lt"
+	if q.Sort == storage.OldestFirst {
+		dir, cmp = 1, "$gt"
+	}
+	if q.Cursor != "" {
+		c, err := storage.DecodeCursor(q.Cursor)
+		if err != nil {
+			return storage.PostsPage{}, err
+		}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "created_at", Value: bson.D{{Key: cmp, Value: c.CreatedAt}}}},
+			bson.D{{Key: "created_at", Value: c.CreatedAt}, {Key: "_id", Value: bson.D{{Key: cmp, Value: c.ID}}}},
+		}})
+	}
+
+	limit := q.PageSize()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: dir}, {Key: "_id", Value: dir}}}},
+	}
+	// Автора присоединяем до $skip и $limit: публикации без автора отбрасываются
+	// и не должны ни укорачивать страницу, ни сдвигать смещение
+	pipeline = append(pipeline, authorLookup...)
+	if q.Cursor == "" && q.Offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: q.Offset}})
+	}
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit + 1}})
+
+	cursor, err := s.db.Collection(postsCollection).Aggregate(ctx, pipeline)
 	if err != nil {
-		return nil, err
+		return storage.PostsPage{}, err
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(ctx)
 
 	var posts []storage.Post
-	for cursor.Next(context.Background()) {
-		var p storage.Post
-		if err := cursor.Decode(&p); err != nil {
-			return nil, err
+	for cursor.Next(ctx) {
+		var d postDoc
+		if err := cursor.Decode(&d); err != nil {
+			return storage.PostsPage{}, err
 		}
-		posts = append(posts, p)
+		posts = append(posts, d.post())
 	}
 
 	if err := cursor.Err(); err != nil {
-		return nil, err
+		return storage.PostsPage{}, err
+	}
+
+	return storage.NewPostsPage(posts, limit), nil
+}
+
+// get post by id
+func (s *Store) Post(ctx context.Context, id int) (storage.Post, error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "_id", Value: id}}}}}
+	pipeline = append(pipeline, authorLookup...)
+
+	cursor, err := s.db.Collection(postsCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return storage.Post{}, err
 	}
+	defer cursor.Close(ctx)
 
-	return posts, nil
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return storage.Post{}, err
+		}
+		return storage.Post{}, fmt.Errorf("публикация %d: %w", id, storage.ErrNotFound)
+	}
+	var d postDoc
+	if err := cursor.Decode(&d); err != nil {
+		return storage.Post{}, err
+	}
+	return d.post(), nil
+}
+
+// Стадии агрегации, присоединяющие автора к публикации.
+// Как и JOIN в PostgreSQL, отбрасывают публикации без автора.
+var authorLookup = mongo.Pipeline{
+	{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: authorsCollection},
+		{Key: "localField", Value: "author_id"},
+		{Key: "foreignField", Value: "_id"},
+		{Key: "as", Value: "author"},
+	}}},
+	{{Key: "$unwind", Value: "$author"}},
 }
 
 // add post
-func (s *Store) AddPost(p storage.Post) error {
-	collection := s.db.Collection("posts")
-	_, err := collection.InsertOne(context.Background(), p)
+func (s *Store) AddPost(ctx context.Context, p storage.Post) (int, error) {
+	if err := s.authorExists(ctx, p.AuthorID); err != nil {
+		return 0, err
+	}
+	id, err := s.nextID(ctx, postsCollection)
+	if err != nil {
+		return 0, err
+	}
+	if p.CreatedAt == 0 {
+		p.CreatedAt = time.Now().Unix()
+	}
+
+	_, err = s.db.Collection(postsCollection).InsertOne(ctx, postDoc{
+		ID:        id,
+		Title:     p.Title,
+		Content:   p.Content,
+		AuthorID:  p.AuthorID,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		UpdatedBy: p.UpdatedBy,
+	})
+	if err != nil {
+		return 0, convertError(err)
+	}
+	p.ID = id
+	if _, err := s.addRevision(ctx, p, false); err != nil {
+		// Публикация без первой версии не нужна: удаляем её, как откатилась бы транзакция
+		if _, derr := s.db.Collection(postsCollection).DeleteOne(context.WithoutCancel(ctx), bson.D{{Key: "_id", Value: id}}); derr != nil {
+			log.Printf("Ошибка удаления публикации %d без версии: %v", id, derr)
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// update post
+// Дата создания не меняется.
+func (s *Store) UpdatePost(ctx context.Context, p storage.Post) error {
+	// Сначала публикация, потом автор: несуществующая публикация - ErrNotFound
+	// при любом авторе, как в остальных хранилищах
+	err := s.db.Collection(postsCollection).FindOne(ctx, bson.D{{Key: "_id", Value: p.ID}}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("публикация %d: %w", p.ID, storage.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.authorExists(ctx, p.AuthorID); err != nil {
+		return err
+	}
+	if p.UpdatedAt == 0 {
+		p.UpdatedAt = time.Now().Unix()
+	}
+	if p.UpdatedBy == 0 {
+		p.UpdatedBy = p.AuthorID
+	}
+
+	// Транзакции MongoDB требуют набора реплик, поэтому хранилище их не использует
+	// (см. keepAdmin). Версия записывается до изменения публикации и удаляется,
+	// если изменить её не удалось: изменённое состояние всегда есть в истории,
+	// а при сбое процесса между записями останется лишь лишняя версия.
+	revID, err := s.addRevision(ctx, p, false)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Collection(postsCollection).UpdateByID(ctx, p.ID, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "title", Value: p.Title},
+		{Key: "content", Value: p.Content},
+		{Key: "author_id", Value: p.AuthorID},
+		{Key: "updated_at", Value: p.UpdatedAt},
+		{Key: "updated_by", Value: p.UpdatedBy},
+	}}})
+	switch {
+	case err != nil:
+		err = convertError(err)
+	case res.MatchedCount == 0:
+		// публикацию удалили после проверки
+		err = fmt.Errorf("публикация %d: %w", p.ID, storage.ErrNotFound)
+	}
+	if err != nil {
+		if _, derr := s.db.Collection(revisionsCollection).DeleteOne(context.WithoutCancel(ctx), bson.D{{Key: "_id", Value: revID}}); derr != nil {
+			log.Printf("Ошибка удаления версии %d публикации %d: %v", revID, p.ID, derr)
+		}
+		return err
+	}
+	return nil
+}
+
+// addRevision сохраняет состояние публикации как новую версию и возвращает её ID.
+// baseline отмечает первую версию, созданную миграцией backfillRevisions.
+func (s *Store) addRevision(ctx context.Context, p storage.Post, baseline bool) (int, error) {
+	id, err := s.nextID(ctx, revisionsCollection)
+	if err != nil {
+		return 0, err
+	}
+	rev := p.Snapshot()
+	d := revisionDoc{ID: id, PostID: rev.PostID, AuthorID: rev.AuthorID, Title: rev.Title, Content: rev.Content, CreatedAt: rev.CreatedAt, Baseline: baseline}
+	if _, err := s.db.Collection(revisionsCollection).InsertOne(ctx, d); err != nil {
+		return 0, convertError(err)
+	}
+	return id, nil
+}
+
+// get revisions of post
+func (s *Store) Revisions(ctx context.Context, postID int) ([]storage.Revision, error) {
+	cursor, err := s.db.Collection(revisionsCollection).Find(ctx, bson.D{{Key: "post_id", Value: postID}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var res []storage.Revision
+	for cursor.Next(ctx) {
+		var d revisionDoc
+		if err := cursor.Decode(&d); err != nil {
+			return nil, err
+		}
+		res = append(res, d.revision())
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// get revision
+func (s *Store) Revision(ctx context.Context, postID, id int) (storage.Revision, error) {
+	var d revisionDoc
+	err := s.db.Collection(revisionsCollection).FindOne(ctx, bson.D{{Key: "_id", Value: id}, {Key: "post_id", Value: postID}}).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return storage.Revision{}, fmt.Errorf("версия %d публикации %d: %w", id, postID, storage.ErrNotFound)
+	}
+	if err != nil {
+		return storage.Revision{}, err
+	}
+	return d.revision(), nil
+}
+
+// delete post
+// Вложения и версии удаляются после публикации: при сбое посередине остаются
+// только записи, которые больше нигде не показываются.
+func (s *Store) DeletePost(ctx context.Context, p storage.Post) error {
+	res, err := s.db.Collection(postsCollection).DeleteOne(ctx, bson.D{{Key: "_id", Value: p.ID}})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("публикация %d: %w", p.ID, storage.ErrNotFound)
+	}
+	byPost := bson.D{{Key: "post_id", Value: p.ID}}
+	if _, err := s.db.Collection(attachmentsCollection).DeleteMany(ctx, byPost); err != nil {
+		return err
+	}
+	_, err = s.db.Collection(revisionsCollection).DeleteMany(ctx, byPost)
 	return err
 }
 
+// add attachment
+func (s *Store) AddAttachment(ctx context.Context, att storage.Attachment) (int, error) {
+	n, err := s.db.Collection(postsCollection).CountDocuments(ctx, bson.D{{Key: "_id", Value: att.PostID}})
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("публикация %d: %w", att.PostID, storage.ErrInvalid)
+	}
+	id, err := s.nextID(ctx, attachmentsCollection)
+	if err != nil {
+		return 0, err
+	}
+	att.ID = id
+	if _, err := s.db.Collection(attachmentsCollection).InsertOne(ctx, attachmentDoc(att)); err != nil {
+		return 0, convertError(err)
+	}
+	return id, nil
+}
+
+// get attachments of posts
+func (s *Store) Attachments(ctx context.Context, postIDs ...int) ([]storage.Attachment, error) {
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+	cursor, err := s.db.Collection(attachmentsCollection).Find(ctx,
+		bson.D{{Key: "post_id", Value: bson.D{{Key: "$in", Value: postIDs}}}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var res []storage.Attachment
+	for cursor.Next(ctx) {
+		var d attachmentDoc
+		if err := cursor.Decode(&d); err != nil {
+			return nil, err
+		}
+		res = append(res, storage.Attachment(d))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// delete attachment
+func (s *Store) DeleteAttachment(ctx context.Context, postID, id int) error {
+	res, err := s.db.Collection(attachmentsCollection).DeleteOne(ctx, bson.D{{Key: "_id", Value: id}, {Key: "post_id", Value: postID}})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("вложение %d публикации %d: %w", id, postID, storage.ErrNotFound)
+	}
+	return nil
+}
+
+// get author
+func (s *Store) GetAuthorByID(ctx context.Context, id int) (storage.Author, error) {
+	var d authorDoc
+	err := s.db.Collection(authorsCollection).FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return storage.Author{}, fmt.Errorf("автор %d: %w", id, storage.ErrNotFound)
+	}
+	if err != nil {
+		return storage.Author{}, err
+	}
+	return d.author(), nil
+}
+
 // get authors
-func (s *Store) GetAuthors() ([]storage.Author, error) {
-	collection := s.db.Collection("authors")
-	cursor, err := collection.Find(context.Background(), bson.D{})
+func (s *Store) GetAuthors(ctx context.Context) ([]storage.Author, error) {
+	collection := s.db.Collection(authorsCollection)
+	cursor, err := collection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(ctx)
 
 	var authors []storage.Author
-	for cursor.Next(context.Background()) {
-		var a storage.Author
-		if err := cursor.Decode(&a); err != nil {
+	for cursor.Next(ctx) {
+		var d authorDoc
+		if err := cursor.Decode(&d); err != nil {
 			return nil, err
 		}
-		authors = append(authors, a)
+		authors = append(authors, d.author())
 	}
 
 	if err := cursor.Err(); err != nil {
@@ -115,8 +698,332 @@ func (s *Store) GetAuthors() ([]storage.Author, error) {
 }
 
 // add authors
-func (s *Store) AddAuthor(a storage.Author) error {
-	collection := s.db.Collection("authors")
-	_, err := collection.InsertOne(context.Background(), a)
+func (s *Store) AddAuthor(ctx context.Context, a storage.Author) (int, error) {
+	id, err := s.nextID(ctx, authorsCollection)
+	if err != nil {
+		return 0, err
+	}
+	_, err = s.db.Collection(authorsCollection).InsertOne(ctx, authorDoc{
+		ID:        id,
+		Name:      a.Name,
+		AvatarURL: a.AvatarURL,
+	})
+	if err != nil {
+		return 0, convertError(err)
+	}
+	return id, nil
+}
+
+// update author
+func (s *Store) UpdateAuthor(ctx context.Context, a storage.Author) error {
+	res, err := s.db.Collection(authorsCollection).UpdateByID(ctx, a.ID, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "name", Value: a.Name},
+		{Key: "avatar_url", Value: a.AvatarURL},
+	}}})
+	if err != nil {
+		return convertError(err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("автор %d: %w", a.ID, storage.ErrNotFound)
+	}
+	return nil
+}
+
+// delete author
+// MongoDB без набора реплик не поддерживает транзакции, поэтому публикации
+// обрабатываются до удаления автора: при сбое посередине автор остаётся,
+// и удаление можно повторить.
+func (s *Store) DeleteAuthor(ctx context.Context, id int, opts storage.DeleteAuthorOptions) (err error) {
+	if _, err := s.GetAuthorByID(ctx, id); err != nil {
+		return err
+	}
+	// Администратора сначала понижаем: последнего из них не удалят и параллельные
+	// запросы (см. keepAdmin). Если удаление не удастся, роль вернётся.
+	demoted, err := s.demoteAdmin(ctx, id)
+	if err != nil {
+		return err
+	}
+	if demoted {
+		defer func() {
+			if err != nil {
+				s.restoreAdmin(ctx, id)
+			}
+		}()
+	}
+
+	posts := s.db.Collection(postsCollection)
+	byAuthor := bson.D{{Key: "author_id", Value: id}}
+	switch opts.Posts {
+	case storage.RestrictPosts:
+		n, err := posts.CountDocuments(ctx, byAuthor, options.Count().SetLimit(1))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("у автора %d есть публикации: %w", id, storage.ErrConflict)
+		}
+	case storage.CascadePosts:
+		// Сначала вложения и версии: при сбое публикации остаются, и удаление можно повторить
+		ids, err := posts.Distinct(ctx, "_id", byAuthor)
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			byPosts := bson.D{{Key: "post_id", Value: bson.D{{Key: "$in", Value: ids}}}}
+			for _, c := range []string{attachmentsCollection, revisionsCollection} {
+				if _, err := s.db.Collection(c).DeleteMany(ctx, byPosts); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := posts.DeleteMany(ctx, byAuthor); err != nil {
+			return err
+		}
+	case storage.ReassignPosts:
+		if opts.ReassignTo == id {
+			return fmt.Errorf("новый автор публикаций совпадает с удаляемым: %w", storage.ErrInvalid)
+		}
+		if err := s.authorExists(ctx, opts.ReassignTo); err != nil {
+			return err
+		}
+		_, err := posts.UpdateMany(ctx, byAuthor, bson.D{{Key: "$set", Value: bson.D{{Key: "author_id", Value: opts.ReassignTo}}}})
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("режим удаления %d: %w", opts.Posts, storage.ErrInvalid)
+	}
+
+	if _, err := s.db.Collection(tokensCollection).DeleteMany(ctx, byAuthor); err != nil {
+		return err
+	}
+	if _, err := s.db.Collection(accountsCollection).DeleteOne(ctx, bson.D{{Key: "_id", Value: id}}); err != nil {
+		return err
+	}
+	_, err = s.db.Collection(authorsCollection).DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
 	return err
 }
+
+// add account
+func (s *Store) AddAccount(ctx context.Context, acc storage.Account) error {
+	if err := s.authorExists(ctx, acc.AuthorID); err != nil {
+		return err
+	}
+	if !acc.Role.Valid() {
+		return fmt.Errorf("роль %q: %w", acc.Role, storage.ErrInvalid)
+	}
+	_, err := s.db.Collection(accountsCollection).InsertOne(ctx, accountDoc{
+		AuthorID:     acc.AuthorID,
+		Login:        acc.Login,
+		PasswordHash: acc.PasswordHash,
+		Role:         acc.Role,
+	})
+	return convertError(err)
+}
+
+// set role
+func (s *Store) SetRole(ctx context.Context, authorID int, role rbac.Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("роль %q: %w", role, storage.ErrInvalid)
+	}
+	var old accountDoc
+	err := s.db.Collection(accountsCollection).FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: authorID}}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "role", Value: role}}},
+		{Key: "$unset", Value: bson.D{{Key: "admin", Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: "session_version", Value: 1}}},
+	}).Decode(&old)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("учётная запись автора %d: %w", authorID, storage.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if role != rbac.Admin && old.account().Role == rbac.Admin {
+		return s.keepAdmin(ctx, authorID)
+	}
+	return nil
+}
+
+// Условие на учётные записи администраторов, в том числе созданные до появления ролей.
+var adminAccounts = bson.A{
+	bson.D{{Key: "role", Value: rbac.Admin}},
+	bson.D{{Key: "admin", Value: true}},
+}
+
+// demoteAdmin снимает роль администратора с учётной записи автора authorID
+// и сообщает, была ли она у неё. Последнего администратора не понижает (ErrLastAdmin).
+func (s *Store) demoteAdmin(ctx context.Context, authorID int) (bool, error) {
+	filter := bson.D{{Key: "_id", Value: authorID}, {Key: "$or", Value: adminAccounts}}
+	res, err := s.db.Collection(accountsCollection).UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "role", Value: rbac.Reader}}},
+		{Key: "$unset", Value: bson.D{{Key: "admin", Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: "session_version", Value: 1}}},
+	})
+	if err != nil || res.MatchedCount == 0 {
+		return false, err
+	}
+	if err := s.keepAdmin(ctx, authorID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// keepAdmin вызывается после снятия роли администратора с учётной записи автора
+// authorID. Транзакции MongoDB требуют набора реплик, поэтому хранилище их
+// не использует: роль сначала меняется, затем администраторы пересчитываются,
+// и если их не осталось, роль возвращается, а операция завершается ErrLastAdmin.
+// Из двух параллельных понижений последних администраторов хотя бы одно
+// увидит ноль и откатится, так что база не останется без администратора.
+func (s *Store) keepAdmin(ctx context.Context, authorID int) error {
+	n, err := s.db.Collection(accountsCollection).CountDocuments(ctx,
+		bson.D{{Key: "$or", Value: adminAccounts}}, options.Count().SetLimit(1))
+	if err == nil && n > 0 {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("учётная запись автора %d: %w", authorID, storage.ErrLastAdmin)
+	}
+	s.restoreAdmin(ctx, authorID)
+	return err
+}
+
+// restoreAdmin возвращает учётной записи автора authorID роль администратора
+// и отменяет увеличение версии сессий при понижении: отказ сессии не отзывает.
+// Выполняется и после отмены запроса, чтобы не оставить её пониженной.
+func (s *Store) restoreAdmin(ctx context.Context, authorID int) {
+	_, err := s.db.Collection(accountsCollection).UpdateByID(context.WithoutCancel(ctx), authorID, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "role", Value: rbac.Admin}}},
+		{Key: "$inc", Value: bson.D{{Key: "session_version", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Ошибка возврата роли администратора учётной записи %d: %v", authorID, err)
+	}
+}
+
+// Отзыв сессий учётной записи
+func (s *Store) RevokeSessions(ctx context.Context, authorID int) error {
+	res, err := s.db.Collection(accountsCollection).UpdateByID(ctx, authorID, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "session_version", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("учётная запись автора %d: %w", authorID, storage.ErrNotFound)
+	}
+	return nil
+}
+
+// findAccount возвращает учётную запись по фильтру; what - описание для ошибки ErrNotFound.
+func (s *Store) findAccount(ctx context.Context, filter bson.D, what string) (storage.Account, error) {
+	var d accountDoc
+	err := s.db.Collection(accountsCollection).FindOne(ctx, filter).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return storage.Account{}, fmt.Errorf("%s: %w", what, storage.ErrNotFound)
+	}
+	if err != nil {
+		return storage.Account{}, err
+	}
+	return d.account(), nil
+}
+
+// get account by login
+func (s *Store) AccountByLogin(ctx context.Context, login string) (storage.Account, error) {
+	return s.findAccount(ctx, bson.D{{Key: "login", Value: login}}, fmt.Sprintf("учётная запись %q", login))
+}
+
+// get account by author
+func (s *Store) AccountByAuthor(ctx context.Context, authorID int) (storage.Account, error) {
+	return s.findAccount(ctx, bson.D{{Key: "_id", Value: authorID}}, fmt.Sprintf("учётная запись автора %d", authorID))
+}
+
+// get accounts
+func (s *Store) Accounts(ctx context.Context) ([]storage.Account, error) {
+	cursor, err := s.db.Collection(accountsCollection).Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var accounts []storage.Account
+	for cursor.Next(ctx) {
+		var d accountDoc
+		if err := cursor.Decode(&d); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, d.account())
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// add token
+func (s *Store) AddToken(ctx context.Context, t storage.Token) (int, error) {
+	if err := s.authorExists(ctx, t.AuthorID); err != nil {
+		return 0, err
+	}
+	id, err := s.nextID(ctx, tokensCollection)
+	if err != nil {
+		return 0, err
+	}
+	t.ID = id
+	if t.Scopes == nil {
+		t.Scopes = []string{}
+	}
+	if _, err := s.db.Collection(tokensCollection).InsertOne(ctx, tokenDoc(t)); err != nil {
+		return 0, convertError(err)
+	}
+	return id, nil
+}
+
+// get token by hash
+func (s *Store) TokenByHash(ctx context.Context, hash string) (storage.Token, error) {
+	var d tokenDoc
+	err := s.db.Collection(tokensCollection).FindOne(ctx, bson.D{{Key: "hash", Value: hash}}).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return storage.Token{}, fmt.Errorf("токен: %w", storage.ErrNotFound)
+	}
+	if err != nil {
+		return storage.Token{}, err
+	}
+	return d.token(), nil
+}
+
+// get tokens of author
+func (s *Store) Tokens(ctx context.Context, authorID int) ([]storage.Token, error) {
+	cursor, err := s.db.Collection(tokensCollection).Find(ctx, bson.D{{Key: "author_id", Value: authorID}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var tokens []storage.Token
+	for cursor.Next(ctx) {
+		var d tokenDoc
+		if err := cursor.Decode(&d); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, d.token())
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// revoke token
+func (s *Store) DeleteToken(ctx context.Context, authorID, id int) error {
+	res, err := s.db.Collection(tokensCollection).DeleteOne(ctx, bson.D{{Key: "_id", Value: id}, {Key: "author_id", Value: authorID}})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("токен %d автора %d: %w", id, authorID, storage.ErrNotFound)
+	}
+	return nil
+}
+
+var _ storage.Interface = (*Store)(nil)
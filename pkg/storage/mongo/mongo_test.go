@@ -0,0 +1,92 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"GoNews/pkg/storage"
+	"GoNews/pkg/storage/storagetest"
+)
+
+// Тестовая база данных, удаляется перед каждым подтестом.
+const testDatabase = "GoNews_test"
+
+// testURI возвращает адрес тестового сервера MongoDB.
+// Тесты запускаются только при заданной переменной GONEWS_TEST_MONGO_URI.
+func testURI(t *testing.T) string {
+	uri := os.Getenv("GONEWS_TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("GONEWS_TEST_MONGO_URI не задана")
+	}
+	return uri
+}
+
+// emptyStore подключается к тестовой базе и очищает её.
+func emptyStore(t *testing.T, uri string) *Store {
+	s, err := New(uri, testDatabase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	if err := s.db.Drop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ensureIndexes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	uri := testURI(t)
+
+	storagetest.Run(t, func(t *testing.T) storage.Interface {
+		return emptyStore(t, uri)
+	})
+}
+
+func TestOrphans(t *testing.T) {
+	uri := testURI(t)
+
+	storagetest.RunOrphans(t, func(t *testing.T) storage.Interface {
+		return emptyStore(t, uri)
+	}, func(t *testing.T, db storage.Interface, authorID int) {
+		_, err := db.(*Store).db.Collection(authorsCollection).DeleteOne(context.Background(), bson.D{{Key: "_id", Value: authorID}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// Публикации, созданные до появления истории, получают одну первую версию,
+// сколько бы раз ни запускалась миграция.
+func TestBackfillRevisions(t *testing.T) {
+	ctx := context.Background()
+	s := emptyStore(t, testURI(t))
+
+	legacy := postDoc{ID: 1, Title: "t", Content: "c", AuthorID: 1, CreatedAt: 1700000000}
+	if _, err := s.db.Collection(postsCollection).InsertOne(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := s.backfillRevisions(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// повторный запуск, например на другом сервере до появления отметки
+		if _, err := s.db.Collection(migrationsCollection).DeleteMany(ctx, bson.D{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revs, err := s.Revisions(ctx, legacy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 1 || revs[0].Title != legacy.Title || revs[0].CreatedAt != legacy.CreatedAt {
+		t.Errorf("версии публикации после миграции: %+v, ожидалась одна с её состоянием", revs)
+	}
+}
@@ -0,0 +1,19 @@
+-- Авторы и публикации.
+-- IF NOT EXISTS позволяет подключить миграции к базе, созданной вручную.
+CREATE TABLE IF NOT EXISTS authors (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    avatar_url TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id SERIAL PRIMARY KEY,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    author_id INTEGER NOT NULL REFERENCES authors(id),
+    created_at BIGINT NOT NULL DEFAULT extract(epoch from now())::bigint
+);
+
+-- Лента: сортировка по дате и фильтр по автору.
+CREATE INDEX IF NOT EXISTS posts_created_at_idx ON posts (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS posts_author_id_idx ON posts (author_id, created_at DESC, id DESC);
@@ -0,0 +1,7 @@
+-- Учётные записи для входа в веб-интерфейс, по одной на автора.
+CREATE TABLE accounts (
+    author_id INTEGER PRIMARY KEY REFERENCES authors(id) ON DELETE CASCADE,
+    login TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    admin BOOLEAN NOT NULL DEFAULT FALSE
+);
@@ -0,0 +1,12 @@
+-- API-токены авторов. Хранится только SHA-256 токена.
+CREATE TABLE tokens (
+    id SERIAL PRIMARY KEY,
+    author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX tokens_author_id_idx ON tokens (author_id);
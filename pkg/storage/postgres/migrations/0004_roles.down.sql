@@ -0,0 +1,3 @@
+ALTER TABLE accounts ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE accounts SET admin = TRUE WHERE role = 'admin';
+ALTER TABLE accounts DROP COLUMN role;
@@ -0,0 +1,5 @@
+-- Роли учётных записей вместо признака администратора.
+ALTER TABLE accounts ADD COLUMN role TEXT NOT NULL DEFAULT 'author'
+    CHECK (role IN ('admin', 'editor', 'author', 'reader'));
+UPDATE accounts SET role = 'admin' WHERE admin;
+ALTER TABLE accounts DROP COLUMN admin;
@@ -0,0 +1,13 @@
+-- Вложения публикаций. Файлы лежат в хранилище файлов, здесь - их адреса.
+CREATE TABLE attachments (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL CHECK (size >= 0),
+    url TEXT NOT NULL,
+    thumbnail_url TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX attachments_post_id_idx ON attachments (post_id, id);
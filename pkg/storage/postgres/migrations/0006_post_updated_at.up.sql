@@ -0,0 +1,2 @@
+-- Время последнего изменения публикации, 0 - публикацию не изменяли.
+ALTER TABLE posts ADD COLUMN updated_at BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS revisions;
+ALTER TABLE posts DROP COLUMN IF EXISTS updated_by;
@@ -0,0 +1,20 @@
+-- Автор последнего изменения публикации.
+ALTER TABLE posts ADD COLUMN updated_by INTEGER NOT NULL DEFAULT 0;
+UPDATE posts SET updated_by = author_id WHERE updated_at <> 0;
+
+-- Версии публикаций. Автор версии может быть уже удалён, поэтому внешнего ключа нет.
+CREATE TABLE revisions (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX revisions_post_id_idx ON revisions (post_id, id);
+
+-- Текущее состояние существующих публикаций становится их первой версией.
+INSERT INTO revisions (post_id, author_id, title, content, created_at)
+SELECT id, author_id, title, content, CASE WHEN updated_at <> 0 THEN updated_at ELSE created_at END
+FROM posts ORDER BY id;
@@ -0,0 +1,2 @@
+-- Версия сессий учётной записи: увеличивается при выходе и смене роли.
+ALTER TABLE accounts ADD COLUMN session_version INTEGER NOT NULL DEFAULT 0;
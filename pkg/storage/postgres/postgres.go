@@ -1,16 +1,18 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"net/url"
-	"os"
+	"strings"
 	"time"
 
+	"GoNews/pkg/rbac"
 	"GoNews/pkg/storage"
 
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/lib/pq" // PostgreSQL driver
 )
 
 // Store представляет собой хранилище PostgreSQL.
@@ -18,27 +20,13 @@ type Store struct {
 	db *sql.DB
 }
 
-// New - подключение к БД PostgreSQL
-func New() (*Store, error) {
-	// повторная проверка переменных
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-
-	// Проверка переменных .env
-	if host == "" || port == "" || user == "" || password == "" || dbname == "" {
-		return nil, fmt.Errorf("не все переменные окружения загружены")
+// New - подключение к БД PostgreSQL по строке подключения
+// в формате URL или key=value.
+func New(dsn string) (*Store, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("не задана строка подключения к PostgreSQL")
 	}
 
-	// Экранизация пароля (если требуется)
-	escapedPassword := url.QueryEscape(password)
-
-	// Строка подключения
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, escapedPassword, dbname)
-
 	// Открытие соединения с БД
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -49,6 +37,7 @@ func New() (*Store, error) {
 	// Проверка подключения
 	err = db.Ping()
 	if err != nil {
+		db.Close()
 		log.Printf("Ошибка пинга PostgreSQL: %v", err)
 		return nil, fmt.Errorf("не удалось подключиться к БД: %w", err)
 	}
@@ -66,76 +55,342 @@ func (s *Store) Close() {
 	}
 }
 
-func (s *Store) Posts() ([]storage.Post, error) {
-	rows, err := s.db.Query(`
-        SELECT posts.id, posts.title, posts.content, posts.created_at, 
+// Posts возвращает страницу публикаций с учётом фильтров и сортировки.
+func (s *Store) Posts(ctx context.Context, q storage.PostsQuery) (storage.PostsPage, error) {
+	if err := q.Validate(); err != nil {
+		return storage.PostsPage{}, err
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	// arg добавляет аргумент запроса и возвращает его плейсхолдер
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.AuthorID != 0 {
+		where = append(where, "posts.author_id = "+arg(q.AuthorID))
+	}
+	if q.From != 0 {
+		where = append(where, "posts.created_at >= "+arg(q.From))
+	}
+	if q.To != 0 {
+		where = append(where, "posts.created_at < "+arg(q.To))
+	}
+
+	order, cmp := "DESC", "<"
+	if q.Sort == storage.OldestFirst {
+		order, cmp = "ASC", ">"
+	}
+	if q.Cursor != "" {
+		c, err := storage.DecodeCursor(q.Cursor)
+		if err != nil {
+			return storage.PostsPage{}, err
+		}
+		where = append(where, fmt.Sprintf("(posts.created_at, posts.id) %s (%s, %s)", cmp, arg(c.CreatedAt), arg(c.ID)))
+	}
+
+	query := selectPosts
+	if len(where) > 0 {
+		query += "\n        WHERE " + strings.Join(where, " AND ")
+	}
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	limit := q.PageSize()
+	query += fmt.Sprintf("\n        ORDER BY posts.created_at %s, posts.id %s LIMIT %s", order, order, arg(limit+1))
+	if q.Cursor == "" && q.Offset > 0 {
+		query += " OFFSET " + arg(q.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return storage.PostsPage{}, err
+	}
+	defer rows.Close()
+
+	var posts []storage.Post
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return storage.PostsPage{}, err
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.PostsPage{}, err
+	}
+
+	return storage.NewPostsPage(posts, limit), nil
+}
+
+// Post возвращает публикацию по ID вместе с автором.
+func (s *Store) Post(ctx context.Context, id int) (storage.Post, error) {
+	row := s.db.QueryRowContext(ctx, selectPosts+"\n        WHERE posts.id = $1", id)
+	p, err := scanPost(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Post{}, fmt.Errorf("публикация %d: %w", id, storage.ErrNotFound)
+	}
+	return p, err
+}
+
+// Выборка публикаций вместе с авторами, порядок столбцов соответствует scanPost.
+const selectPosts = `
+        SELECT posts.id, posts.title, posts.content, posts.created_at, posts.updated_at, posts.updated_by, 
                authors.id, authors.name, authors.avatar_url 
         FROM posts 
-        JOIN authors ON posts.author_id = authors.id
-    `)
+        JOIN authors ON posts.author_id = authors.id`
+
+// scanner - общий интерфейс *sql.Row и *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPost читает строку выборки selectPosts.
+func scanPost(row scanner) (storage.Post, error) {
+	var p storage.Post
+	var a storage.Author //объект автора
+	var createdAtUnix int64
+
+	if err := row.Scan(&p.ID, &p.Title, &p.Content, &createdAtUnix, &p.UpdatedAt, &p.UpdatedBy, &a.ID, &a.Name, &a.AvatarURL); err != nil {
+		return storage.Post{}, err
+	}
+	// Конвертируем Unix timestamp в строку с форматом даты
+	p.CreatedAt = createdAtUnix
+	p.FormattedDate = storage.FormatDate(createdAtUnix)
+
+	p.AuthorID = a.ID
+	p.Author = a // Присваиваем автора в структуру поста
+	return p, nil
+}
+
+// Добавление публикации вместе с её первой версией
+func (s *Store) AddPost(ctx context.Context, p storage.Post) (int, error) {
+	if p.CreatedAt == 0 {
+		p.CreatedAt = time.Now().Unix()
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, "INSERT INTO posts (title, content, author_id, created_at, updated_at, updated_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		p.Title, p.Content, p.AuthorID, p.CreatedAt, p.UpdatedAt, p.UpdatedBy).Scan(&p.ID)
+	if err != nil {
+		return 0, convertError(err)
+	}
+	if err := addRevision(ctx, tx, p); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return p.ID, nil
+}
+
+// Обновление публикации с сохранением новой версии. Дата создания не меняется.
+func (s *Store) UpdatePost(ctx context.Context, p storage.Post) error {
+	if p.UpdatedAt == 0 {
+		p.UpdatedAt = time.Now().Unix()
+	}
+	if p.UpdatedBy == 0 {
+		p.UpdatedBy = p.AuthorID
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE posts SET title=$1, content=$2, author_id=$3, updated_at=$4, updated_by=$5 WHERE id=$6",
+		p.Title, p.Content, p.AuthorID, p.UpdatedAt, p.UpdatedBy, p.ID)
+	if err != nil {
+		return convertError(err)
+	}
+	if err := checkAffected(res, "публикация %d", p.ID); err != nil {
+		return err
+	}
+	if err := addRevision(ctx, tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addRevision сохраняет состояние публикации как новую версию.
+func addRevision(ctx context.Context, tx *sql.Tx, p storage.Post) error {
+	rev := p.Snapshot()
+	_, err := tx.ExecContext(ctx, "INSERT INTO revisions (post_id, author_id, title, content, created_at) VALUES ($1, $2, $3, $4, $5)",
+		rev.PostID, rev.AuthorID, rev.Title, rev.Content, rev.CreatedAt)
+	return convertError(err)
+}
+
+// Получение версий публикации
+func (s *Store) Revisions(ctx context.Context, postID int) ([]storage.Revision, error) {
+	rows, err := s.db.QueryContext(ctx, selectRevisions+" WHERE post_id = $1 ORDER BY id", postID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []storage.Post
+	var res []storage.Revision
 	for rows.Next() {
-		var p storage.Post
-		var a storage.Author //объект автора
-		var createdAtUnix int64
-
-		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &createdAtUnix, &a.ID, &a.Name, &a.AvatarURL); err != nil {
+		rev, err := scanRevision(rows)
+		if err != nil {
 			return nil, err
 		}
-		// Конвертируем Unix timestamp в строку с форматом даты
-		p.CreatedAt = createdAtUnix
-		p.FormattedDate = time.Unix(createdAtUnix, 0).Format("02.01.2006 15:04")
+		res = append(res, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
 
-		p.Author = a // Присваиваем автора в структуру поста
-		posts = append(posts, p)
+// Получение версии публикации
+func (s *Store) Revision(ctx context.Context, postID, id int) (storage.Revision, error) {
+	row := s.db.QueryRowContext(ctx, selectRevisions+" WHERE id = $1 AND post_id = $2", id, postID)
+	rev, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Revision{}, fmt.Errorf("версия %d публикации %d: %w", id, postID, storage.ErrNotFound)
 	}
+	return rev, err
+}
+
+// Выборка версий, порядок столбцов соответствует scanRevision.
+const selectRevisions = `SELECT id, post_id, author_id, title, content, created_at FROM revisions`
 
-	return posts, nil
+// scanRevision читает строку выборки selectRevisions.
+func scanRevision(row scanner) (storage.Revision, error) {
+	var rev storage.Revision
+	err := row.Scan(&rev.ID, &rev.PostID, &rev.AuthorID, &rev.Title, &rev.Content, &rev.CreatedAt)
+	return rev, err
 }
 
-// Добавление публикации
-func (s *Store) AddPost(p storage.Post) error {
-	// p.CreatedAt = time.Now().Unix()
-	_, err := s.db.Exec("INSERT INTO posts (title, content, author_id) VALUES ($1, $2, $3)",
-		p.Title, p.Content, p.AuthorID)
-	return err
+// Удаление публикации. Вложения и версии удаляются каскадно по внешнему ключу.
+func (s *Store) DeletePost(ctx context.Context, p storage.Post) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM posts WHERE id=$1", p.ID)
+	if err != nil {
+		return convertError(err)
+	}
+	return checkAffected(res, "публикация %d", p.ID)
 }
 
-// Обновление публикации
-func (s *Store) UpdatePost(p storage.Post) error {
-	_, err := s.db.Exec("UPDATE posts SET title=$1, content=$2, author_id=$3, created_at=$4  WHERE id=$5",
-		p.Title, p.Content, p.AuthorID, p.CreatedAt, p.ID)
-	return err
+// Добавление вложения
+func (s *Store) AddAttachment(ctx context.Context, att storage.Attachment) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `
+        INSERT INTO attachments (post_id, name, content_type, size, url, thumbnail_url, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		att.PostID, att.Name, att.ContentType, att.Size, att.URL, att.ThumbnailURL, att.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, convertError(err)
+	}
+	return id, nil
 }
 
-// Удаление публикации
-func (s *Store) DeletePost(p storage.Post) error {
-	_, err := s.db.Exec("DELETE FROM posts WHERE id=$1", p.ID)
-	return err
+// Получение вложений публикаций
+func (s *Store) Attachments(ctx context.Context, postIDs ...int) ([]storage.Attachment, error) {
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, post_id, name, content_type, size, url, thumbnail_url, created_at
+        FROM attachments WHERE post_id = ANY($1) ORDER BY id`, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.Attachment
+	for rows.Next() {
+		var att storage.Attachment
+		err := rows.Scan(&att.ID, &att.PostID, &att.Name, &att.ContentType, &att.Size, &att.URL, &att.ThumbnailURL, &att.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, att)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Удаление вложения публикации
+func (s *Store) DeleteAttachment(ctx context.Context, postID, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM attachments WHERE id = $1 AND post_id = $2`, id, postID)
+	if err != nil {
+		return convertError(err)
+	}
+	return checkAffected(res, "вложение %d публикации %d", id, postID)
 }
 
-func (s *Store) AddAuthor(a storage.Author) error {
-	_, err := s.db.Exec(`INSERT INTO authors (name, avatar_url) VALUES ($1, $2)`, a.Name, a.AvatarURL)
+// checkAffected возвращает storage.ErrNotFound, если запрос не затронул ни одной строки.
+func checkAffected(res sql.Result, format string, args ...any) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf(format+": %w", append(args, storage.ErrNotFound)...)
+	}
+	return nil
+}
+
+// Коды ошибок PostgreSQL, которые приводятся к ошибкам пакета storage.
+const (
+	codeUniqueViolation     = "23505"
+	codeForeignKeyViolation = "23503"
+	codeNotNullViolation    = "23502"
+	codeCheckViolation      = "23514"
+	codeStringTooLong       = "22001"
+)
+
+// convertError приводит ошибки драйвера к ошибкам пакета storage.
+func convertError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case codeUniqueViolation:
+		return fmt.Errorf("%w: %s", storage.ErrConflict, pqErr.Message)
+	case codeForeignKeyViolation, codeNotNullViolation, codeCheckViolation, codeStringTooLong:
+		return fmt.Errorf("%w: %s", storage.ErrInvalid, pqErr.Message)
+	}
 	return err
 }
 
-func (s *Store) GetAuthorByID(id int) (storage.Author, error) {
+func (s *Store) AddAuthor(ctx context.Context, a storage.Author) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `INSERT INTO authors (name, avatar_url) VALUES ($1, $2) RETURNING id`,
+		a.Name, a.AvatarURL).Scan(&id)
+	if err != nil {
+		return 0, convertError(err)
+	}
+	return id, nil
+}
+
+func (s *Store) GetAuthorByID(ctx context.Context, id int) (storage.Author, error) {
 	var a storage.Author
-	err := s.db.QueryRow(`SELECT id, name, avatar_url FROM authors WHERE id = $1`, id).
+	err := s.db.QueryRowContext(ctx, `SELECT id, name, avatar_url FROM authors WHERE id = $1`, id).
 		Scan(&a.ID, &a.Name, &a.AvatarURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Author{}, fmt.Errorf("автор %d: %w", id, storage.ErrNotFound)
+	}
 	if err != nil {
 		return storage.Author{}, err
 	}
 	return a, nil
 }
 
-func (s *Store) GetAuthors() ([]storage.Author, error) {
-	rows, err := s.db.Query("SELECT id, name, avatar_url FROM authors")
+func (s *Store) GetAuthors(ctx context.Context) ([]storage.Author, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, avatar_url FROM authors ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
@@ -149,6 +404,255 @@ func (s *Store) GetAuthors() ([]storage.Author, error) {
 		}
 		authors = append(authors, a)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return authors, nil
 }
+
+// Изменение автора
+func (s *Store) UpdateAuthor(ctx context.Context, a storage.Author) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE authors SET name = $1, avatar_url = $2 WHERE id = $3`,
+		a.Name, a.AvatarURL, a.ID)
+	if err != nil {
+		return convertError(err)
+	}
+	return checkAffected(res, "автор %d", a.ID)
+}
+
+// Удаление автора и, в зависимости от opts, его публикаций.
+// Всё выполняется в одной транзакции.
+func (s *Store) DeleteAuthor(ctx context.Context, id int, opts storage.DeleteAuthorOptions) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокируем автора: параллельное добавление его публикаций дождётся конца транзакции
+	err = tx.QueryRowContext(ctx, `SELECT id FROM authors WHERE id = $1 FOR UPDATE`, id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("автор %d: %w", id, storage.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkLastAdmin(ctx, tx, id); err != nil {
+		return err
+	}
+
+	switch opts.Posts {
+	case storage.RestrictPosts:
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM posts WHERE author_id = $1)`, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("у автора %d есть публикации: %w", id, storage.ErrConflict)
+		}
+	case storage.CascadePosts:
+		if _, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE author_id = $1`, id); err != nil {
+			return err
+		}
+	case storage.ReassignPosts:
+		if opts.ReassignTo == id {
+			return fmt.Errorf("новый автор публикаций совпадает с удаляемым: %w", storage.ErrInvalid)
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE posts SET author_id = $1 WHERE author_id = $2`, opts.ReassignTo, id)
+		if err != nil {
+			return convertError(err)
+		}
+	default:
+		return fmt.Errorf("режим удаления %d: %w", opts.Posts, storage.ErrInvalid)
+	}
+
+	// Учётная запись и токены автора удаляются каскадно по внешнему ключу
+	if _, err := tx.ExecContext(ctx, `DELETE FROM authors WHERE id = $1`, id); err != nil {
+		return convertError(err)
+	}
+	return tx.Commit()
+}
+
+// Добавление учётной записи
+func (s *Store) AddAccount(ctx context.Context, acc storage.Account) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO accounts (author_id, login, password_hash, role) VALUES ($1, $2, $3, $4)`,
+		acc.AuthorID, acc.Login, acc.PasswordHash, acc.Role)
+	return convertError(err)
+}
+
+// Выборка учётных записей, порядок столбцов соответствует scanAccount.
+const selectAccounts = `SELECT author_id, login, password_hash, role, session_version FROM accounts`
+
+func scanAccount(row scanner) (storage.Account, error) {
+	var acc storage.Account
+	err := row.Scan(&acc.AuthorID, &acc.Login, &acc.PasswordHash, &acc.Role, &acc.SessionVersion)
+	return acc, err
+}
+
+// Получение учётной записи по логину
+func (s *Store) AccountByLogin(ctx context.Context, login string) (storage.Account, error) {
+	acc, err := scanAccount(s.db.QueryRowContext(ctx, selectAccounts+` WHERE login = $1`, login))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Account{}, fmt.Errorf("учётная запись %q: %w", login, storage.ErrNotFound)
+	}
+	return acc, err
+}
+
+// Получение учётной записи автора
+func (s *Store) AccountByAuthor(ctx context.Context, authorID int) (storage.Account, error) {
+	acc, err := scanAccount(s.db.QueryRowContext(ctx, selectAccounts+` WHERE author_id = $1`, authorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Account{}, fmt.Errorf("учётная запись автора %d: %w", authorID, storage.ErrNotFound)
+	}
+	return acc, err
+}
+
+// Получение всех учётных записей
+func (s *Store) Accounts(ctx context.Context) ([]storage.Account, error) {
+	rows, err := s.db.QueryContext(ctx, selectAccounts+` ORDER BY author_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []storage.Account
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// Назначение роли
+func (s *Store) SetRole(ctx context.Context, authorID int, role rbac.Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("роль %q: %w", role, storage.ErrInvalid)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if role != rbac.Admin {
+		if err := checkLastAdmin(ctx, tx, authorID); err != nil {
+			return err
+		}
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE accounts SET role = $1, session_version = session_version + 1 WHERE author_id = $2`, role, authorID)
+	if err != nil {
+		return convertError(err)
+	}
+	if err := checkAffected(res, "учётная запись автора %d", authorID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkLastAdmin возвращает ErrLastAdmin, если учётная запись автора authorID -
+// единственный администратор. Учётные записи администраторов блокируются
+// до конца транзакции tx, поэтому параллельные понижения и удаления
+// администраторов проверяются по очереди и не оставят базу без администратора.
+func checkLastAdmin(ctx context.Context, tx *sql.Tx, authorID int) error {
+	rows, err := tx.QueryContext(ctx, `SELECT author_id FROM accounts WHERE role = 'admin' FOR UPDATE`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	admins, target := 0, false
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		admins++
+		target = target || id == authorID
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if target && admins == 1 {
+		return fmt.Errorf("учётная запись автора %d: %w", authorID, storage.ErrLastAdmin)
+	}
+	return nil
+}
+
+// Отзыв сессий учётной записи
+func (s *Store) RevokeSessions(ctx context.Context, authorID int) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE accounts SET session_version = session_version + 1 WHERE author_id = $1`, authorID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, "учётная запись автора %d", authorID)
+}
+
+// Добавление API-токена
+func (s *Store) AddToken(ctx context.Context, t storage.Token) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `
+        INSERT INTO tokens (author_id, name, hash, scopes, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		t.AuthorID, t.Name, t.Hash, pq.Array(t.Scopes), t.CreatedAt, t.ExpiresAt).Scan(&id)
+	if err != nil {
+		return 0, convertError(err)
+	}
+	return id, nil
+}
+
+// Выборка токенов, порядок столбцов соответствует scanToken.
+const selectTokens = `SELECT id, author_id, name, hash, scopes, created_at, expires_at FROM tokens`
+
+func scanToken(row scanner) (storage.Token, error) {
+	var t storage.Token
+	err := row.Scan(&t.ID, &t.AuthorID, &t.Name, &t.Hash, pq.Array(&t.Scopes), &t.CreatedAt, &t.ExpiresAt)
+	return t, err
+}
+
+// Получение API-токена по хешу
+func (s *Store) TokenByHash(ctx context.Context, hash string) (storage.Token, error) {
+	t, err := scanToken(s.db.QueryRowContext(ctx, selectTokens+` WHERE hash = $1`, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Token{}, fmt.Errorf("токен: %w", storage.ErrNotFound)
+	}
+	return t, err
+}
+
+// Получение API-токенов автора
+func (s *Store) Tokens(ctx context.Context, authorID int) ([]storage.Token, error) {
+	rows, err := s.db.QueryContext(ctx, selectTokens+` WHERE author_id = $1 ORDER BY id`, authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []storage.Token
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Отзыв API-токена автора
+func (s *Store) DeleteToken(ctx context.Context, authorID, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM tokens WHERE id = $1 AND author_id = $2`, id, authorID)
+	if err != nil {
+		return convertError(err)
+	}
+	return checkAffected(res, "токен %d автора %d", id, authorID)
+}
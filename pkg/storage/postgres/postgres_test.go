@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"GoNews/pkg/storage"
+	"GoNews/pkg/storage/storagetest"
+)
+
+// testStore подключается к базе из GONEWS_TEST_POSTGRES_DSN и применяет миграции.
+// Без переменной тест пропускается. Все данные в указанной базе удаляются,
+// поэтому используйте отдельную базу.
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	dsn := os.Getenv("GONEWS_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GONEWS_TEST_POSTGRES_DSN не задана")
+	}
+
+	s, err := New(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	if _, err := s.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	testStore(t)
+
+	storagetest.Run(t, func(t *testing.T) storage.Interface {
+		s := testStore(t)
+		if _, err := s.db.Exec(`TRUNCATE posts, authors RESTART IDENTITY CASCADE`); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	all, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range all {
+		if m.Version != i+1 {
+			t.Errorf("миграция %s: версия %d, ожидалась %d", m.Name, m.Version, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("у миграции %d_%s нет отката", m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigrateRollback(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	all, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := s.Rollback(ctx, len(all))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(all) {
+		t.Errorf("откачено %d миграций, ожидалось %d", len(done), len(all))
+	}
+
+	status, err := s.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range status {
+		if st.Applied() {
+			t.Errorf("миграция %d осталась применённой после отката", st.Version)
+		}
+	}
+
+	done, err = s.Migrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(all) {
+		t.Errorf("применено %d миграций, ожидалось %d", len(done), len(all))
+	}
+}
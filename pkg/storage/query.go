@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Размеры страницы публикаций.
+const (
+	DefaultLimit = 20  // размер страницы по умолчанию
+	MaxLimit     = 100 // максимальный размер страницы
+)
+
+// SortOrder - порядок сортировки публикаций по дате создания.
+type SortOrder int
+
+const (
+	NewestFirst SortOrder = iota // сначала новые
+	OldestFirst                  // сначала старые
+)
+
+// PostsQuery - параметры выборки публикаций.
+// Нулевое значение означает первую страницу всех публикаций,
+// отсортированных от новых к старым.
+type PostsQuery struct {
+	Limit    int       // размер страницы (0 - DefaultLimit)
+	Offset   int       // смещение от начала выборки, не используется вместе с Cursor
+	Cursor   string    // курсор из PostsPage.NextCursor предыдущей страницы
+	AuthorID int       // только публикации автора (0 - все авторы)
+	From     int64     // CreatedAt >= From (0 - без ограничения)
+	To       int64     // CreatedAt < To (0 - без ограничения)
+	Sort     SortOrder // порядок сортировки
+}
+
+// PageSize возвращает размер страницы с учётом значения по умолчанию и ограничения.
+func (q PostsQuery) PageSize() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultLimit
+	case q.Limit > MaxLimit:
+		return MaxLimit
+	}
+	return q.Limit
+}
+
+// PostsPage - страница публикаций.
+type PostsPage struct {
+	Posts      []Post
+	NextCursor string // курсор следующей страницы, пустой на последней странице
+}
+
+// NewPostsPage формирует страницу из выборки, запрошенной с запасом в одну запись:
+// лишняя запись отбрасывается и служит признаком наличия следующей страницы.
+func NewPostsPage(posts []Post, limit int) PostsPage {
+	if posts == nil {
+		posts = []Post{}
+	}
+	if len(posts) <= limit {
+		return PostsPage{Posts: posts}
+	}
+	posts = posts[:limit]
+	last := posts[len(posts)-1]
+	return PostsPage{
+		Posts:      posts,
+		NextCursor: Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.String(),
+	}
+}
+
+// Cursor - позиция в выборке публикаций: последняя публикация предыдущей страницы.
+// Публикации упорядочены по паре (CreatedAt, ID), поэтому курсор устойчив
+// к добавлению новых записей между запросами страниц.
+type Cursor struct {
+	CreatedAt int64
+	ID        int
+}
+
+// String кодирует курсор для передачи клиенту.
+func (c Cursor) String() string {
+	raw := strconv.FormatInt(c.CreatedAt, 10) + ":" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// After сообщает, находится ли публикация после курсора при заданном порядке сортировки.
+func (c Cursor) After(p Post, order SortOrder) bool {
+	if order == OldestFirst {
+		return p.CreatedAt > c.CreatedAt || (p.CreatedAt == c.CreatedAt && p.ID > c.ID)
+	}
+	return p.CreatedAt < c.CreatedAt || (p.CreatedAt == c.CreatedAt && p.ID < c.ID)
+}
+
+// ErrBadCursor - курсор не удалось разобрать.
+var ErrBadCursor = fmt.Errorf("%w: курсор", ErrInvalid)
+
+// DecodeCursor разбирает курсор, полученный от клиента.
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrBadCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return Cursor{}, ErrBadCursor
+	}
+	var c Cursor
+	if c.CreatedAt, err = strconv.ParseInt(ts, 10, 64); err != nil {
+		return Cursor{}, ErrBadCursor
+	}
+	if c.ID, err = strconv.Atoi(id); err != nil {
+		return Cursor{}, ErrBadCursor
+	}
+	return c, nil
+}
+
+// Validate проверяет параметры выборки.
+func (q PostsQuery) Validate() error {
+	if q.Offset < 0 {
+		return fmt.Errorf("%w: отрицательное смещение %d", ErrInvalid, q.Offset)
+	}
+	if q.Cursor != "" {
+		if q.Offset > 0 {
+			return fmt.Errorf("%w: смещение и курсор нельзя использовать вместе", ErrInvalid)
+		}
+		if _, err := DecodeCursor(q.Cursor); err != nil {
+			return err
+		}
+	}
+	if q.From != 0 && q.To != 0 && q.From >= q.To {
+		return fmt.Errorf("%w: пустой диапазон дат", ErrInvalid)
+	}
+	return nil
+}
@@ -1,5 +1,17 @@
 package storage
 
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"GoNews/pkg/rbac"
+)
+
 // Post - публикация.
 type Post struct {
 	ID            int
@@ -8,10 +20,29 @@ type Post struct {
 	AuthorID      int
 	Author        Author
 	CreatedAt     int64
+	UpdatedAt     int64  // время последнего изменения, 0 - публикацию не изменяли
+	UpdatedBy     int    // ID автора последнего изменения (по умолчанию - автор публикации)
 	FormattedDate string // Дополнительное поле для вывода в шаблон
 	// PublishedAt int64
 }
 
+// DateLayout - формат даты публикации для вывода в шаблонах.
+const DateLayout = "02.01.2006 15:04"
+
+// FormatDate форматирует Unix-время публикации для поля FormattedDate.
+func FormatDate(unix int64) string {
+	return time.Unix(unix, 0).Format(DateLayout)
+}
+
+// FormattedUpdatedAt возвращает дату последнего изменения публикации для вывода
+// в шаблонах или пустую строку, если публикацию не изменяли.
+func (p Post) FormattedUpdatedAt() string {
+	if p.UpdatedAt == 0 {
+		return ""
+	}
+	return FormatDate(p.UpdatedAt)
+}
+
 // Author - автор публикаций.
 type Author struct {
 	ID        int
@@ -19,20 +50,200 @@ type Author struct {
 	AvatarURL string
 }
 
+// AvatarSizes - стороны квадратных вариантов аватарки в пикселях, по возрастанию.
+// AvatarURL указывает на наименьший вариант, остальные лежат рядом с ним
+// с другим суффиксом: av_<id>_32.png, av_<id>_64.png и т. д.
+var AvatarSizes = []int{32, 64, 128, 256}
+
+// URL наименьшего варианта аватарки: префикс и расширение общие для всех вариантов.
+var sizedAvatar = regexp.MustCompile(`^(.*/av_[0-9a-f]{32})_32(\.(?:jpg|png))$`)
+
+// AvatarVariant - вариант аватарки со стороной Size пикселей.
+type AvatarVariant struct {
+	Size int
+	URL  string
+}
+
+// Avatars возвращает варианты аватарки по возрастанию размера.
+// У аватарок, загруженных до появления вариантов, и внешних адресов
+// вариант один - AvatarURL размером 32.
+func (a Author) Avatars() []AvatarVariant {
+	if a.AvatarURL == "" {
+		return nil
+	}
+	m := sizedAvatar.FindStringSubmatch(a.AvatarURL)
+	if m == nil {
+		return []AvatarVariant{{Size: AvatarSizes[0], URL: a.AvatarURL}}
+	}
+	res := make([]AvatarVariant, len(AvatarSizes))
+	for i, size := range AvatarSizes {
+		res[i] = AvatarVariant{Size: size, URL: m[1] + "_" + strconv.Itoa(size) + m[2]}
+	}
+	return res
+}
+
+// Avatar возвращает URL наименьшего варианта аватарки со стороной не меньше size,
+// а если такого нет - наибольшего.
+func (a Author) Avatar(size int) string {
+	variants := a.Avatars()
+	for _, v := range variants {
+		if v.Size >= size {
+			return v.URL
+		}
+	}
+	if len(variants) == 0 {
+		return ""
+	}
+	return variants[len(variants)-1].URL
+}
+
+// AvatarSrcset возвращает значение атрибута srcset со всеми вариантами аватарки.
+func (a Author) AvatarSrcset() string {
+	var parts []string
+	for _, v := range a.Avatars() {
+		parts = append(parts, fmt.Sprintf("%s %dw", v.URL, v.Size))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Revision - версия публикации: заголовок и текст после создания или очередного
+// изменения, кто и когда их сохранил. Версии добавляют AddPost и UpdatePost,
+// удаляются они вместе с публикацией.
+type Revision struct {
+	ID        int
+	PostID    int
+	AuthorID  int // автор изменения; может быть уже удалён
+	Title     string
+	Content   string
+	CreatedAt int64
+}
+
+// Snapshot возвращает текущее состояние публикации как версию без ID.
+func (p Post) Snapshot() Revision {
+	r := Revision{PostID: p.ID, AuthorID: p.AuthorID, Title: p.Title, Content: p.Content, CreatedAt: p.CreatedAt}
+	if p.UpdatedAt != 0 {
+		r.CreatedAt = p.UpdatedAt
+	}
+	if p.UpdatedBy != 0 {
+		r.AuthorID = p.UpdatedBy
+	}
+	return r
+}
+
+// FormattedDate возвращает дату сохранения версии для вывода в шаблонах.
+func (r Revision) FormattedDate() string {
+	return FormatDate(r.CreatedAt)
+}
+
+// Attachment - файл, приложенный к публикации.
+// Удаляется вместе с публикацией.
+type Attachment struct {
+	ID           int
+	PostID       int
+	Name         string // имя файла при загрузке: подпись в галерее и ссылка из текста
+	ContentType  string
+	Size         int64  // размер в байтах
+	URL          string // адрес файла
+	ThumbnailURL string // адрес уменьшенной копии; пусто, если файл не изображение
+	CreatedAt    int64
+}
+
+// IsImage сообщает, что вложение - изображение с уменьшенной копией.
+func (a Attachment) IsImage() bool {
+	return a.ThumbnailURL != ""
+}
+
+// Account - учётная запись для входа в веб-интерфейс.
+// Принадлежит автору: публикации создаются от его имени.
+type Account struct {
+	AuthorID       int
+	Login          string
+	PasswordHash   string    // bcrypt-хеш пароля
+	Role           rbac.Role // роль, определяет права в веб-интерфейсе и API
+	SessionVersion int       // версия сессий: растёт при выходе и смене роли, старые cookie перестают действовать
+}
+
+// Token - API-токен автора для доступа к JSON API.
+// Сам токен не хранится: по нему ищут только его хеш SHA-256.
+type Token struct {
+	ID        int
+	AuthorID  int
+	Name      string   // описание, например имя скрипта автоматизации
+	Hash      string   // SHA-256 токена в шестнадцатеричном виде
+	Scopes    []string // области действия, например posts:write
+	CreatedAt int64
+	ExpiresAt int64 // 0 - бессрочный
+}
+
+// DeletePostsMode - что делать с публикациями удаляемого автора.
+type DeletePostsMode int
+
+const (
+	RestrictPosts DeletePostsMode = iota // отказать (ErrConflict), если у автора есть публикации
+	CascadePosts                         // удалить публикации вместе с автором
+	ReassignPosts                        // передать публикации другому автору
+)
+
+// DeleteAuthorOptions - параметры удаления автора.
+type DeleteAuthorOptions struct {
+	Posts      DeletePostsMode
+	ReassignTo int // новый автор публикаций для ReassignPosts
+}
+
 // PageData - структура для передачи данных в шаблоны.
 type PageData struct {
 	Authors []Author // Список авторов
+	Posts   []Post   // Публикации текущей страницы
+	Post    Post     // Публикация на её отдельной странице
+	Author  Author   // Автор на странице профиля
+	PrevURL string   // Ссылка на предыдущую страницу (пусто на первой)
+	NextURL string   // Ссылка на следующую страницу (пусто на последней)
+	Message string   // Сообщение об ошибке для формы
+	Next    string   // Адрес для перехода после входа
+
+	Attachments map[int][]Attachment // Вложения публикаций страницы по ID публикации
+
+	Errors map[string]string // Ошибки проверки формы по полям
+	Form   url.Values        // Отправленные значения формы для повторного вывода
 }
 
 // Interface задаёт контракт на работу с БД.
+// Все методы принимают контекст запроса и должны прекращать работу
+// при его отмене или истечении дедлайна.
 type Interface interface {
-	Posts() ([]Post, error) // получение всех публикаций
-	AddPost(Post) error     // создание новой публикации
-	UpdatePost(Post) error  // обновление публикации
-	DeletePost(Post) error  // удаление публикации по ID
+	Posts(context.Context, PostsQuery) (PostsPage, error) // получение страницы публикаций
+	Post(context.Context, int) (Post, error)              // получение публикации по ID
+	AddPost(context.Context, Post) (int, error)           // создание новой публикации и её первой версии, возвращает её ID
+	UpdatePost(context.Context, Post) error               // обновление публикации и её новая версия; дата создания не меняется
+	DeletePost(context.Context, Post) error               // удаление публикации по ID вместе с её вложениями и версиями
+
+	// История изменений публикаций
+	Revisions(ctx context.Context, postID int) ([]Revision, error)  // версии публикации в порядке создания
+	Revision(ctx context.Context, postID, id int) (Revision, error) // версия публикации по ID
+
+	// Вложения публикаций
+	AddAttachment(context.Context, Attachment) (int, error)                // добавление вложения к публикации, возвращает его ID
+	Attachments(ctx context.Context, postIDs ...int) ([]Attachment, error) // вложения публикаций в порядке ID
+	DeleteAttachment(ctx context.Context, postID, id int) error            // удаление вложения публикации
 
 	// Новый метод для работы с авторами
-	AddAuthor(Author) error            // создание нового автора
-	GetAuthorByID(int) (Author, error) // получение автора по ID
-	GetAuthors() ([]Author, error)     // получение всех авторов
+	AddAuthor(context.Context, Author) (int, error)               // создание нового автора, возвращает его ID
+	GetAuthorByID(context.Context, int) (Author, error)           // получение автора по ID
+	GetAuthors(context.Context) ([]Author, error)                 // получение всех авторов
+	UpdateAuthor(context.Context, Author) error                   // изменение имени и аватарки автора
+	DeleteAuthor(context.Context, int, DeleteAuthorOptions) error // удаление автора вместе с его учётной записью, токенами, вложениями и версиями удалённых публикаций; последнего администратора - ErrLastAdmin
+
+	// Учётные записи
+	AddAccount(context.Context, Account) error                       // создание учётной записи для существующего автора
+	AccountByLogin(context.Context, string) (Account, error)         // поиск учётной записи по логину
+	AccountByAuthor(context.Context, int) (Account, error)           // поиск учётной записи по ID автора
+	Accounts(context.Context) ([]Account, error)                     // все учётные записи в порядке ID авторов
+	SetRole(ctx context.Context, authorID int, role rbac.Role) error // назначение роли; отзывает сессии учётной записи, с последнего администратора не снимает (ErrLastAdmin)
+	RevokeSessions(ctx context.Context, authorID int) error          // отзыв всех сессий учётной записи
+
+	// API-токены
+	AddToken(context.Context, Token) (int, error)            // создание токена, возвращает его ID
+	TokenByHash(context.Context, string) (Token, error)      // поиск токена по хешу
+	Tokens(context.Context, int) ([]Token, error)            // токены автора в порядке создания
+	DeleteToken(ctx context.Context, authorID, id int) error // отзыв токена автора
 }
@@ -0,0 +1,1157 @@
+// Package storagetest содержит общий набор тестов, которому должна
+// соответствовать любая реализация storage.Interface.
+//
+// Использование в пакете хранилища:
+//
+//	func TestStore(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Interface {
+//			return memdb.New()
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"GoNews/pkg/rbac"
+	"GoNews/pkg/storage"
+)
+
+// Factory создаёт пустое хранилище для одного подтеста.
+// Ресурсы хранилища освобождаются через t.Cleanup.
+type Factory func(t *testing.T) storage.Interface
+
+// Run запускает набор тестов против хранилищ, созданных newStore.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, db storage.Interface)
+	}{
+		{"Authors", testAuthors},
+		{"AuthorNotFound", testAuthorNotFound},
+		{"UpdateAuthor", testUpdateAuthor},
+		{"UpdateAuthorNotFound", testUpdateAuthorNotFound},
+		{"DeleteAuthorRestrict", testDeleteAuthorRestrict},
+		{"DeleteAuthorCascade", testDeleteAuthorCascade},
+		{"DeleteAuthorReassign", testDeleteAuthorReassign},
+		{"DeleteAuthorNotFound", testDeleteAuthorNotFound},
+		{"Accounts", testAccounts},
+		{"AccountConflict", testAccountConflict},
+		{"AccountUnknownAuthor", testAccountUnknownAuthor},
+		{"AccountNotFound", testAccountNotFound},
+		{"DeleteAuthorAccount", testDeleteAuthorAccount},
+		{"SetRole", testSetRole},
+		{"RevokeSessions", testRevokeSessions},
+		{"LastAdmin", testLastAdmin},
+		{"InvalidRole", testInvalidRole},
+		{"Tokens", testTokens},
+		{"TokenConflict", testTokenConflict},
+		{"TokenUnknownAuthor", testTokenUnknownAuthor},
+		{"DeleteToken", testDeleteToken},
+		{"DeleteAuthorTokens", testDeleteAuthorTokens},
+		{"AddPost", testAddPost},
+		{"AddPostDefaultDate", testAddPostDefaultDate},
+		{"AddPostUnknownAuthor", testAddPostUnknownAuthor},
+		{"PostNotFound", testPostNotFound},
+		{"UpdatePost", testUpdatePost},
+		{"UpdatePostDefaultDate", testUpdatePostDefaultDate},
+		{"UpdatePostNotFound", testUpdatePostNotFound},
+		{"DeletePost", testDeletePost},
+		{"DeletePostNotFound", testDeletePostNotFound},
+		{"Attachments", testAttachments},
+		{"AttachmentUnknownPost", testAttachmentUnknownPost},
+		{"DeleteAttachment", testDeleteAttachment},
+		{"DeletePostAttachments", testDeletePostAttachments},
+		{"DeleteAuthorAttachments", testDeleteAuthorAttachments},
+		{"Revisions", testRevisions},
+		{"RevisionNotFound", testRevisionNotFound},
+		{"DeletePostRevisions", testDeletePostRevisions},
+		{"DeleteAuthorRevisions", testDeleteAuthorRevisions},
+		{"Ordering", testOrdering},
+		{"CursorPagination", testCursorPagination},
+		{"OffsetPagination", testOffsetPagination},
+		{"Filters", testFilters},
+		{"BadQuery", testBadQuery},
+		{"CanceledContext", testCanceledContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// Orphan удаляет автора authorID в обход storage.Interface, оставляя его
+// публикации: так бывает после сбоя удаления в базе без транзакций.
+type Orphan func(t *testing.T, db storage.Interface, authorID int)
+
+// RunOrphans проверяет, что публикации удалённого автора, оставшиеся в базе,
+// не попадают в выборку и не укорачивают её страницы.
+func RunOrphans(t *testing.T, newStore Factory, orphan Orphan) {
+	db := newStore(t)
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	own := seed(t, db, a.ID, 1, 3, 5)
+	seed(t, db, b.ID, 2, 4, 6)
+	orphan(t, db, b.ID)
+
+	var pages [][]int
+	var got []storage.Post
+	q := storage.PostsQuery{Limit: 2}
+	for i := 0; i < 10; i++ {
+		page, err := db.Posts(context.Background(), q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, ids(page.Posts))
+		got = append(got, page.Posts...)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if fmt.Sprint(pages) != fmt.Sprint([][]int{{own[2], own[1]}, {own[0]}}) {
+		t.Errorf("страницы = %v, ожидались страницы по 2 и 1 записи автора %d", pages, a.ID)
+	}
+	checkIDs(t, "по курсору", got, []int{own[2], own[1], own[0]})
+
+	page, err := db.Posts(context.Background(), storage.PostsQuery{Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, "смещение 1", page.Posts, []int{own[1]})
+	if page.NextCursor == "" {
+		t.Error("после второй записи есть ещё одна, ожидался NextCursor")
+	}
+}
+
+// addAuthor добавляет автора и возвращает его вместе с выданным ID.
+func addAuthor(t *testing.T, db storage.Interface, name string) storage.Author {
+	t.Helper()
+	ctx := context.Background()
+	a := storage.Author{Name: name, AvatarURL: "/static/avatars/av_" + name + ".png"}
+	id, err := db.AddAuthor(ctx, a)
+	if err != nil {
+		t.Fatalf("AddAuthor(%q): %v", name, err)
+	}
+	a.ID = id
+	got, err := db.GetAuthorByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetAuthorByID(%d): %v", id, err)
+	}
+	if got != a {
+		t.Fatalf("после добавления автор = %+v, ожидался %+v", got, a)
+	}
+	return a
+}
+
+// addAccount создаёт автора с учётной записью.
+func addAccount(t *testing.T, db storage.Interface, login string) storage.Account {
+	t.Helper()
+	acc := storage.Account{
+		AuthorID:     addAuthor(t, db, login).ID,
+		Login:        login,
+		PasswordHash: "hash-" + login,
+		Role:         rbac.Author,
+	}
+	if err := db.AddAccount(context.Background(), acc); err != nil {
+		t.Fatalf("AddAccount(%q): %v", login, err)
+	}
+	return acc
+}
+
+// addPost добавляет публикацию и возвращает её ID.
+func addPost(t *testing.T, db storage.Interface, p storage.Post) int {
+	t.Helper()
+	id, err := db.AddPost(context.Background(), p)
+	if err != nil {
+		t.Fatalf("AddPost(%q): %v", p.Title, err)
+	}
+	if id == 0 {
+		t.Fatalf("AddPost(%q) вернул нулевой ID", p.Title)
+	}
+	return id
+}
+
+// allPosts обходит все страницы выборки по курсору.
+func allPosts(t *testing.T, db storage.Interface, q storage.PostsQuery) []storage.Post {
+	t.Helper()
+	var posts []storage.Post
+	for i := 0; ; i++ {
+		if i > 1000 {
+			t.Fatal("слишком много страниц: курсор не продвигается")
+		}
+		page, err := db.Posts(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Posts(%+v): %v", q, err)
+		}
+		posts = append(posts, page.Posts...)
+		if page.NextCursor == "" {
+			return posts
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// getPost получает публикацию по ID и проверяет, что она совпадает
+// с той же публикацией из общей выборки.
+func getPost(t *testing.T, db storage.Interface, id int) (storage.Post, bool) {
+	t.Helper()
+	p, err := db.Post(context.Background(), id)
+	if err != nil {
+		t.Errorf("Post(%d): %v", id, err)
+		return storage.Post{}, false
+	}
+	for _, got := range allPosts(t, db, storage.PostsQuery{Limit: storage.MaxLimit}) {
+		if got.ID == id {
+			if got != p {
+				t.Errorf("Post(%d) = %+v, в выборке %+v", id, p, got)
+			}
+			return p, true
+		}
+	}
+	t.Errorf("публикация %d не найдена в выборке", id)
+	return storage.Post{}, false
+}
+
+func ids(posts []storage.Post) []int {
+	res := make([]int, len(posts))
+	for i, p := range posts {
+		res[i] = p.ID
+	}
+	return res
+}
+
+func checkIDs(t *testing.T, what string, posts []storage.Post, want []int) {
+	t.Helper()
+	got := ids(posts)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s: ID = %v, ожидалось %v", what, got, want)
+	}
+}
+
+func testAuthors(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	if a.ID == 0 || b.ID == 0 || a.ID == b.ID {
+		t.Fatalf("ID авторов не уникальны: %d, %d", a.ID, b.ID)
+	}
+
+	authors, err := db.GetAuthors(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authors) != 2 || authors[0] != a || authors[1] != b {
+		t.Errorf("GetAuthors = %+v, ожидались %+v и %+v по порядку добавления", authors, a, b)
+	}
+
+	got, err := db.GetAuthorByID(ctx, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != b {
+		t.Errorf("GetAuthorByID(%d) = %+v, ожидался %+v", b.ID, got, b)
+	}
+}
+
+func testAuthorNotFound(t *testing.T, db storage.Interface) {
+	_, err := db.GetAuthorByID(context.Background(), 4242)
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetAuthorByID для несуществующего автора: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testUpdateAuthor(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	id := addPost(t, db, storage.Post{Title: "t", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+
+	a.Name = "alice2"
+	a.AvatarURL = "/static/avatars/new.png"
+	if err := db.UpdateAuthor(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetAuthorByID(ctx, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != a {
+		t.Errorf("после обновления автор = %+v, ожидался %+v", got, a)
+	}
+	if p, _ := getPost(t, db, id); p.Author != a {
+		t.Errorf("автор публикации = %+v, ожидался %+v", p.Author, a)
+	}
+}
+
+func testUpdateAuthorNotFound(t *testing.T, db storage.Interface) {
+	err := db.UpdateAuthor(context.Background(), storage.Author{ID: 4242, Name: "nobody"})
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("UpdateAuthor для несуществующего автора: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testDeleteAuthorRestrict(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	id := addPost(t, db, storage.Post{Title: "t", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+
+	err := db.DeleteAuthor(ctx, a.ID, storage.DeleteAuthorOptions{Posts: storage.RestrictPosts})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("удаление автора с публикациями: %v, ожидалась storage.ErrConflict", err)
+	}
+	if _, ok := getPost(t, db, id); !ok {
+		t.Error("публикация пропала после отказа в удалении автора")
+	}
+
+	// автора без публикаций удалить можно
+	if err := db.DeleteAuthor(ctx, b.ID, storage.DeleteAuthorOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetAuthorByID(ctx, b.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetAuthorByID после удаления: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testDeleteAuthorCascade(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	seed(t, db, a.ID, 1, 2)
+	keep := seed(t, db, b.ID, 3)
+
+	if err := db.DeleteAuthor(ctx, a.ID, storage.DeleteAuthorOptions{Posts: storage.CascadePosts}); err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, "после каскадного удаления", allPosts(t, db, storage.PostsQuery{}), keep)
+}
+
+func testDeleteAuthorReassign(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	posts := seed(t, db, a.ID, 1, 2)
+
+	for _, to := range []int{a.ID, 4242} {
+		err := db.DeleteAuthor(ctx, a.ID, storage.DeleteAuthorOptions{Posts: storage.ReassignPosts, ReassignTo: to})
+		if !errors.Is(err, storage.ErrInvalid) {
+			t.Errorf("передача публикаций автору %d: %v, ожидалась storage.ErrInvalid", to, err)
+		}
+	}
+
+	err := db.DeleteAuthor(ctx, a.ID, storage.DeleteAuthorOptions{Posts: storage.ReassignPosts, ReassignTo: b.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range posts {
+		if p, _ := getPost(t, db, id); p.AuthorID != b.ID || p.Author != b {
+			t.Errorf("публикация %d после передачи: автор %+v, ожидался %+v", id, p.Author, b)
+		}
+	}
+}
+
+func testDeleteAuthorNotFound(t *testing.T, db storage.Interface) {
+	err := db.DeleteAuthor(context.Background(), 4242, storage.DeleteAuthorOptions{Posts: storage.CascadePosts})
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("DeleteAuthor для несуществующего автора: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testAccounts(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAccount(t, db, "alice")
+	addAuthor(t, db, "guest") // автор без учётной записи
+	b := storage.Account{AuthorID: addAuthor(t, db, "bob").ID, Login: "bob", PasswordHash: "hash-bob", Role: rbac.Admin}
+	if err := db.AddAccount(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []storage.Account{a, b} {
+		got, err := db.AccountByLogin(ctx, want.Login)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("AccountByLogin(%q) = %+v, ожидалась %+v", want.Login, got, want)
+		}
+	}
+	got, err := db.AccountByAuthor(ctx, a.AuthorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != a {
+		t.Errorf("AccountByAuthor = %+v, ожидалась %+v", got, a)
+	}
+
+	all, err := db.Accounts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Login != "alice" || all[1].Login != "bob" {
+		t.Errorf("Accounts = %+v, ожидались alice и bob по порядку", all)
+	}
+}
+
+func testAccountConflict(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAccount(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+
+	// логин занят
+	err := db.AddAccount(ctx, storage.Account{AuthorID: b.ID, Login: "alice", PasswordHash: "x", Role: rbac.Author})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("AddAccount с занятым логином: %v, ожидалась storage.ErrConflict", err)
+	}
+	// у автора уже есть учётная запись
+	err = db.AddAccount(ctx, storage.Account{AuthorID: a.AuthorID, Login: "alice2", PasswordHash: "x", Role: rbac.Author})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("вторая учётная запись автора: %v, ожидалась storage.ErrConflict", err)
+	}
+}
+
+func testAccountUnknownAuthor(t *testing.T, db storage.Interface) {
+	err := db.AddAccount(context.Background(), storage.Account{AuthorID: 4242, Login: "ghost", PasswordHash: "x", Role: rbac.Author})
+	if !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("AddAccount для несуществующего автора: %v, ожидалась storage.ErrInvalid", err)
+	}
+}
+
+func testAccountNotFound(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	if _, err := db.AccountByLogin(ctx, "alice"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("AccountByLogin для несуществующего логина: %v, ожидалась storage.ErrNotFound", err)
+	}
+	if _, err := db.AccountByAuthor(ctx, a.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("AccountByAuthor для автора без учётной записи: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testDeleteAuthorAccount(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAccount(t, db, "alice")
+
+	if err := db.DeleteAuthor(ctx, a.AuthorID, storage.DeleteAuthorOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AccountByLogin(ctx, "alice"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("учётная запись осталась после удаления автора: %v", err)
+	}
+
+	// логин освобождается вместе с учётной записью
+	addAccount(t, db, "alice")
+}
+
+func testSetRole(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAccount(t, db, "alice")
+
+	if err := db.SetRole(ctx, a.AuthorID, rbac.Editor); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.AccountByLogin(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Role != rbac.Editor {
+		t.Errorf("роль после SetRole = %q, ожидалась %q", got.Role, rbac.Editor)
+	}
+	if got.SessionVersion == a.SessionVersion {
+		t.Errorf("SetRole не изменил версию сессий: %d", got.SessionVersion)
+	}
+
+	if err := db.SetRole(ctx, 4242, rbac.Editor); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("SetRole для несуществующей учётной записи: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testRevokeSessions(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAccount(t, db, "alice")
+	b := addAccount(t, db, "bob")
+
+	if err := db.RevokeSessions(ctx, a.AuthorID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.AccountByAuthor(ctx, a.AuthorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SessionVersion == a.SessionVersion {
+		t.Errorf("RevokeSessions не изменил версию сессий: %d", got.SessionVersion)
+	}
+	if got.Role != a.Role || got.PasswordHash != a.PasswordHash {
+		t.Errorf("RevokeSessions изменил учётную запись: %+v, было %+v", got, a)
+	}
+	other, err := db.AccountByAuthor(ctx, b.AuthorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.SessionVersion != b.SessionVersion {
+		t.Errorf("RevokeSessions изменил версию сессий другой учётной записи: %d", other.SessionVersion)
+	}
+
+	if err := db.RevokeSessions(ctx, 4242); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("RevokeSessions для несуществующей учётной записи: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testLastAdmin(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAccount(t, db, "alice")
+	b := addAccount(t, db, "bob")
+	postID := addPost(t, db, storage.Post{Title: "t", Content: "c", AuthorID: b.AuthorID, CreatedAt: 1700000000})
+	for _, acc := range []storage.Account{a, b} {
+		if err := db.SetRole(ctx, acc.AuthorID, rbac.Admin); err != nil {
+			t.Fatal(err)
+		}
+	}
+	account := func(id int) storage.Account {
+		t.Helper()
+		acc, err := db.AccountByAuthor(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return acc
+	}
+
+	// пока есть другой администратор, роль снимается
+	if err := db.SetRole(ctx, a.AuthorID, rbac.Editor); err != nil {
+		t.Fatalf("SetRole при двух администраторах: %v", err)
+	}
+
+	// отказ не меняет ни роль, ни версию сессий
+	before := account(b.AuthorID)
+	err := db.SetRole(ctx, b.AuthorID, rbac.Editor)
+	if !errors.Is(err, storage.ErrLastAdmin) || !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("SetRole последнего администратора: %v, ожидалась storage.ErrLastAdmin", err)
+	}
+	if got := account(b.AuthorID); got.Role != rbac.Admin || got.SessionVersion != before.SessionVersion {
+		t.Errorf("после отказа в SetRole роль %q, версия сессий %d, ожидались %q и %d", got.Role, got.SessionVersion, rbac.Admin, before.SessionVersion)
+	}
+	if err := db.SetRole(ctx, b.AuthorID, rbac.Admin); err != nil {
+		t.Errorf("SetRole admin для последнего администратора: %v", err)
+	}
+
+	before = account(b.AuthorID)
+	err = db.DeleteAuthor(ctx, b.AuthorID, storage.DeleteAuthorOptions{Posts: storage.CascadePosts})
+	if !errors.Is(err, storage.ErrLastAdmin) {
+		t.Errorf("DeleteAuthor последнего администратора: %v, ожидалась storage.ErrLastAdmin", err)
+	}
+	if got := account(b.AuthorID); got.Role != rbac.Admin || got.SessionVersion != before.SessionVersion {
+		t.Errorf("после отказа в удалении роль %q, версия сессий %d, ожидались %q и %d", got.Role, got.SessionVersion, rbac.Admin, before.SessionVersion)
+	}
+	getPost(t, db, postID) // публикации остаются
+
+	// автора, который не администратор, удалить можно
+	if err := db.DeleteAuthor(ctx, a.AuthorID, storage.DeleteAuthorOptions{}); err != nil {
+		t.Errorf("DeleteAuthor не администратора: %v", err)
+	}
+
+	// с новым администратором прежнего можно удалить
+	c := addAccount(t, db, "carol")
+	if err := db.SetRole(ctx, c.AuthorID, rbac.Admin); err != nil {
+		t.Fatal(err)
+	}
+	// но не вместе с публикациями в режиме restrict: роль и сессии остаются
+	err = db.DeleteAuthor(ctx, b.AuthorID, storage.DeleteAuthorOptions{Posts: storage.RestrictPosts})
+	if !errors.Is(err, storage.ErrConflict) || errors.Is(err, storage.ErrLastAdmin) {
+		t.Errorf("DeleteAuthor restrict администратора с публикациями: %v, ожидалась storage.ErrConflict", err)
+	}
+	if got := account(b.AuthorID); got.Role != rbac.Admin || got.SessionVersion != before.SessionVersion {
+		t.Errorf("после отказа в удалении с публикациями роль %q, версия сессий %d, ожидались %q и %d", got.Role, got.SessionVersion, rbac.Admin, before.SessionVersion)
+	}
+	if err := db.DeleteAuthor(ctx, b.AuthorID, storage.DeleteAuthorOptions{Posts: storage.CascadePosts}); err != nil {
+		t.Errorf("DeleteAuthor администратора при втором администраторе: %v", err)
+	}
+}
+
+func testInvalidRole(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAccount(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+
+	err := db.AddAccount(ctx, storage.Account{AuthorID: b.ID, Login: "bob", PasswordHash: "x", Role: "root"})
+	if !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("AddAccount с неизвестной ролью: %v, ожидалась storage.ErrInvalid", err)
+	}
+	if err := db.SetRole(ctx, a.AuthorID, "root"); !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("SetRole с неизвестной ролью: %v, ожидалась storage.ErrInvalid", err)
+	}
+}
+
+// addToken добавляет API-токен и возвращает его с выданным ID.
+func addToken(t *testing.T, db storage.Interface, authorID int, hash string, scopes ...string) storage.Token {
+	t.Helper()
+	tok := storage.Token{AuthorID: authorID, Name: "token " + hash, Hash: hash, Scopes: scopes, CreatedAt: 1700000000, ExpiresAt: 1800000000}
+	id, err := db.AddToken(context.Background(), tok)
+	if err != nil {
+		t.Fatalf("AddToken(%q): %v", hash, err)
+	}
+	if id == 0 {
+		t.Fatalf("AddToken(%q) вернул нулевой ID", hash)
+	}
+	tok.ID = id
+	return tok
+}
+
+// Токены сравниваются по строковому представлению: пустой и nil
+// список областей действия считаются одинаковыми.
+func sameToken(a, b storage.Token) bool {
+	return fmt.Sprintf("%+v", a) == fmt.Sprintf("%+v", b)
+}
+
+func testTokens(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	t1 := addToken(t, db, a.ID, "h1", "posts:write", "authors:admin")
+	addToken(t, db, b.ID, "h2")
+	t3 := addToken(t, db, a.ID, "h3")
+
+	got, err := db.TokenByHash(ctx, "h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameToken(got, t1) {
+		t.Errorf("TokenByHash = %+v, ожидался %+v", got, t1)
+	}
+	if _, err := db.TokenByHash(ctx, "nope"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("TokenByHash для неизвестного хеша: %v, ожидалась storage.ErrNotFound", err)
+	}
+
+	tokens, err := db.Tokens(ctx, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || !sameToken(tokens[0], t1) || !sameToken(tokens[1], t3) {
+		t.Errorf("Tokens(%d) = %+v, ожидались %+v и %+v", a.ID, tokens, t1, t3)
+	}
+}
+
+func testTokenConflict(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	addToken(t, db, a.ID, "h1")
+	_, err := db.AddToken(context.Background(), storage.Token{AuthorID: a.ID, Name: "dup", Hash: "h1"})
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("AddToken с повторным хешем: %v, ожидалась storage.ErrConflict", err)
+	}
+}
+
+func testTokenUnknownAuthor(t *testing.T, db storage.Interface) {
+	_, err := db.AddToken(context.Background(), storage.Token{AuthorID: 4242, Name: "ghost", Hash: "h1"})
+	if !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("AddToken для несуществующего автора: %v, ожидалась storage.ErrInvalid", err)
+	}
+}
+
+func testDeleteToken(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	tok := addToken(t, db, a.ID, "h1")
+
+	// чужой токен отозвать нельзя
+	if err := db.DeleteToken(ctx, b.ID, tok.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("DeleteToken чужого токена: %v, ожидалась storage.ErrNotFound", err)
+	}
+	if err := db.DeleteToken(ctx, a.ID, tok.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.TokenByHash(ctx, "h1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("TokenByHash после отзыва: %v, ожидалась storage.ErrNotFound", err)
+	}
+	if err := db.DeleteToken(ctx, a.ID, tok.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("повторный DeleteToken: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testDeleteAuthorTokens(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	addToken(t, db, a.ID, "h1")
+
+	if err := db.DeleteAuthor(ctx, a.ID, storage.DeleteAuthorOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.TokenByHash(ctx, "h1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("токен остался после удаления автора: %v", err)
+	}
+}
+
+func testAddPost(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	id := addPost(t, db, storage.Post{Title: "Заголовок", Content: "Текст", AuthorID: a.ID, CreatedAt: 1700000000})
+
+	p, ok := getPost(t, db, id)
+	if !ok {
+		t.Fatalf("публикация %d не найдена", id)
+	}
+	want := storage.Post{
+		ID:            id,
+		Title:         "Заголовок",
+		Content:       "Текст",
+		AuthorID:      a.ID,
+		Author:        a,
+		CreatedAt:     1700000000,
+		FormattedDate: storage.FormatDate(1700000000),
+	}
+	if p != want {
+		t.Errorf("публикация = %+v, ожидалась %+v", p, want)
+	}
+}
+
+func testAddPostDefaultDate(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	id := addPost(t, db, storage.Post{Title: "Без даты", Content: "Текст", AuthorID: a.ID})
+
+	p, _ := getPost(t, db, id)
+	if p.CreatedAt == 0 {
+		t.Error("при нулевом CreatedAt должна проставляться текущая дата")
+	}
+	if p.FormattedDate != storage.FormatDate(p.CreatedAt) {
+		t.Errorf("FormattedDate = %q, ожидалось %q", p.FormattedDate, storage.FormatDate(p.CreatedAt))
+	}
+}
+
+func testAddPostUnknownAuthor(t *testing.T, db storage.Interface) {
+	_, err := db.AddPost(context.Background(), storage.Post{Title: "t", Content: "c", AuthorID: 4242})
+	if !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("AddPost с несуществующим автором: %v, ожидалась storage.ErrInvalid", err)
+	}
+}
+
+func testPostNotFound(t *testing.T, db storage.Interface) {
+	_, err := db.Post(context.Background(), 4242)
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Post для несуществующей публикации: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testUpdatePost(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	id := addPost(t, db, storage.Post{Title: "old", Content: "old", AuthorID: a.ID, CreatedAt: 100})
+
+	// Дата создания не меняется, даже если передана другая
+	err := db.UpdatePost(context.Background(), storage.Post{ID: id, Title: "new", Content: "new", AuthorID: b.ID, CreatedAt: 200, UpdatedAt: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := getPost(t, db, id)
+	if p.Title != "new" || p.Content != "new" || p.AuthorID != b.ID || p.Author != b || p.CreatedAt != 100 || p.UpdatedAt != 300 {
+		t.Errorf("после обновления публикация = %+v", p)
+	}
+}
+
+func testUpdatePostDefaultDate(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	id := addPost(t, db, storage.Post{Title: "t", Content: "c", AuthorID: a.ID, CreatedAt: 100})
+	if p, _ := getPost(t, db, id); p.UpdatedAt != 0 || p.FormattedUpdatedAt() != "" {
+		t.Errorf("у новой публикации UpdatedAt = %d", p.UpdatedAt)
+	}
+
+	if err := db.UpdatePost(context.Background(), storage.Post{ID: id, Title: "t2", Content: "c", AuthorID: a.ID}); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := getPost(t, db, id)
+	if p.UpdatedAt == 0 {
+		t.Error("при нулевом UpdatedAt должна проставляться текущая дата")
+	}
+	if p.CreatedAt != 100 {
+		t.Errorf("CreatedAt = %d, ожидалось 100", p.CreatedAt)
+	}
+}
+
+func testUpdatePostNotFound(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	err := db.UpdatePost(context.Background(), storage.Post{ID: 4242, Title: "t", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("UpdatePost для несуществующей публикации: %v, ожидалась storage.ErrNotFound", err)
+	}
+	// Публикация проверяется раньше автора
+	err = db.UpdatePost(context.Background(), storage.Post{ID: 4242, Title: "t", Content: "c", AuthorID: 4243, CreatedAt: 1})
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("UpdatePost для несуществующей публикации с несуществующим автором: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testDeletePost(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	keep := addPost(t, db, storage.Post{Title: "keep", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+	del := addPost(t, db, storage.Post{Title: "delete", Content: "c", AuthorID: a.ID, CreatedAt: 2})
+
+	if err := db.DeletePost(context.Background(), storage.Post{ID: del}); err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, "после удаления", allPosts(t, db, storage.PostsQuery{}), []int{keep})
+	if _, err := db.Post(context.Background(), del); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Post после удаления: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testDeletePostNotFound(t *testing.T, db storage.Interface) {
+	err := db.DeletePost(context.Background(), storage.Post{ID: 4242})
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("DeletePost для несуществующей публикации: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+// addAttachment добавляет вложение к публикации и возвращает его с выданным ID.
+func addAttachment(t *testing.T, db storage.Interface, postID int, name string) storage.Attachment {
+	t.Helper()
+	att := storage.Attachment{
+		PostID:       postID,
+		Name:         name,
+		ContentType:  "image/png",
+		Size:         1234,
+		URL:          "/static/posts/" + name,
+		ThumbnailURL: "/static/posts/thumb_" + name,
+		CreatedAt:    1700000000,
+	}
+	id, err := db.AddAttachment(context.Background(), att)
+	if err != nil {
+		t.Fatalf("AddAttachment(%q): %v", name, err)
+	}
+	if id == 0 {
+		t.Fatalf("AddAttachment(%q) вернул нулевой ID", name)
+	}
+	att.ID = id
+	return att
+}
+
+// attachments возвращает вложения публикаций.
+func attachments(t *testing.T, db storage.Interface, postIDs ...int) []storage.Attachment {
+	t.Helper()
+	res, err := db.Attachments(context.Background(), postIDs...)
+	if err != nil {
+		t.Fatalf("Attachments(%v): %v", postIDs, err)
+	}
+	return res
+}
+
+func testAttachments(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	p1 := addPost(t, db, storage.Post{Title: "one", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+	p2 := addPost(t, db, storage.Post{Title: "two", Content: "c", AuthorID: a.ID, CreatedAt: 2})
+	p3 := addPost(t, db, storage.Post{Title: "three", Content: "c", AuthorID: a.ID, CreatedAt: 3})
+	a1 := addAttachment(t, db, p1, "a.png")
+	a2 := addAttachment(t, db, p2, "b.png")
+	a3 := addAttachment(t, db, p1, "c.pdf")
+
+	got := attachments(t, db, p1)
+	if len(got) != 2 || got[0] != a1 || got[1] != a3 {
+		t.Errorf("Attachments(%d) = %+v, ожидались %+v и %+v", p1, got, a1, a3)
+	}
+	got = attachments(t, db, p2, p1, p3)
+	if len(got) != 3 || got[0] != a1 || got[1] != a2 || got[2] != a3 {
+		t.Errorf("Attachments(%d, %d, %d) = %+v", p2, p1, p3, got)
+	}
+	if got := attachments(t, db, p3); len(got) != 0 {
+		t.Errorf("Attachments(%d) без вложений = %+v", p3, got)
+	}
+	if got := attachments(t, db); len(got) != 0 {
+		t.Errorf("Attachments() без публикаций = %+v", got)
+	}
+}
+
+func testAttachmentUnknownPost(t *testing.T, db storage.Interface) {
+	_, err := db.AddAttachment(context.Background(), storage.Attachment{PostID: 4242, Name: "a.png", URL: "/a.png"})
+	if !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("AddAttachment к несуществующей публикации: %v, ожидалась storage.ErrInvalid", err)
+	}
+}
+
+func testDeleteAttachment(t *testing.T, db storage.Interface) {
+	ctx := context.Background()
+	a := addAuthor(t, db, "alice")
+	p1 := addPost(t, db, storage.Post{Title: "one", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+	p2 := addPost(t, db, storage.Post{Title: "two", Content: "c", AuthorID: a.ID, CreatedAt: 2})
+	del := addAttachment(t, db, p1, "a.png")
+	keep := addAttachment(t, db, p1, "b.png")
+
+	// вложение чужой публикации удалить нельзя
+	if err := db.DeleteAttachment(ctx, p2, del.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("DeleteAttachment чужого вложения: %v, ожидалась storage.ErrNotFound", err)
+	}
+	if err := db.DeleteAttachment(ctx, p1, del.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := attachments(t, db, p1); len(got) != 1 || got[0] != keep {
+		t.Errorf("после удаления вложения = %+v, ожидалось %+v", got, keep)
+	}
+	if err := db.DeleteAttachment(ctx, p1, del.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("повторный DeleteAttachment: %v, ожидалась storage.ErrNotFound", err)
+	}
+}
+
+func testDeletePostAttachments(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	del := addPost(t, db, storage.Post{Title: "delete", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+	keep := addPost(t, db, storage.Post{Title: "keep", Content: "c", AuthorID: a.ID, CreatedAt: 2})
+	addAttachment(t, db, del, "a.png")
+	kept := addAttachment(t, db, keep, "b.png")
+
+	if err := db.DeletePost(context.Background(), storage.Post{ID: del}); err != nil {
+		t.Fatal(err)
+	}
+	if got := attachments(t, db, del, keep); len(got) != 1 || got[0] != kept {
+		t.Errorf("после удаления публикации вложения = %+v, ожидалось %+v", got, kept)
+	}
+}
+
+func testDeleteAuthorAttachments(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	pa := addPost(t, db, storage.Post{Title: "alice", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+	pb := addPost(t, db, storage.Post{Title: "bob", Content: "c", AuthorID: b.ID, CreatedAt: 2})
+	addAttachment(t, db, pa, "a.png")
+	kept := addAttachment(t, db, pb, "b.png")
+
+	if err := db.DeleteAuthor(context.Background(), a.ID, storage.DeleteAuthorOptions{Posts: storage.CascadePosts}); err != nil {
+		t.Fatal(err)
+	}
+	if got := attachments(t, db, pa, pb); len(got) != 1 || got[0] != kept {
+		t.Errorf("после удаления автора вложения = %+v, ожидалось %+v", got, kept)
+	}
+}
+
+// revisions возвращает версии публикации.
+func revisions(t *testing.T, db storage.Interface, postID int) []storage.Revision {
+	t.Helper()
+	res, err := db.Revisions(context.Background(), postID)
+	if err != nil {
+		t.Fatalf("Revisions(%d): %v", postID, err)
+	}
+	return res
+}
+
+func testRevisions(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	id := addPost(t, db, storage.Post{Title: "v1", Content: "c1", AuthorID: a.ID, CreatedAt: 100})
+
+	// Правка другим автором, затем правка без автора изменения и даты
+	err := db.UpdatePost(context.Background(), storage.Post{ID: id, Title: "v2", Content: "c2", AuthorID: a.ID, UpdatedAt: 200, UpdatedBy: b.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := getPost(t, db, id); p.UpdatedBy != b.ID {
+		t.Errorf("UpdatedBy = %d, ожидалось %d", p.UpdatedBy, b.ID)
+	}
+	if err := db.UpdatePost(context.Background(), storage.Post{ID: id, Title: "v3", Content: "c3", AuthorID: a.ID}); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := getPost(t, db, id)
+	if p.UpdatedBy != a.ID {
+		t.Errorf("при нулевом UpdatedBy = %d, ожидался автор публикации %d", p.UpdatedBy, a.ID)
+	}
+
+	got := revisions(t, db, id)
+	if len(got) != 3 {
+		t.Fatalf("версий: %d, ожидалось 3: %+v", len(got), got)
+	}
+	want := []storage.Revision{
+		{ID: got[0].ID, PostID: id, AuthorID: a.ID, Title: "v1", Content: "c1", CreatedAt: 100},
+		{ID: got[1].ID, PostID: id, AuthorID: b.ID, Title: "v2", Content: "c2", CreatedAt: 200},
+		{ID: got[2].ID, PostID: id, AuthorID: a.ID, Title: "v3", Content: "c3", CreatedAt: p.UpdatedAt},
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("версия %d = %+v, ожидалось %+v", i, got[i], want[i])
+		}
+	}
+	if !(got[0].ID < got[1].ID && got[1].ID < got[2].ID) {
+		t.Errorf("версии не в порядке создания: %+v", got)
+	}
+
+	rev, err := db.Revision(context.Background(), id, got[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != got[1] {
+		t.Errorf("Revision = %+v, ожидалось %+v", rev, got[1])
+	}
+}
+
+func testRevisionNotFound(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	p1 := addPost(t, db, storage.Post{Title: "t", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+	p2 := addPost(t, db, storage.Post{Title: "t", Content: "c", AuthorID: a.ID, CreatedAt: 2})
+	rev := revisions(t, db, p1)[0]
+
+	if _, err := db.Revision(context.Background(), p2, rev.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Revision чужой публикации: %v, ожидалась storage.ErrNotFound", err)
+	}
+	if _, err := db.Revision(context.Background(), p1, 4242); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Revision несуществующей версии: %v, ожидалась storage.ErrNotFound", err)
+	}
+	if got := revisions(t, db, 4242); len(got) != 0 {
+		t.Errorf("версии несуществующей публикации: %+v", got)
+	}
+}
+
+func testDeletePostRevisions(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	del := addPost(t, db, storage.Post{Title: "delete", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+	keep := addPost(t, db, storage.Post{Title: "keep", Content: "c", AuthorID: a.ID, CreatedAt: 2})
+	rev := revisions(t, db, del)[0]
+
+	if err := db.DeletePost(context.Background(), storage.Post{ID: del}); err != nil {
+		t.Fatal(err)
+	}
+	if got := revisions(t, db, del); len(got) != 0 {
+		t.Errorf("после удаления публикации версии = %+v", got)
+	}
+	if _, err := db.Revision(context.Background(), del, rev.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Revision после удаления публикации: %v, ожидалась storage.ErrNotFound", err)
+	}
+	if got := revisions(t, db, keep); len(got) != 1 {
+		t.Errorf("версии оставшейся публикации = %+v", got)
+	}
+}
+
+func testDeleteAuthorRevisions(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	pa := addPost(t, db, storage.Post{Title: "alice", Content: "c", AuthorID: a.ID, CreatedAt: 1})
+	pb := addPost(t, db, storage.Post{Title: "bob", Content: "c", AuthorID: b.ID, CreatedAt: 2})
+	// Правка alice в публикации bob остаётся в истории после удаления alice
+	err := db.UpdatePost(context.Background(), storage.Post{ID: pb, Title: "bob", Content: "c2", AuthorID: b.ID, UpdatedBy: a.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.DeleteAuthor(context.Background(), a.ID, storage.DeleteAuthorOptions{Posts: storage.CascadePosts}); err != nil {
+		t.Fatal(err)
+	}
+	if got := revisions(t, db, pa); len(got) != 0 {
+		t.Errorf("после удаления автора версии его публикации = %+v", got)
+	}
+	if got := revisions(t, db, pb); len(got) != 2 || got[1].AuthorID != a.ID {
+		t.Errorf("после удаления автора версии чужой публикации = %+v", got)
+	}
+}
+
+// seed добавляет публикации с заданными датами и возвращает их ID в том же порядке.
+func seed(t *testing.T, db storage.Interface, authorID int, dates ...int64) []int {
+	t.Helper()
+	res := make([]int, len(dates))
+	for i, d := range dates {
+		res[i] = addPost(t, db, storage.Post{
+			Title:     fmt.Sprintf("post-%d-%d", authorID, i),
+			Content:   "c",
+			AuthorID:  authorID,
+			CreatedAt: d,
+		})
+	}
+	return res
+}
+
+func testOrdering(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	// две публикации с одинаковой датой упорядочиваются по ID
+	id := seed(t, db, a.ID, 300, 100, 200, 200)
+
+	checkIDs(t, "сначала новые", allPosts(t, db, storage.PostsQuery{}), []int{id[0], id[3], id[2], id[1]})
+	checkIDs(t, "сначала старые", allPosts(t, db, storage.PostsQuery{Sort: storage.OldestFirst}), []int{id[1], id[2], id[3], id[0]})
+}
+
+func testCursorPagination(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	id := seed(t, db, a.ID, 1, 2, 2, 3, 4, 5, 5)
+	want := []int{id[6], id[5], id[4], id[3], id[2], id[1], id[0]}
+
+	var pages [][]int
+	q := storage.PostsQuery{Limit: 3}
+	var got []storage.Post
+	for {
+		page, err := db.Posts(context.Background(), q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, ids(page.Posts))
+		got = append(got, page.Posts...)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if len(pages) != 3 {
+		t.Errorf("страницы = %v, ожидалось 3 страницы по 3, 3 и 1 записи", pages)
+	}
+	checkIDs(t, "по курсору", got, want)
+
+	// на последней полной странице курсора быть не должно
+	page, err := db.Posts(context.Background(), storage.PostsQuery{Limit: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor != "" {
+		t.Errorf("NextCursor = %q на последней странице", page.NextCursor)
+	}
+}
+
+func testOffsetPagination(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	id := seed(t, db, a.ID, 1, 2, 3, 4, 5)
+
+	page, err := db.Posts(context.Background(), storage.PostsQuery{Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, "смещение 2", page.Posts, []int{id[2], id[1]})
+	if page.NextCursor == "" {
+		t.Error("после второй страницы есть ещё записи, ожидался NextCursor")
+	}
+
+	page, err = db.Posts(context.Background(), storage.PostsQuery{Offset: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Posts) != 0 || page.NextCursor != "" {
+		t.Errorf("смещение за концом выборки: %+v", page)
+	}
+}
+
+func testFilters(t *testing.T, db storage.Interface) {
+	a := addAuthor(t, db, "alice")
+	b := addAuthor(t, db, "bob")
+	ia := seed(t, db, a.ID, 10, 20, 30)
+	ib := seed(t, db, b.ID, 15, 25)
+
+	checkIDs(t, "по автору", allPosts(t, db, storage.PostsQuery{AuthorID: b.ID}), []int{ib[1], ib[0]})
+	checkIDs(t, "по датам", allPosts(t, db, storage.PostsQuery{From: 15, To: 30}), []int{ib[1], ia[1], ib[0]})
+	checkIDs(t, "по автору и датам", allPosts(t, db, storage.PostsQuery{AuthorID: a.ID, From: 20}), []int{ia[2], ia[1]})
+}
+
+func testBadQuery(t *testing.T, db storage.Interface) {
+	for _, q := range []storage.PostsQuery{
+		{Cursor: "не курсор"},
+		{Offset: -1},
+		{From: 20, To: 10},
+	} {
+		if _, err := db.Posts(context.Background(), q); !errors.Is(err, storage.ErrInvalid) {
+			t.Errorf("Posts(%+v): %v, ожидалась storage.ErrInvalid", q, err)
+		}
+	}
+}
+
+func testCanceledContext(t *testing.T, db storage.Interface) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.Posts(ctx, storage.PostsQuery{}); err == nil {
+		t.Error("Posts с отменённым контекстом должен вернуть ошибку")
+	}
+	if _, err := db.AddAuthor(ctx, storage.Author{Name: "alice"}); err == nil {
+		t.Error("AddAuthor с отменённым контекстом должен вернуть ошибку")
+	}
+	if _, err := db.GetAuthors(ctx); err == nil {
+		t.Error("GetAuthors с отменённым контекстом должен вернуть ошибку")
+	}
+}
@@ -0,0 +1,140 @@
+// Package validate проверяет входные данные по декларативным правилам
+// и сообщает об ошибках отдельно по каждому полю.
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Errors - ошибки проверки: имя поля и сообщение о первом нарушенном правиле.
+type Errors map[string]string
+
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for f := range e {
+		fields = append(fields, f)
+	}
+	slices.Sort(fields)
+	msgs := make([]string, 0, len(e))
+	for _, f := range fields {
+		msgs = append(msgs, f+": "+e[f])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Rule проверяет значение поля и возвращает сообщение об ошибке
+// или пустую строку, если значение подходит.
+type Rule func(s string) string
+
+// Field - значение поля и правила, которым оно должно соответствовать.
+type Field struct {
+	Name  string
+	Value string
+	Rules []Rule
+}
+
+// F описывает поле name со значением value.
+func F(name, value string, rules ...Rule) Field {
+	return Field{Name: name, Value: value, Rules: rules}
+}
+
+// Check проверяет поля по порядку правил. Для каждого поля сообщается
+// только первое нарушенное правило. Возвращает Errors или nil.
+func Check(fields ...Field) error {
+	errs := Errors{}
+	for _, f := range fields {
+		for _, rule := range f.Rules {
+			if msg := rule(f.Value); msg != "" {
+				errs[f.Name] = msg
+				break
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Required - значение не пустое и не состоит из одних пробелов.
+func Required() Rule {
+	return func(s string) string {
+		if strings.TrimSpace(s) == "" {
+			return "обязательное поле"
+		}
+		return ""
+	}
+}
+
+// MinLen - не меньше n символов.
+func MinLen(n int) Rule {
+	return func(s string) string {
+		if utf8.RuneCountInString(s) < n {
+			return fmt.Sprintf("не короче %d символов", n)
+		}
+		return ""
+	}
+}
+
+// MaxLen - не больше n символов.
+func MaxLen(n int) Rule {
+	return func(s string) string {
+		if utf8.RuneCountInString(s) > n {
+			return fmt.Sprintf("не длиннее %d символов", n)
+		}
+		return ""
+	}
+}
+
+// MaxBytes - не больше n байт.
+func MaxBytes(n int) Rule {
+	return func(s string) string {
+		if len(s) > n {
+			return fmt.Sprintf("не длиннее %d байт", n)
+		}
+		return ""
+	}
+}
+
+// Match - значение соответствует регулярному выражению, иначе сообщение msg.
+func Match(re *regexp.Regexp, msg string) Rule {
+	return func(s string) string {
+		if !re.MatchString(s) {
+			return msg
+		}
+		return ""
+	}
+}
+
+// SingleLine - корректный UTF-8 в одну строку, без управляющих символов.
+func SingleLine() Rule {
+	return func(s string) string {
+		if !utf8.ValidString(s) {
+			return "недопустимая кодировка"
+		}
+		if strings.ContainsFunc(s, unicode.IsControl) {
+			return "недопустимые символы"
+		}
+		return ""
+	}
+}
+
+// Text - корректный UTF-8 без управляющих символов, кроме переводов строк и табуляции.
+func Text() Rule {
+	return func(s string) string {
+		if !utf8.ValidString(s) {
+			return "недопустимая кодировка"
+		}
+		if strings.ContainsFunc(s, func(r rune) bool {
+			return unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t'
+		}) {
+			return "недопустимые символы"
+		}
+		return ""
+	}
+}
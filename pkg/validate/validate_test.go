@@ -0,0 +1,67 @@
+package validate
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	digits := Match(regexp.MustCompile(`^[0-9]+$`), "только цифры")
+	tests := []struct {
+		rule  Rule
+		value string
+		ok    bool
+	}{
+		{Required(), "x", true},
+		{Required(), "", false},
+		{Required(), " \t\n", false},
+		{MinLen(3), "абв", true},
+		{MinLen(3), "аб", false},
+		{MaxLen(3), "абв", true},
+		{MaxLen(3), "абвг", false},
+		{MaxBytes(3), "абв", false},
+		{MaxBytes(6), "абв", true},
+		{digits, "123", true},
+		{digits, "12a", false},
+		{SingleLine(), "Заголовок", true},
+		{SingleLine(), "две\nстроки", false},
+		{SingleLine(), "\xff", false},
+		{Text(), "абзац\n\tещё\r\n", true},
+		{Text(), "звонок\a", false},
+	}
+	for i, tt := range tests {
+		if msg := tt.rule(tt.value); (msg == "") != tt.ok {
+			t.Errorf("%d: правило для %q вернуло %q, ожидалось ok=%v", i, tt.value, msg, tt.ok)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check(F("name", "Иван", Required(), MaxLen(10))); err != nil {
+		t.Fatalf("Check() = %v, ожидалось nil", err)
+	}
+
+	err := Check(
+		F("title", "", Required(), MaxLen(1)),
+		F("content", "слишком длинно", Required(), MaxLen(5)),
+		F("name", "ok", Required()),
+	)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Check() = %v, ожидались Errors", err)
+	}
+	want := Errors{"title": "обязательное поле", "content": "не длиннее 5 символов"}
+	if len(errs) != len(want) {
+		t.Fatalf("Check() = %v, ожидалось %v", errs, want)
+	}
+	for f, msg := range want {
+		if errs[f] != msg {
+			t.Errorf("поле %s: %q, ожидалось %q", f, errs[f], msg)
+		}
+	}
+	if got := errs.Error(); !strings.HasPrefix(got, "content: ") {
+		t.Errorf("Error() = %q: поля должны идти по алфавиту", got)
+	}
+}
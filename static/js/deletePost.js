@@ -1,5 +1,11 @@
 let postToDelete = null; 
 
+// CSRF-токен страницы: сервер отклоняет изменяющие запросы без него
+function csrfToken() {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    return meta ? meta.content : "";
+}
+
 function confirmDelete(postID) {
     postToDelete = postID;
     document.getElementById("deleteModal").style.display = "flex";
@@ -16,11 +22,20 @@ function deletePost() {
 
     fetch(`/posts/${postToDelete}`, {
         method: "DELETE",
+        credentials: "same-origin",
+        headers: { "X-CSRF-Token": csrfToken() },
     })
     .then(response => {
         if (response.ok) {
             document.getElementById(`post-${postToDelete}`).remove();
             closeModal();
+        } else if (response.status === 401) {
+            window.location.href = "/login?next=" + encodeURIComponent(window.location.pathname);
+        } else if (response.status === 403) {
+            response.json()
+                .then(body => alert(body.error))
+                .catch(() => alert("Недостаточно прав для удаления поста"));
+            closeModal();
         } else {
             alert("Ошибка при удалении поста");
         }
@@ -0,0 +1,49 @@
+// Предварительный просмотр текста публикации в Markdown
+const previewDelay = 400; // мс после последнего нажатия
+
+let previewTimer = null;
+let previewRequest = 0;
+
+function csrfToken() {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    return meta ? meta.content : "";
+}
+
+function updatePreview() {
+    const textarea = document.getElementById("content");
+    const preview = document.getElementById("preview");
+    const request = ++previewRequest;
+    // На странице редактирования ссылки на вложения разрешаются по вложениям публикации
+    const postID = Number(textarea.dataset.postId || 0);
+
+    fetch("/api/v1/preview", {
+        method: "POST",
+        credentials: "same-origin",
+        headers: { "Content-Type": "application/json", "X-CSRF-Token": csrfToken() },
+        body: JSON.stringify(postID ? { content: textarea.value, post_id: postID } : { content: textarea.value }),
+    })
+    .then(response => response.json())
+    .then(body => {
+        // Ответ на устаревший запрос не показываем
+        if (request !== previewRequest) return;
+        if (body.data) {
+            // HTML уже очищен сервером
+            preview.innerHTML = body.data.html;
+        } else if (body.error) {
+            preview.textContent = body.error.message;
+        }
+    })
+    .catch(error => {
+        console.error("Ошибка:", error);
+    });
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+    const content = document.getElementById("content");
+    if (!content) return;
+    content.addEventListener("input", () => {
+        clearTimeout(previewTimer);
+        previewTimer = setTimeout(updatePreview, previewDelay);
+    });
+    if (content.value) updatePreview();
+});